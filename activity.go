@@ -0,0 +1,116 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ─── GET /api/me/activity ─────────────────────────────────────────────────────
+
+// ActivityEvent is one entry in a seller's activity feed.
+type ActivityEvent struct {
+	Type   string  `json:"type"` // listed | views | offer | comment | sold
+	CarID  int     `json:"car_id"`
+	At     string  `json:"at"` // RFC3339
+	Actor  string  `json:"actor,omitempty"`
+	Amount float64 `json:"amount,omitempty"` // offer amount or sale price
+	Count  int     `json:"count,omitempty"`  // views that day
+	Text   string  `json:"text,omitempty"`   // comment text
+	at     time.Time
+}
+
+// activityHandler returns a paginated, newest-first feed of what happened to
+// the caller's listings: when each was listed and sold, offers and comments
+// from other users, and views (one event per listing per UTC day, stamped
+// with that day's latest view). Only the newest maxActivityEvents are kept.
+func activityHandler(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+
+	limit, offset, err := parsePagination(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, err.Error())
+		return
+	}
+
+	events := activityFor(claims.Username)
+	sort.SliceStable(events, func(i, j int) bool { return events[i].at.After(events[j].at) })
+	if len(events) > maxActivityEvents {
+		events = events[:maxActivityEvents]
+	}
+
+	page, meta := paginate(events, limit, offset)
+	respond(w, http.StatusOK, map[string]interface{}{
+		"events":     page,
+		"pagination": meta,
+	})
+}
+
+// activityFor collects the unsorted activity on username's listings.
+func activityFor(username string) []ActivityEvent {
+	events := []ActivityEvent{}
+	add := func(e ActivityEvent, at time.Time) {
+		e.at = at
+		e.At = at.Format(time.RFC3339)
+		events = append(events, e)
+	}
+	parse := func(s string) (time.Time, bool) {
+		t, err := time.Parse(time.RFC3339, s)
+		return t, err == nil
+	}
+
+	storeMu.RLock()
+	var own []CarListing
+	for _, car := range carStore {
+		if car.Seller == username {
+			own = append(own, car)
+		}
+	}
+	storeMu.RUnlock()
+
+	for _, car := range own {
+		if t, ok := parse(car.ListedAt); ok {
+			add(ActivityEvent{Type: "listed", CarID: car.ID}, t)
+		}
+		if t, ok := parse(car.SoldAt); ok && car.Status == statusSold {
+			add(ActivityEvent{Type: "sold", CarID: car.ID, Amount: car.SalePrice}, t)
+		}
+
+		offersMu.RLock()
+		for _, o := range carOffers[car.ID] {
+			if t, ok := parse(o.CreatedAt); ok {
+				add(ActivityEvent{Type: "offer", CarID: car.ID, Actor: o.Buyer, Amount: o.Amount}, t)
+			}
+		}
+		offersMu.RUnlock()
+
+		commentsMu.RLock()
+		for _, c := range carComments[car.ID] {
+			if t, ok := parse(c.CreatedAt); ok && c.Author != username {
+				add(ActivityEvent{Type: "comment", CarID: car.ID, Actor: c.Author, Text: c.Text}, t)
+			}
+		}
+		commentsMu.RUnlock()
+
+		// History is oldest first, so the last view of each day wins
+		daily := map[string]*ActivityEvent{}
+		var days []string
+		viewHistoryMu.Lock()
+		for _, t := range viewHistory[car.ID] {
+			day := t.UTC().Format("2006-01-02")
+			e, ok := daily[day]
+			if !ok {
+				e = &ActivityEvent{Type: "views", CarID: car.ID}
+				daily[day] = e
+				days = append(days, day)
+			}
+			e.Count++
+			e.at = t
+		}
+		viewHistoryMu.Unlock()
+		for _, day := range days {
+			add(*daily[day], daily[day].at)
+		}
+	}
+	return events
+}
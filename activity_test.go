@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestActivityFeedInterleavesByTime(t *testing.T) {
+	base := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	at := func(h int) time.Time { return base.Add(time.Duration(h) * time.Hour) }
+	stamp := func(h int) string { return at(h).Format(time.RFC3339) }
+
+	useStore(t,
+		CarListing{ID: 1, Seller: "alice", ListedAt: stamp(0)},
+		CarListing{ID: 2, Seller: "alice", ListedAt: stamp(2), Status: statusSold, SoldAt: stamp(30), SalePrice: 41000},
+		CarListing{ID: 3, Seller: "bob", ListedAt: stamp(5)},
+	)
+	carOffers[1] = []Offer{{CarID: 1, Buyer: "carol", Amount: 39000, CreatedAt: stamp(4)}}
+	carOffers[3] = []Offer{{CarID: 3, Buyer: "carol", Amount: 10000, CreatedAt: stamp(6)}}
+	carComments[2] = []Comment{
+		{CarID: 2, Author: "dave", Text: "Still available?", CreatedAt: stamp(3)},
+		{CarID: 2, Author: "alice", Text: "Yes", CreatedAt: stamp(7)}, // the seller's own reply
+	}
+	viewHistory[1] = []time.Time{at(1), at(8), at(26)} // two views on day one, one on day two
+
+	rec := httptest.NewRecorder()
+	activityHandler(rec, asUser(httptest.NewRequest("GET", "/api/me/activity", nil), "alice"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var got struct {
+		Events []ActivityEvent `json:"events"`
+	}
+	decodeData(t, rec, &got)
+
+	type event struct {
+		Type  string
+		CarID int
+		At    string
+	}
+	var seen []event
+	for _, e := range got.Events {
+		seen = append(seen, event{e.Type, e.CarID, e.At})
+	}
+	want := []event{
+		{"sold", 2, stamp(30)},
+		{"views", 1, stamp(26)},
+		{"views", 1, stamp(8)},
+		{"offer", 1, stamp(4)},
+		{"comment", 2, stamp(3)},
+		{"listed", 2, stamp(2)},
+		{"listed", 1, stamp(0)},
+	}
+	if !reflect.DeepEqual(seen, want) {
+		t.Errorf("feed =\n%v\nwant\n%v", seen, want)
+	}
+	for _, e := range got.Events {
+		if e.Type == "views" && e.At == stamp(8) && e.Count != 2 {
+			t.Errorf("day one views count = %d, want 2", e.Count)
+		}
+	}
+}
+
+func TestActivityFeedCapped(t *testing.T) {
+	useStore(t, CarListing{ID: 1, Seller: "alice", ListedAt: "2026-01-01T00:00:00Z"})
+	base := time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC)
+	var offers []Offer
+	for i := 0; i < maxActivityEvents+10; i++ {
+		offers = append(offers, Offer{CarID: 1, Buyer: "bob", Amount: float64(i), CreatedAt: base.Add(time.Duration(i) * time.Minute).Format(time.RFC3339)})
+	}
+	carOffers[1] = offers
+
+	rec := httptest.NewRecorder()
+	activityHandler(rec, asUser(httptest.NewRequest("GET", "/api/me/activity?limit=1", nil), "alice"))
+	var got struct {
+		Events     []ActivityEvent `json:"events"`
+		Pagination PageMeta        `json:"pagination"`
+	}
+	decodeData(t, rec, &got)
+	if got.Pagination.Total != maxActivityEvents {
+		t.Errorf("total = %d, want capped at %d", got.Pagination.Total, maxActivityEvents)
+	}
+	if len(got.Events) == 0 || got.Events[0].Amount != float64(maxActivityEvents+9) {
+		t.Errorf("first event = %+v, want the newest offer", got.Events)
+	}
+}
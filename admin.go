@@ -0,0 +1,306 @@
+package main
+
+import (
+	"context"
+	"log"
+	"maps"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ─── GET|PUT /api/admin/valuation/base-prices ─────────────────────────────────
+
+// getBasePricesHandler returns the current tiers as make → {price,
+// updated_at}, leaving out updated_at for tiers never reviewed.
+func getBasePricesHandler(w http.ResponseWriter, r *http.Request) {
+	// Copy under the read lock so encoding never touches the live map
+	basePricesMu.RLock()
+	prices := basePriceEntries()
+	basePricesMu.RUnlock()
+
+	respond(w, http.StatusOK, prices)
+}
+
+// putBasePricesHandler merges a JSON map of make → tier into the tier table,
+// so operators can retune the valuation engine without a deploy.
+// Existing makes are updated and new makes are added; every price must be
+// positive or the whole update is rejected. A tier may carry the date its
+// price was actually reviewed; otherwise the update counts as the review.
+// With persistence enabled the merged table is saved before it takes effect,
+// so a failed write leaves the tiers unchanged.
+//
+// Request body: { "bmw": 54000, "rivian": {"price": 65000, "updated_at": "2026-01-05T00:00:00Z"} }
+func putBasePricesHandler(w http.ResponseWriter, r *http.Request) {
+	var body map[string]basePriceEntry
+	if status, err := decodeJSON(w, r, &body, maxBodyBytes); err != nil {
+		respondError(w, status, errCodeInvalidBody, err.Error())
+		return
+	}
+	if len(body) == 0 {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "at least one make is required")
+		return
+	}
+
+	now := time.Now()
+	updates, err := normalizeBasePrices(body, now)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, err.Error())
+		return
+	}
+
+	basePricesMu.Lock()
+	prevPrices, prevUpdated := maps.Clone(basePriceTable), maps.Clone(basePriceUpdatedAt)
+	for brand, e := range updates {
+		basePriceTable[brand] = e.Price
+		basePriceUpdatedAt[brand] = now
+		if e.UpdatedAt != nil {
+			basePriceUpdatedAt[brand] = *e.UpdatedAt
+		}
+	}
+	if err := saveBasePrices(); err != nil {
+		basePriceTable, basePriceUpdatedAt = prevPrices, prevUpdated
+		basePricesMu.Unlock()
+		log.Printf("ERROR saving base prices: %v", err)
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "failed to save base prices")
+		return
+	}
+	basePricesMu.Unlock()
+	clearValuationCache()
+
+	getBasePricesHandler(w, r)
+}
+
+// ─── POST|GET|DELETE /api/admin/valuations/recompute ──────────────────────────
+
+// recomputeJob tracks the background job that refreshes valuationCache for
+// every active listing. Only one runs at a time.
+type recomputeJob struct {
+	Run        int    `json:"run"`   // increments with each started job
+	State      string `json:"state"` // running | completed | cancelled
+	Total      int    `json:"total"`
+	Done       int    `json:"done"`
+	StartedAt  string `json:"started_at"`
+	FinishedAt string `json:"finished_at,omitempty"`
+
+	cancel context.CancelFunc
+}
+
+var (
+	recomputeLatest *recomputeJob // most recent job, nil until one starts
+	recomputeMu     sync.Mutex
+)
+
+// startRecomputeHandler starts a recompute job and returns 202 with its
+// status. Idempotent: while a job is running, it returns that job (200)
+// instead of starting another.
+func startRecomputeHandler(w http.ResponseWriter, r *http.Request) {
+	recomputeMu.Lock()
+	defer recomputeMu.Unlock()
+
+	if recomputeLatest != nil && recomputeLatest.State == "running" {
+		respond(w, http.StatusOK, *recomputeLatest)
+		return
+	}
+
+	storeMu.RLock()
+	var reqs []ValuationRequest
+	for _, car := range carStore {
+		if car.Status == statusActive {
+			reqs = append(reqs, valuationRequestFor(car))
+		}
+	}
+	storeMu.RUnlock()
+
+	run := 1
+	if recomputeLatest != nil {
+		run = recomputeLatest.Run + 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	job := &recomputeJob{
+		Run:       run,
+		State:     "running",
+		Total:     len(reqs),
+		StartedAt: time.Now().Format(time.RFC3339),
+		cancel:    cancel,
+	}
+	recomputeLatest = job
+	go job.run(ctx, reqs)
+
+	respond(w, http.StatusAccepted, *job)
+}
+
+// run valuates each request into the cache, stopping early if cancelled.
+func (job *recomputeJob) run(ctx context.Context, reqs []ValuationRequest) {
+	state := "completed"
+	for _, req := range reqs {
+		if ctx.Err() != nil {
+			state = "cancelled"
+			break
+		}
+		storeValuation(req)
+		recomputeMu.Lock()
+		job.Done++
+		recomputeMu.Unlock()
+	}
+
+	recomputeMu.Lock()
+	job.State = state
+	job.FinishedAt = time.Now().Format(time.RFC3339)
+	recomputeMu.Unlock()
+	job.cancel()
+}
+
+// recomputeStatusHandler reports the latest job's progress.
+func recomputeStatusHandler(w http.ResponseWriter, r *http.Request) {
+	recomputeMu.Lock()
+	defer recomputeMu.Unlock()
+
+	if recomputeLatest == nil {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "no recompute job has been started")
+		return
+	}
+	respond(w, http.StatusOK, *recomputeLatest)
+}
+
+// cancelRecomputeHandler cancels the running job. The job stops before its
+// next listing and reports "cancelled"; entries already cached are kept.
+// Returns 409 when no job is running.
+func cancelRecomputeHandler(w http.ResponseWriter, r *http.Request) {
+	recomputeMu.Lock()
+	defer recomputeMu.Unlock()
+
+	if recomputeLatest == nil || recomputeLatest.State != "running" {
+		respondError(w, http.StatusConflict, errCodeConflict, "no recompute job is running")
+		return
+	}
+	recomputeLatest.cancel()
+	respond(w, http.StatusOK, *recomputeLatest)
+}
+
+// ─── POST /api/admin/impersonate ──────────────────────────────────────────────
+
+// impersonateHandler lets support staff act as a seller to reproduce issues.
+// It returns a short-lived access token for the target that carries an
+// impersonated_by claim, and logs the event for audit. Admin accounts can
+// never be impersonated.
+//
+// Request body: { "username": "demo" }
+func impersonateHandler(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+
+	var body struct {
+		Username string `json:"username"`
+	}
+	if status, err := decodeJSON(w, r, &body, maxBodyBytes); err != nil {
+		respondError(w, status, errCodeInvalidBody, err.Error())
+		return
+	}
+	target := strings.TrimSpace(body.Username)
+	if target == "" {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "username is required")
+		return
+	}
+	if isAdmin(target) {
+		respondError(w, http.StatusForbidden, errCodeForbidden, "admin accounts cannot be impersonated")
+		return
+	}
+	if !userExists(target) {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "user not found")
+		return
+	}
+
+	token, expires, err := generateImpersonationToken(target, claims.Username)
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "token generation failed")
+		return
+	}
+
+	log.Printf("AUDIT impersonation: %s is acting as %s until %s", claims.Username, target, expires.Format(time.RFC3339))
+	respond(w, http.StatusOK, map[string]interface{}{
+		"access_token":    token,
+		"expires_in":      int(impersonationTTL.Seconds()),
+		"username":        target,
+		"impersonated_by": claims.Username,
+	})
+}
+
+// ─── GET /api/admin/validate-store ────────────────────────────────────────────
+
+// invalidListing is one listing that fails validateListing.
+type invalidListing struct {
+	ID     int               `json:"id"`
+	Seller string            `json:"seller"`
+	Status string            `json:"status"`
+	Errors []ValidationError `json:"errors"`
+}
+
+// validateStoreHandler re-runs validateListing over every listing, so
+// operators can find legacy or seeded data that breaks rules tightened since
+// it was stored. It reports offenders by ID, paginated, and changes nothing.
+func validateStoreHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, err.Error())
+		return
+	}
+
+	storeMu.RLock()
+	checked := len(carStore)
+	invalid := []invalidListing{}
+	for _, car := range carStore {
+		if errs := validateListing(car); len(errs) > 0 {
+			invalid = append(invalid, invalidListing{
+				ID: car.ID, Seller: car.Seller, Status: car.Status, Errors: errs,
+			})
+		}
+	}
+	storeMu.RUnlock()
+
+	sort.Slice(invalid, func(i, j int) bool { return invalid[i].ID < invalid[j].ID })
+	page, meta := paginate(invalid, limit, offset)
+	respond(w, http.StatusOK, map[string]interface{}{
+		"checked":    checked,
+		"invalid":    page,
+		"pagination": meta,
+	})
+}
+
+// ─── Helper ───────────────────────────────────────────────────────────────────
+
+// isAdmin reports whether the given user may call /api/admin routes: one of
+// adminUsernames or a service account whose API key has the admin role.
+func isAdmin(username string) bool {
+	for _, u := range adminUsernames {
+		if u == username {
+			return true
+		}
+	}
+	k, ok := serviceAccount(username)
+	return ok && k.Role == "admin"
+}
+
+// userExists reports whether username is a known account: a registered user
+// or anyone who owns a listing.
+func userExists(username string) bool {
+	usersMu.RLock()
+	_, registered := users[username]
+	usersMu.RUnlock()
+	return registered || ownsListings(username)
+}
+
+// ownsListings reports whether any listing in the store names username as
+// its seller, including accounts that were never registered, like the
+// seeded "demo" seller.
+func ownsListings(username string) bool {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	for _, car := range carStore {
+		if car.Seller == username {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,292 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// useBasePrices restores the valuation tiers, and the persistence paths
+// they're saved to, when the test ends. Saves go to a temporary directory.
+func useBasePrices(t *testing.T) (dir string) {
+	t.Helper()
+	basePricesMu.Lock()
+	prices, updated := maps.Clone(basePriceTable), maps.Clone(basePriceUpdatedAt)
+	basePricesMu.Unlock()
+	savedData, savedFile := dataFile, basePricesFile
+	t.Cleanup(func() {
+		basePricesMu.Lock()
+		basePriceTable, basePriceUpdatedAt = prices, updated
+		basePricesMu.Unlock()
+		dataFile, basePricesFile = savedData, savedFile
+		clearValuationCache()
+	})
+
+	dir = t.TempDir()
+	dataFile = filepath.Join(dir, "cars.json")
+	basePricesFile = ""
+	return dir
+}
+
+func putBasePrices(t *testing.T, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	putBasePricesHandler(rec, asUser(jsonRequest(t, "PUT", "/api/admin/valuation/base-prices", body), "seller"))
+	return rec
+}
+
+func TestPutBasePricesChangesValuations(t *testing.T) {
+	dir := useBasePrices(t)
+	clearValuationCache()
+
+	bmw := ValuationRequest{Make: "BMW", Year: time.Now().Year(), Mileage: 50000, Condition: "used", FuelType: "petrol"}
+	before := valuate(t, bmw) // cached at the old tier
+
+	rec := putBasePrices(t, map[string]interface{}{"BMW": basePriceFor("bmw") * 2, "rivian": 65000})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT = %d: %s", rec.Code, rec.Body.String())
+	}
+	after := valuate(t, bmw)
+	if ratio := after.EstimatedMax / before.EstimatedMax; ratio < 1.95 || ratio > 2.05 {
+		t.Errorf("estimate went %v → %v after doubling the tier, want ~2×", before.EstimatedMax, after.EstimatedMax)
+	}
+	if _, known := lookupBasePrice("Rivian R1T"); !known {
+		t.Errorf("new make rivian was not added")
+	}
+
+	// The merged table was saved and loads back
+	data, err := os.ReadFile(filepath.Join(dir, "base-prices.json"))
+	if err != nil {
+		t.Fatalf("base prices not persisted: %v", err)
+	}
+	var saved map[string]basePriceEntry
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if saved["rivian"].Price != 65000 || saved["ferrari"].Price == 0 {
+		t.Errorf("saved table = %v, want the merged tiers", saved)
+	}
+	basePricesMu.Lock()
+	delete(basePriceTable, "rivian")
+	basePricesMu.Unlock()
+	if err := loadBasePrices(); err != nil {
+		t.Fatal(err)
+	}
+	if basePriceFor("rivian") != 65000 {
+		t.Errorf("rivian tier not reloaded from disk")
+	}
+}
+
+func TestPutBasePricesRejectsInvalid(t *testing.T) {
+	useBasePrices(t)
+	ferrari := basePriceFor("ferrari")
+
+	for name, body := range map[string]interface{}{
+		"empty":        map[string]interface{}{},
+		"zero price":   map[string]interface{}{"ferrari": 1, "bmw": 0},
+		"blank make":   map[string]interface{}{" ": 1000},
+		"future stamp": map[string]interface{}{"bmw": map[string]interface{}{"price": 1, "updated_at": time.Now().Add(time.Hour)}},
+	} {
+		if rec := putBasePrices(t, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, rec.Code)
+		}
+	}
+	if basePriceFor("ferrari") != ferrari {
+		t.Errorf("a rejected update changed the ferrari tier")
+	}
+}
+
+func TestImpersonationToken(t *testing.T) {
+	resetRateLimiter(t)
+	useStore(t, CarListing{ID: 1, Seller: "alice"}, CarListing{ID: 2, Seller: "bob"})
+
+	impersonate := func(admin, target string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		body := map[string]string{"username": target}
+		impersonateHandler(rec, asUser(jsonRequest(t, "POST", "/api/admin/impersonate", body), admin))
+		return rec
+	}
+
+	rec := impersonate("seller", "alice")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("impersonate = %d: %s", rec.Code, rec.Body.String())
+	}
+	var got struct {
+		AccessToken    string `json:"access_token"`
+		Username       string `json:"username"`
+		ImpersonatedBy string `json:"impersonated_by"`
+	}
+	decodeData(t, rec, &got)
+	if got.Username != "alice" || got.ImpersonatedBy != "seller" {
+		t.Errorf("response names %q impersonated by %q, want alice by seller", got.Username, got.ImpersonatedBy)
+	}
+
+	claims, err := validateJWT(got.AccessToken, "access")
+	if err != nil {
+		t.Fatalf("impersonation token rejected: %v", err)
+	}
+	if claims.Username != "alice" || claims.ImpersonatedBy != "seller" {
+		t.Errorf("claims = %q impersonated by %q, want alice by seller", claims.Username, claims.ImpersonatedBy)
+	}
+	if ttl := time.Until(claims.ExpiresAt.Time); ttl > impersonationTTL || ttl < impersonationTTL-time.Minute {
+		t.Errorf("token lives %v, want about %v", ttl, impersonationTTL)
+	}
+
+	// The token acts as alice: her listing is hers to delete, bob's isn't
+	send := func(target string) int {
+		r := httptest.NewRequest("DELETE", target, nil)
+		r.Header.Set("Authorization", "Bearer "+got.AccessToken)
+		rec := httptest.NewRecorder()
+		newRouter().ServeHTTP(rec, r)
+		return rec.Code
+	}
+	if code := send("/api/cars/1?dry_run=1"); code != http.StatusOK {
+		t.Errorf("delete own listing as alice = %d, want 200", code)
+	}
+	if code := send("/api/cars/2?dry_run=1"); code != http.StatusForbidden {
+		t.Errorf("delete bob's listing as alice = %d, want 403", code)
+	}
+
+	if rec := impersonate("seller", "seller"); rec.Code != http.StatusForbidden {
+		t.Errorf("impersonating an admin = %d, want 403", rec.Code)
+	}
+	if rec := impersonate("seller", "nobody"); rec.Code != http.StatusNotFound {
+		t.Errorf("impersonating an unknown user = %d, want 404", rec.Code)
+	}
+	if rec := serveAPI(t, "POST", "/api/admin/impersonate", "bob"); rec.Code != http.StatusForbidden {
+		t.Errorf("non-admin impersonate = %d, want 403", rec.Code)
+	}
+}
+
+func TestRecomputeJobPopulatesCache(t *testing.T) {
+	cars := useStore(t,
+		CarListing{Make: "BMW", Model: "M3", Year: 2020, Mileage: 20000, Condition: "used", FuelType: "petrol"},
+		CarListing{Make: "Audi", Model: "RS6", Year: 2018, Mileage: 60000, Condition: "used", FuelType: "petrol"},
+		CarListing{Make: "Tesla", Model: "Model 3", Year: 2022, Mileage: 15000, FuelType: "electric"},
+		CarListing{Make: "Kia", Model: "Ceed", Year: 2016, Mileage: 90000, Status: statusSold},
+	)
+	recomputeMu.Lock()
+	saved := recomputeLatest
+	recomputeLatest = nil
+	recomputeMu.Unlock()
+	t.Cleanup(func() {
+		recomputeMu.Lock()
+		recomputeLatest = saved
+		recomputeMu.Unlock()
+	})
+
+	call := func(h http.HandlerFunc, method string) (int, recomputeJob) {
+		rec := httptest.NewRecorder()
+		h(rec, asUser(httptest.NewRequest(method, "/api/admin/valuations/recompute", nil), "seller"))
+		var job recomputeJob
+		if rec.Code < 300 {
+			decodeData(t, rec, &job)
+		}
+		return rec.Code, job
+	}
+	waitDone := func() recomputeJob {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			_, job := call(recomputeStatusHandler, "GET")
+			if job.State != "running" {
+				return job
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("job still running: %+v", job)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	if code, _ := call(recomputeStatusHandler, "GET"); code != http.StatusNotFound {
+		t.Errorf("status before any job = %d, want 404", code)
+	}
+	code, job := call(startRecomputeHandler, "POST")
+	if code != http.StatusAccepted || job.Run != 1 || job.Total != 3 {
+		t.Fatalf("start = %d %+v, want 202 for run 1 over the 3 active listings", code, job)
+	}
+
+	job = waitDone()
+	if job.State != "completed" || job.Done != 3 || job.FinishedAt == "" {
+		t.Errorf("finished job = %+v, want completed 3/3", job)
+	}
+
+	now := time.Now()
+	valuationCacheMu.RLock()
+	for _, car := range cars {
+		_, cached := valuationCache[valuationCacheKey(valuationRequestFor(car), now)]
+		if want := car.Status == statusActive; cached != want {
+			t.Errorf("%s %s cached = %v, want %v", car.Make, car.Model, cached, want)
+		}
+	}
+	valuationCacheMu.RUnlock()
+
+	if code, _ := call(cancelRecomputeHandler, "DELETE"); code != http.StatusConflict {
+		t.Errorf("cancel with no job running = %d, want 409", code)
+	}
+	if code, job := call(startRecomputeHandler, "POST"); code != http.StatusAccepted || job.Run != 2 {
+		t.Errorf("restart = %d %+v, want 202 for run 2", code, job)
+	}
+	waitDone()
+}
+
+func TestValidateStoreReportsInvalidListings(t *testing.T) {
+	resetRateLimiter(t)
+	valid := CarListing{Make: "BMW", Model: "M3", Year: 2021, Price: 70000, Mileage: 12000, FuelType: "petrol", Condition: "used"}
+	noPrice := valid
+	noPrice.Price = 0
+	badSpecs := valid
+	badSpecs.Condition, badSpecs.VIN = "mint", "NOTAVIN"
+	useStore(t, valid, noPrice, badSpecs, valid)
+	before := fmt.Sprint(carStore)
+
+	rec := serveAPI(t, "GET", "/api/admin/validate-store", demoUsername)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var got struct {
+		Checked int              `json:"checked"`
+		Invalid []invalidListing `json:"invalid"`
+	}
+	decodeData(t, rec, &got)
+	if got.Checked != 4 || len(got.Invalid) != 2 {
+		t.Fatalf("checked %d, invalid %+v; want 4 checked and listings 2 and 3 reported", got.Checked, got.Invalid)
+	}
+	fields := func(errs []ValidationError) []string {
+		var names []string
+		for _, e := range errs {
+			names = append(names, e.Field)
+		}
+		return names
+	}
+	if l := got.Invalid[0]; l.ID != 2 || !reflect.DeepEqual(fields(l.Errors), []string{"price"}) {
+		t.Errorf("first offender = %+v, want listing 2 failing on price", l)
+	}
+	if l := got.Invalid[1]; l.ID != 3 || !reflect.DeepEqual(fields(l.Errors), []string{"condition", "vin"}) {
+		t.Errorf("second offender = %+v, want listing 3 failing on condition and vin", l)
+	}
+	if fmt.Sprint(carStore) != before {
+		t.Errorf("validating the store modified it")
+	}
+
+	var page struct {
+		Invalid    []invalidListing `json:"invalid"`
+		Pagination PageMeta         `json:"pagination"`
+	}
+	decodeData(t, serveAPI(t, "GET", "/api/admin/validate-store?limit=1&offset=1", demoUsername), &page)
+	if len(page.Invalid) != 1 || page.Invalid[0].ID != 3 || page.Pagination.Total != 2 {
+		t.Errorf("second page = %+v, want just listing 3 of 2", page)
+	}
+
+	if rec := serveAPI(t, "GET", "/api/admin/validate-store", "alice"); rec.Code != http.StatusForbidden {
+		t.Errorf("non-admin: status = %d, want 403", rec.Code)
+	}
+}
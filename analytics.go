@@ -0,0 +1,83 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// ─── Analytics Events ─────────────────────────────────────────────────────────
+// Domain events (a listing was viewed, a valuation was run, …) written as
+// JSON lines for lightweight product analytics without a database. This is
+// separate from the access log: one line per business action, not per
+// request. Off unless analyticsEnabled; the sink is stdout or a file path.
+
+// Analytics event types.
+const (
+	eventListingViewed = "listing_viewed"
+	eventListingAdded  = "listing_added"
+	eventOfferMade     = "offer_made"
+	eventValuationRun  = "valuation_run"
+	eventLogin         = "login"
+)
+
+// analyticsEvent is one line of the event stream.
+type analyticsEvent struct {
+	Type      string                 `json:"type"`
+	Timestamp string                 `json:"timestamp"` // RFC3339Nano
+	Actor     string                 `json:"actor,omitempty"`
+	CarID     int                    `json:"car_id,omitempty"`
+	Data      map[string]interface{} `json:"data,omitempty"`
+}
+
+var (
+	analyticsOut io.Writer // nil when analytics are off
+	analyticsMu  sync.Mutex
+)
+
+// openAnalyticsSink points the event stream at analyticsSink. Files are
+// appended to, so restarts don't lose earlier events.
+func openAnalyticsSink() error {
+	if !analyticsEnabled {
+		return nil
+	}
+	if analyticsSink == "stdout" {
+		analyticsOut = os.Stdout
+		return nil
+	}
+	f, err := os.OpenFile(analyticsSink, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	analyticsOut = f
+	return nil
+}
+
+// emitEvent writes one event to the stream, if analytics are on. Lines are
+// written whole under analyticsMu so concurrent events never interleave. A
+// failed write is logged and dropped; analytics never fail a request.
+func emitEvent(typ, actor string, carID int, data map[string]interface{}) {
+	if analyticsOut == nil {
+		return
+	}
+	line, err := json.Marshal(analyticsEvent{
+		Type:      typ,
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Actor:     actor,
+		CarID:     carID,
+		Data:      data,
+	})
+	if err != nil {
+		log.Printf("analytics: encoding %s event: %v", typ, err)
+		return
+	}
+
+	analyticsMu.Lock()
+	defer analyticsMu.Unlock()
+	if _, err := analyticsOut.Write(append(line, '\n')); err != nil {
+		log.Printf("analytics: writing %s event: %v", typ, err)
+	}
+}
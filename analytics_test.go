@@ -0,0 +1,100 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// captureEvents turns analytics on for the test, writing to a buffer, and
+// returns a func decoding the events emitted so far.
+func captureEvents(t *testing.T) func() []analyticsEvent {
+	t.Helper()
+	saved := analyticsOut
+	t.Cleanup(func() { analyticsOut = saved })
+	var buf bytes.Buffer
+	analyticsOut = &buf
+
+	return func() []analyticsEvent {
+		t.Helper()
+		analyticsMu.Lock()
+		defer analyticsMu.Unlock()
+		var events []analyticsEvent
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var e analyticsEvent
+			if err := json.Unmarshal([]byte(line), &e); err != nil {
+				t.Fatalf("event line %q: %v", line, err)
+			}
+			events = append(events, e)
+		}
+		return events
+	}
+}
+
+func TestAnalyticsEvents(t *testing.T) {
+	useStore(t, CarListing{ID: 1, Seller: "alice"})
+	events := captureEvents(t)
+
+	getCarHandler(httptest.NewRecorder(), asUser(httptest.NewRequest("GET", "/api/cars/1", nil), "bob"))
+	rec := addCar(t, "alice", CarListing{Make: "Mazda", Model: "MX-5", Year: 2019, Price: 24000, Mileage: 30000})
+	var added CarListing
+	decodeData(t, rec, &added)
+	valuate(t, ValuationRequest{Make: "Mazda", Year: 2019, Mileage: 30000})
+
+	got := events()
+	if len(got) != 3 {
+		t.Fatalf("emitted %d events, want 3: %+v", len(got), got)
+	}
+	if e := got[0]; e.Type != eventListingViewed || e.Actor != "bob" || e.CarID != 1 {
+		t.Errorf("view event = %+v, want listing_viewed of car 1 by bob", e)
+	}
+	if e := got[1]; e.Type != eventListingAdded || e.Actor != "alice" || e.CarID != added.ID || e.Data["make"] != "Mazda" {
+		t.Errorf("add event = %+v, want listing_added of car %d by alice", e, added.ID)
+	}
+	if e := got[2]; e.Type != eventValuationRun || e.Actor != "seller" || e.Data["batch"] != false {
+		t.Errorf("valuation event = %+v, want a single valuation_run by seller", e)
+	}
+	for _, e := range got {
+		if e.Timestamp == "" {
+			t.Errorf("%s event has no timestamp", e.Type)
+		}
+	}
+}
+
+func TestAnalyticsSink(t *testing.T) {
+	savedOut, savedEnabled, savedSink := analyticsOut, analyticsEnabled, analyticsSink
+	t.Cleanup(func() { analyticsOut, analyticsEnabled, analyticsSink = savedOut, savedEnabled, savedSink })
+
+	// Off: nothing is opened and emitting is a no-op
+	analyticsOut, analyticsEnabled = nil, false
+	if err := openAnalyticsSink(); err != nil || analyticsOut != nil {
+		t.Fatalf("disabled: out %v, err %v; want no sink", analyticsOut, err)
+	}
+	emitEvent(eventLogin, "alice", 0, nil)
+
+	// A file sink is appended to across reopens
+	analyticsEnabled = true
+	analyticsSink = filepath.Join(t.TempDir(), "events.jsonl")
+	for _, user := range []string{"alice", "bob"} {
+		if err := openAnalyticsSink(); err != nil {
+			t.Fatal(err)
+		}
+		emitEvent(eventLogin, user, 0, nil)
+		analyticsOut.(*os.File).Close()
+	}
+	data, err := os.ReadFile(analyticsSink)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"actor":"alice"`) || !strings.Contains(lines[1], `"actor":"bob"`) {
+		t.Errorf("sink file =\n%s\nwant alice's then bob's login", data)
+	}
+}
@@ -2,7 +2,14 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
 )
 
 // ─── POST /api/login ──────────────────────────────────────────────────────────
@@ -15,29 +22,116 @@ import (
 // Protected by: RateLimitMiddleware (brute-force prevention)
 func loginHandler(w http.ResponseWriter, r *http.Request) {
 	var creds User
-	if err := json.NewDecoder(r.Body).Decode(&creds); err != nil {
-		respond(w, http.StatusBadRequest, nil, "invalid request body")
+	if status, err := decodeJSON(w, r, &creds, maxBodyBytes); err != nil {
+		respondError(w, status, errCodeInvalidBody, err.Error())
 		return
 	}
 
-	// Simple credential check — swap with a database lookup in production
-	if creds.Username != demoUsername || creds.Password != demoPassword {
-		respond(w, http.StatusUnauthorized, nil, "invalid credentials")
+	usersMu.RLock()
+	hash, known := users[creds.Username]
+	usersMu.RUnlock()
+
+	// Unknown usernames are still checked against a real hash, so response
+	// timing doesn't reveal whether a username exists.
+	if !known {
+		hash = demoPasswordHash
+	}
+	if !checkPassword(creds.Password, hash) || !known {
+		respondError(w, http.StatusUnauthorized, errCodeUnauthorized, "invalid credentials")
 		return
 	}
 
 	access, refresh, err := generateTokenPair(creds.Username)
 	if err != nil {
-		respond(w, http.StatusInternalServerError, nil, "token generation failed")
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "token generation failed")
 		return
 	}
 
+	emitEvent(eventLogin, creds.Username, 0, nil)
 	respond(w, http.StatusOK, LoginResponse{
 		AccessToken:  access,
 		RefreshToken: refresh,
 		ExpiresIn:    int(accessTokenTTL.Seconds()),
 		Message:      "login successful",
-	}, "")
+	})
+}
+
+// ─── POST /api/register ───────────────────────────────────────────────────────
+
+// registerHandler creates a new account.
+//
+// Request body:  { "username": "alice", "password": "at-least-8-chars" }
+// Response:      201 { "username": "alice" }, 409 if the name is taken
+//
+// A name is taken once it's registered, used by a service account, or owns
+// a listing; the last stops anyone claiming the seeded "demo" seller's cars
+// (or a seller's after a restart) by registering their name.
+//
+// Usernames are case-sensitive and limited to [a-z0-9_.-]; passwords must be
+// at least minPasswordLength characters and are stored only as bcrypt hashes.
+// Protected by: RateLimitMiddleware
+func registerHandler(w http.ResponseWriter, r *http.Request) {
+	var creds User
+	if status, err := decodeJSON(w, r, &creds, maxBodyBytes); err != nil {
+		respondError(w, status, errCodeInvalidBody, err.Error())
+		return
+	}
+
+	if !validUsername(creds.Username) {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, fmt.Sprintf(
+			"username must be %d-%d characters of a-z, 0-9, '_', '.', '-'",
+			minUsernameLength, maxUsernameLength))
+		return
+	}
+	if len(creds.Password) < minPasswordLength {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed,
+			fmt.Sprintf("password must be at least %d characters", minPasswordLength))
+		return
+	}
+
+	// Hash before taking the lock: bcrypt is deliberately slow
+	hash, err := bcrypt.GenerateFromPassword([]byte(creds.Password), bcrypt.DefaultCost)
+	if err != nil {
+		// bcrypt rejects passwords over 72 bytes
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "password is too long")
+		return
+	}
+
+	// Checked before taking usersMu so the two store locks are never nested
+	if _, service := serviceAccount(creds.Username); service || ownsListings(creds.Username) {
+		respondError(w, http.StatusConflict, errCodeConflict, "username already taken")
+		return
+	}
+
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	if _, taken := users[creds.Username]; taken {
+		respondError(w, http.StatusConflict, errCodeConflict, "username already taken")
+		return
+	}
+	users[creds.Username] = string(hash)
+	markCarsDirty()
+
+	respond(w, http.StatusCreated, map[string]string{"username": creds.Username})
+}
+
+// validUsername reports whether name meets the registration rules.
+func validUsername(name string) bool {
+	if len(name) < minUsernameLength || len(name) > maxUsernameLength {
+		return false
+	}
+	for _, c := range name {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '_' || c == '.' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// checkPassword reports whether plain matches the bcrypt hash. bcrypt's
+// comparison is constant-time, and a malformed hash simply fails.
+func checkPassword(plain, hash string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(plain)) == nil
 }
 
 // ─── POST /api/refresh ────────────────────────────────────────────────────────
@@ -54,15 +148,15 @@ func refreshHandler(w http.ResponseWriter, r *http.Request) {
 	var body struct {
 		RefreshToken string `json:"refresh_token"`
 	}
-	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
-		respond(w, http.StatusBadRequest, nil, "invalid request body")
+	if status, err := decodeJSON(w, r, &body, maxBodyBytes); err != nil {
+		respondError(w, status, errCodeInvalidBody, err.Error())
 		return
 	}
 
 	// Validate the JWT itself (signature + expiry + type)
 	claims, err := validateJWT(body.RefreshToken, "refresh")
 	if err != nil {
-		respond(w, http.StatusUnauthorized, nil, "invalid refresh token")
+		respondError(w, http.StatusUnauthorized, errCodeUnauthorized, "invalid refresh token")
 		return
 	}
 
@@ -72,7 +166,7 @@ func refreshHandler(w http.ResponseWriter, r *http.Request) {
 	refreshTokensMu.RUnlock()
 
 	if !exists {
-		respond(w, http.StatusUnauthorized, nil, "refresh token revoked")
+		respondError(w, http.StatusUnauthorized, errCodeUnauthorized, "refresh token revoked")
 		return
 	}
 
@@ -83,7 +177,7 @@ func refreshHandler(w http.ResponseWriter, r *http.Request) {
 
 	access, refresh, err := generateTokenPair(claims.Username)
 	if err != nil {
-		respond(w, http.StatusInternalServerError, nil, "token generation failed")
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "token generation failed")
 		return
 	}
 
@@ -92,7 +186,7 @@ func refreshHandler(w http.ResponseWriter, r *http.Request) {
 		RefreshToken: refresh,
 		ExpiresIn:    int(accessTokenTTL.Seconds()),
 		Message:      "tokens refreshed",
-	}, "")
+	})
 }
 
 // ─── POST /api/logout ─────────────────────────────────────────────────────────
@@ -115,5 +209,47 @@ func logoutHandler(w http.ResponseWriter, r *http.Request) {
 		refreshTokensMu.Unlock()
 	}
 
-	respond(w, http.StatusOK, map[string]string{"message": "logged out"}, "")
+	respond(w, http.StatusOK, map[string]string{"message": "logged out"})
+}
+
+// ─── GET /api/token/validate ──────────────────────────────────────────────────
+
+// validateTokenHandler reports whether the bearer access token is still valid,
+// without touching any server-side state. Lets the SPA check its token up front
+// instead of probing a real endpoint and swallowing the 401.
+//
+// Response (200): { "valid": true, "username": "seller", "expires_at": "..." }
+// Response (401): { "valid": false, "reason": "expired" | "not_yet_valid" | "malformed" }
+func validateTokenHandler(w http.ResponseWriter, r *http.Request) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		respondError(w, http.StatusUnauthorized, errCodeUnauthorized, "authorization header required")
+		return
+	}
+
+	claims, err := validateJWT(strings.TrimPrefix(authHeader, "Bearer "), "access")
+	if err != nil {
+		reason, msg := "malformed", "invalid access token"
+		switch {
+		case errors.Is(err, jwt.ErrTokenExpired):
+			reason, msg = "expired", "access token expired"
+		case errors.Is(err, jwt.ErrTokenNotValidYet):
+			reason, msg = "not_yet_valid", "token not yet valid"
+		}
+		writeEnvelope(w, http.StatusUnauthorized, APIResponse{
+			Data: map[string]interface{}{
+				"valid":  false,
+				"reason": reason,
+			},
+			Error:     msg,
+			ErrorCode: errCodeUnauthorized,
+		})
+		return
+	}
+
+	respond(w, http.StatusOK, map[string]interface{}{
+		"valid":      true,
+		"username":   claims.Username,
+		"expires_at": claims.ExpiresAt.Format(time.RFC3339),
+	})
 }
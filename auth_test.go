@@ -0,0 +1,250 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// signToken mints an access token for username with the given validity
+// window, issued at iat.
+func signToken(t *testing.T, username string, iat, nbf, exp time.Time) string {
+	t.Helper()
+	claims := &Claims{
+		Username:  username,
+		TokenType: "access",
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(iat),
+			NotBefore: jwt.NewNumericDate(nbf),
+			ExpiresAt: jwt.NewNumericDate(exp),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	if err != nil {
+		t.Fatalf("signing token: %v", err)
+	}
+	return token
+}
+
+func TestValidateTokenHandler(t *testing.T) {
+	now := time.Now()
+	valid, _, err := generateTokenPair("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := signToken(t, "alice", now.Add(-time.Hour), now.Add(-time.Hour), now.Add(-time.Minute))
+
+	// Flip a character in the signature
+	sig := strings.LastIndexByte(valid, '.') + 1
+	flipped := byte('A')
+	if valid[sig] == 'A' {
+		flipped = 'B'
+	}
+	tampered := valid[:sig] + string(flipped) + valid[sig+1:]
+
+	tests := []struct {
+		name   string
+		token  string
+		code   int
+		reason string
+	}{
+		{"valid", valid, http.StatusOK, ""},
+		{"expired", expired, http.StatusUnauthorized, "expired"},
+		{"tampered", tampered, http.StatusUnauthorized, "malformed"},
+		{"garbage", "not-a-jwt", http.StatusUnauthorized, "malformed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/token/validate", nil)
+			r.Header.Set("Authorization", "Bearer "+tt.token)
+			rec := httptest.NewRecorder()
+			validateTokenHandler(rec, r)
+			if rec.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.code, rec.Body.String())
+			}
+			var got struct {
+				Valid    bool   `json:"valid"`
+				Username string `json:"username"`
+				Reason   string `json:"reason"`
+			}
+			decodeData(t, rec, &got)
+			if got.Valid != (tt.code == http.StatusOK) || got.Reason != tt.reason {
+				t.Errorf("got valid=%v reason=%q, want reason %q", got.Valid, got.Reason, tt.reason)
+			}
+			if got.Valid && got.Username != "alice" {
+				t.Errorf("username = %q, want alice", got.Username)
+			}
+		})
+	}
+
+	// Checking a token doesn't use it up
+	for i := 0; i < 2; i++ {
+		r := httptest.NewRequest("GET", "/api/token/validate", nil)
+		r.Header.Set("Authorization", "Bearer "+valid)
+		rec := httptest.NewRecorder()
+		validateTokenHandler(rec, r)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("check %d = %d, want 200", i+1, rec.Code)
+		}
+	}
+}
+
+func TestNotBeforeClaim(t *testing.T) {
+	now := time.Now()
+	future := signToken(t, "alice", now, now.Add(time.Hour), now.Add(2*time.Hour))
+	active := signToken(t, "alice", now, now.Add(-time.Second), now.Add(time.Hour))
+
+	if _, err := validateJWT(future, "access"); !errors.Is(err, jwt.ErrTokenNotValidYet) {
+		t.Errorf("future nbf: err = %v, want ErrTokenNotValidYet", err)
+	}
+	if claims, err := validateJWT(active, "access"); err != nil || claims.Username != "alice" {
+		t.Errorf("past nbf: claims %v, err %v; want alice", claims, err)
+	}
+
+	// Freshly minted tokens are valid straight away
+	access, _, err := generateTokenPair("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims, err := validateJWT(access, "access"); err != nil || claims.NotBefore == nil {
+		t.Errorf("new token: claims %v, err %v; want valid with an nbf", claims, err)
+	}
+
+	auth := func(token string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("GET", "/api/me/activity", nil)
+		r.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		AuthMiddleware(okHandler)(rec, r)
+		return rec
+	}
+	if rec := auth(future); rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "token not yet valid") {
+		t.Errorf("middleware, future nbf = %d %s, want 401 token not yet valid", rec.Code, rec.Body.String())
+	}
+	if rec := auth(active); rec.Code != http.StatusNoContent {
+		t.Errorf("middleware, past nbf = %d, want it passed through", rec.Code)
+	}
+
+	r := httptest.NewRequest("GET", "/api/token/validate", nil)
+	r.Header.Set("Authorization", "Bearer "+future)
+	rec := httptest.NewRecorder()
+	validateTokenHandler(rec, r)
+	var got struct {
+		Valid  bool   `json:"valid"`
+		Reason string `json:"reason"`
+	}
+	decodeData(t, rec, &got)
+	if rec.Code != http.StatusUnauthorized || got.Valid || got.Reason != "not_yet_valid" {
+		t.Errorf("validate, future nbf = %d %+v, want 401 not_yet_valid", rec.Code, got)
+	}
+}
+
+func TestIssuedAtClockSkew(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name string
+		iat  time.Time
+		ok   bool
+	}{
+		{"issued now", now, true},
+		{"slightly ahead, within skew", now.Add(maxClockSkew / 2), true},
+		{"far future", now.Add(24 * time.Hour), false},
+		{"just beyond skew", now.Add(maxClockSkew + 5*time.Second), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := signToken(t, "alice", tt.iat, now.Add(-time.Minute), now.Add(time.Hour))
+			_, err := validateJWT(token, "access")
+			if tt.ok && err != nil {
+				t.Errorf("rejected: %v", err)
+			}
+			if !tt.ok && !errors.Is(err, jwt.ErrTokenInvalidClaims) {
+				t.Errorf("err = %v, want ErrTokenInvalidClaims", err)
+			}
+		})
+	}
+
+	// iat is required
+	claims := &Claims{
+		Username:  "alice",
+		TokenType: "access",
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := validateJWT(token, "access"); err == nil {
+		t.Errorf("token without iat was accepted")
+	}
+}
+
+func TestAPIKeyAuth(t *testing.T) {
+	resetRateLimiter(t)
+	useStore(t)
+	saved := apiKeys
+	t.Cleanup(func() { apiKeys = saved })
+	hash := func(key string) string {
+		sum := sha256.Sum256([]byte(key))
+		return hex.EncodeToString(sum[:])
+	}
+	apiKeys = []ServiceAPIKey{
+		{Hash: hash("ci-secret"), Username: "ci-bot", Role: "admin"},
+		{Hash: hash("feed-secret"), Username: "feed-bot", Role: "seller"},
+	}
+
+	var seen *Claims
+	h := AuthMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		seen = r.Context().Value(ctxKey("claims")).(*Claims)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	call := func(key string) *httptest.ResponseRecorder {
+		seen = nil
+		r := httptest.NewRequest("GET", "/api/me/activity", nil)
+		if key != "" {
+			r.Header.Set("X-API-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		h(rec, r)
+		return rec
+	}
+
+	if rec := call("ci-secret"); rec.Code != http.StatusNoContent || seen == nil || seen.Username != "ci-bot" || seen.TokenType != "api_key" {
+		t.Errorf("valid key = %d, claims %+v; want ci-bot's api_key claims", rec.Code, seen)
+	}
+	for _, key := range []string{"wrong-secret", hash("ci-secret"), "ci-secret "} {
+		rec := call(key)
+		if rec.Code != http.StatusUnauthorized || seen != nil || !strings.Contains(rec.Body.String(), "invalid API key") {
+			t.Errorf("key %q = %d %s, want 401 invalid API key", key, rec.Code, rec.Body.String())
+		}
+	}
+
+	// The key's role decides admin access through the full router
+	adminCall := func(key string) int {
+		r := httptest.NewRequest("GET", "/api/admin/validate-store", nil)
+		r.Header.Set("X-API-Key", key)
+		rec := httptest.NewRecorder()
+		newRouter().ServeHTTP(rec, r)
+		return rec.Code
+	}
+	if code := adminCall("ci-secret"); code != http.StatusOK {
+		t.Errorf("admin-role key on an admin route = %d, want 200", code)
+	}
+	if code := adminCall("feed-secret"); code != http.StatusForbidden {
+		t.Errorf("seller-role key on an admin route = %d, want 403", code)
+	}
+
+	// With no keys configured the header is ignored and a JWT is required
+	apiKeys = nil
+	if rec := call("ci-secret"); rec.Code != http.StatusUnauthorized || !strings.Contains(rec.Body.String(), "authorization header required") {
+		t.Errorf("keys disabled = %d %s, want 401 authorization header required", rec.Code, rec.Body.String())
+	}
+}
@@ -1,36 +1,100 @@
 package main
 
 import (
-	"encoding/json"
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
 	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
+	"unicode"
 )
 
 // ─── GET /api/cars ────────────────────────────────────────────────────────────
 
-// getCarsHandler returns all listings, with optional filtering and sorting.
+// getCarsHandler returns a page of listings, with optional filtering and
+// sorting.
 //
 // Query params:
 //
+//	include_sold — also return sold listings (active only by default)
+//	include_reserved — also return listings a buyer has reserved
 //	make        — filter by make (partial, case-insensitive)
 //	fuel        — filter by fuel type (petrol/diesel/electric/hybrid)
 //	condition   — filter by condition (new/used/certified)
+//	transmission — filter by transmission (manual/automatic)
+//	              unknown fuel/condition/transmission values match nothing,
+//	              or return 400 when strictEnumFilters is set
 //	min_price   — lower price bound
 //	max_price   — upper price bound
-//	sort        — price_asc | price_desc | year_desc
+//	sort        — comma-separated keys applied in order, e.g. year_desc,price_asc
+//	              keys: price_asc | price_desc | year_desc | mileage_asc |
+//	                    views_desc | price_per_hp_asc |
+//	                    quality_desc | hot
+//	price_tolerance — widen min/max_price by an amount ("500") or a
+//	              percentage of each bound ("5%"); default exact
+//	empty       — "404" or "200": status when nothing matches
+//	              (default per emptyResultNotFound)
+//	limit       — page size (default defaultPageLimit, capped at maxPageLimit)
+//	offset      — number of matching listings to skip
 func getCarsHandler(w http.ResponseWriter, r *http.Request) {
 	q := r.URL.Query()
+	limit, offset, err := parsePagination(q)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, err.Error())
+		return
+	}
 	makeF := strings.ToLower(q.Get("make"))
 	fuelF := strings.ToLower(q.Get("fuel"))
 	condF := strings.ToLower(q.Get("condition"))
+	transF := strings.ToLower(q.Get("transmission"))
+	includeSold := isTruthy(q.Get("include_sold"))
+	includeReserved := isTruthy(q.Get("include_reserved"))
+	if strictEnumFilters {
+		for _, f := range []struct {
+			name, value string
+			allowed     []string
+		}{
+			{"fuel", fuelF, fuelTypes},
+			{"condition", condF, conditions},
+			{"transmission", transF, transmissions},
+		} {
+			if f.value != "" && !slices.Contains(f.allowed, f.value) {
+				respondError(w, http.StatusBadRequest, errCodeValidationFailed, fmt.Sprintf("unknown %s %q (expected one of %s)",
+					f.name, f.value, strings.Join(f.allowed, ", ")))
+				return
+			}
+		}
+	}
 	minP, _ := strconv.ParseFloat(q.Get("min_price"), 64)
 	maxP, _ := strconv.ParseFloat(q.Get("max_price"), 64)
+	tolerance, err := parsePriceTolerance(q.Get("price_tolerance"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, err.Error())
+		return
+	}
+	minP, maxP = tolerance.widen(minP, maxP)
 
+	now := time.Now()
 	storeMu.RLock()
-	var listings []CarListing
+	listings := []CarListing{} // encode as [] rather than null when empty
 	for _, car := range carStore {
+		if car.Status != statusActive && !(includeSold && car.Status == statusSold) {
+			continue
+		}
+		if !includeReserved && isReserved(car, now) {
+			continue
+		}
+		car = liveViews(car)
+		car.Boosted = isBoosted(car, now)
+		car.QualityScore = listingQuality(car, now)
 		if makeF != "" && !strings.Contains(strings.ToLower(car.Make), makeF) {
 			continue
 		}
@@ -40,6 +104,9 @@ func getCarsHandler(w http.ResponseWriter, r *http.Request) {
 		if condF != "" && strings.ToLower(car.Condition) != condF {
 			continue
 		}
+		if transF != "" && strings.ToLower(car.Transmission) != transF {
+			continue
+		}
 		if minP > 0 && car.Price < minP {
 			continue
 		}
@@ -50,55 +117,349 @@ func getCarsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	storeMu.RUnlock()
 
-	// Simple bubble sort — fine for small in-memory slices
-	switch q.Get("sort") {
-	case "price_asc":
-		sortBy(listings, func(a, b CarListing) bool { return a.Price < b.Price })
-	case "price_desc":
-		sortBy(listings, func(a, b CarListing) bool { return a.Price > b.Price })
-	case "year_desc":
-		sortBy(listings, func(a, b CarListing) bool { return a.Year > b.Year })
+	if err := sortListings(listings, q.Get("sort")); err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, err.Error())
+		return
 	}
 
-	respond(w, http.StatusOK, map[string]interface{}{
-		"listings": listings,
-		"count":    len(listings),
-	}, "")
+	// Some clients want "nothing matched" as a 404 rather than an empty page
+	if len(listings) == 0 && emptyResultStatus(q.Get("empty")) == http.StatusNotFound {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "no listings match the filters")
+		return
+	}
+
+	// Paginate last, so pages are windows over the filtered, sorted result
+	page, meta := paginate(listings, limit, offset)
+	resp := map[string]interface{}{
+		"listings":   page,
+		"count":      len(page),
+		"pagination": meta,
+	}
+	if tolerance.amount > 0 {
+		resp["price_tolerance"] = map[string]interface{}{
+			"value":     tolerance.amount,
+			"percent":   tolerance.percent,
+			"min_price": minP,
+			"max_price": maxP,
+		}
+	}
+	respond(w, http.StatusOK, resp)
+}
+
+// listingComparators maps each sort key to a three-way comparison
+// (negative when a sorts first). "hot" is built per call in sortListings
+// since its score depends on the current time.
+var listingComparators = map[string]func(a, b CarListing) int{
+	"price_asc":   func(a, b CarListing) int { return cmp.Compare(a.Price, b.Price) },
+	"price_desc":  func(a, b CarListing) int { return cmp.Compare(b.Price, a.Price) },
+	"year_desc":   func(a, b CarListing) int { return cmp.Compare(b.Year, a.Year) },
+	"mileage_asc": func(a, b CarListing) int { return cmp.Compare(a.Mileage, b.Mileage) },
+	"views_desc":  func(a, b CarListing) int { return cmp.Compare(b.Views, a.Views) },
+	// quality_desc relies on QualityScore having been set on read
+	"quality_desc": func(a, b CarListing) int { return cmp.Compare(b.QualityScore, a.QualityScore) },
+	"price_per_hp_asc": func(a, b CarListing) int {
+		// Listings without a horsepower figure sort last
+		if c := compareBool(a.PricePerHP == 0, b.PricePerHP == 0); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.PricePerHP, b.PricePerHP)
+	},
+}
+
+// sortListings orders listings in place by a comma-separated list of sort
+// keys, e.g. "year_desc,price_asc" sorts by year and breaks ties on price.
+// Listings with an active boost always come first, ahead of the requested
+// keys. Listings equal on every key fall back to tieBreakLess so the order
+// is deterministic. Shared by every endpoint that accepts a `sort` query
+// param; an unknown key is an error.
+func sortListings(listings []CarListing, spec string) error {
+	now := time.Now()
+	cmps := []func(a, b CarListing) int{
+		func(a, b CarListing) int { return compareBool(isBoosted(b, now), isBoosted(a, now)) },
+	}
+	var keys []string
+	if spec != "" {
+		keys = strings.Split(spec, ",")
+	}
+	for _, key := range keys {
+		key = strings.TrimSpace(key)
+		if key == "hot" {
+			cmps = append(cmps, func(a, b CarListing) int { return cmp.Compare(hotScore(b, now), hotScore(a, now)) })
+			continue
+		}
+		c, ok := listingComparators[key]
+		if !ok {
+			return fmt.Errorf("unknown sort key %q", key)
+		}
+		cmps = append(cmps, c)
+	}
+
+	sortBy(listings, func(a, b CarListing) bool {
+		for _, c := range cmps {
+			if r := c(a, b); r != 0 {
+				return r < 0
+			}
+		}
+		return tieBreakLess(a, b)
+	})
+	return nil
 }
 
 // sortBy is a tiny generic-style helper for sorting CarListing slices.
+// Stable and O(n log n).
 func sortBy(lst []CarListing, less func(a, b CarListing) bool) {
-	for i := 0; i < len(lst)-1; i++ {
-		for j := i + 1; j < len(lst); j++ {
-			if !less(lst[i], lst[j]) {
-				lst[i], lst[j] = lst[j], lst[i]
-			}
+	sort.SliceStable(lst, func(i, j int) bool { return less(lst[i], lst[j]) })
+}
+
+// tieBreakLess orders two listings that compare equal on every requested
+// sort key according to sortTieBreak, falling back to ascending ID so the
+// result never depends on map order.
+func tieBreakLess(a, b CarListing) bool {
+	ta, tb := listedTime(a), listedTime(b)
+	if !ta.Equal(tb) {
+		if sortTieBreak == "oldest" {
+			return ta.Before(tb)
+		}
+		return ta.After(tb)
+	}
+	return a.ID < b.ID
+}
+
+// ─── GET /api/new-arrivals ────────────────────────────────────────────────────
+
+// newArrivalsHandler returns the most recently listed active cars, newest
+// first. ListedAt is parsed rather than compared as a string, so timestamps
+// with different UTC offsets still order correctly.
+//
+// Query params:
+//
+//	limit — max listings (default newArrivalsLimit, capped at maxPageLimit)
+//	since — only cars listed within this window: "7d", or a Go duration
+//	        like "36h"
+func newArrivalsHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	limit := newArrivalsLimit
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			respondError(w, http.StatusBadRequest, errCodeValidationFailed, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, maxPageLimit)
+	}
+
+	var cutoff time.Time
+	if raw := q.Get("since"); raw != "" {
+		window, err := parseWindow(raw)
+		if err != nil {
+			respondError(w, http.StatusBadRequest, errCodeValidationFailed, err.Error())
+			return
+		}
+		cutoff = time.Now().Add(-window)
+	}
+
+	now := time.Now()
+	storeMu.RLock()
+	arrivals := []CarListing{}
+	for _, car := range carStore {
+		if car.Status == statusActive && !isReserved(car, now) && !listedTime(car).Before(cutoff) {
+			arrivals = append(arrivals, liveViews(car))
+		}
+	}
+	storeMu.RUnlock()
+
+	sortBy(arrivals, func(a, b CarListing) bool {
+		if ta, tb := listedTime(a), listedTime(b); !ta.Equal(tb) {
+			return ta.After(tb)
+		}
+		return a.ID > b.ID
+	})
+	if len(arrivals) > limit {
+		arrivals = arrivals[:limit]
+	}
+
+	respond(w, http.StatusOK, map[string]interface{}{
+		"listings": arrivals,
+		"count":    len(arrivals),
+	})
+}
+
+// ─── GET /api/cars/in-budget ──────────────────────────────────────────────────
+
+// inBudgetHandler is budget-first discovery: active listings priced within
+// budget, plus up to flex percent over it, ordered by how well they use the
+// budget. Cars at or under budget come first, most expensive first (closest
+// to the budget); cars in the flex band follow, least over first. At most
+// inBudgetMaxPerMake cars per make are returned so one brand can't crowd out
+// the rest.
+//
+// Query params:
+//
+//	budget — required, positive
+//	flex   — optional percentage the budget may be stretched by (default 0)
+//	limit  — max listings (default newArrivalsLimit, capped at maxPageLimit)
+func inBudgetHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	budget, err := strconv.ParseFloat(q.Get("budget"), 64)
+	if err != nil || budget <= 0 || math.IsInf(budget, 0) {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "budget must be a positive number")
+		return
+	}
+	tolerance := priceTolerance{percent: true}
+	if raw := q.Get("flex"); raw != "" {
+		// flex is always a percentage: "10" and "10%" mean the same
+		if tolerance, err = parsePriceTolerance(strings.TrimSuffix(raw, "%") + "%"); err != nil {
+			respondError(w, http.StatusBadRequest, errCodeValidationFailed, "flex must be a non-negative percentage")
+			return
+		}
+	}
+	_, ceiling := tolerance.widen(0, budget)
+
+	limit := newArrivalsLimit
+	if raw := q.Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 {
+			respondError(w, http.StatusBadRequest, errCodeValidationFailed, "limit must be a positive integer")
+			return
+		}
+		limit = min(n, maxPageLimit)
+	}
+
+	now := time.Now()
+	storeMu.RLock()
+	matches := []CarListing{}
+	for _, car := range carStore {
+		if car.Status == statusActive && !isReserved(car, now) && car.Price <= ceiling {
+			matches = append(matches, liveViews(car))
+		}
+	}
+	storeMu.RUnlock()
+
+	sortBy(matches, func(a, b CarListing) bool {
+		aOver, bOver := a.Price > budget, b.Price > budget
+		switch {
+		case aOver != bOver:
+			return !aOver
+		case a.Price != b.Price && aOver:
+			return a.Price < b.Price
+		case a.Price != b.Price:
+			return a.Price > b.Price
 		}
+		return a.ID < b.ID
+	})
+
+	perMake := map[string]int{}
+	listings := []CarListing{}
+	for _, car := range matches {
+		if len(listings) == limit {
+			break
+		}
+		brand := strings.ToLower(car.Make)
+		if perMake[brand] >= inBudgetMaxPerMake {
+			continue
+		}
+		perMake[brand]++
+		listings = append(listings, car)
 	}
+
+	respond(w, http.StatusOK, map[string]interface{}{
+		"listings": listings,
+		"count":    len(listings),
+		"budget":   budget,
+		"ceiling":  ceiling,
+	})
+}
+
+// parseWindow parses a positive look-back window: "Nd" for N days, or
+// anything time.ParseDuration accepts.
+func parseWindow(raw string) (time.Duration, error) {
+	var d time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(raw, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(raw)
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf(`since must be a positive window like "7d" or "36h"`)
+	}
+	return d, nil
 }
 
 // ─── GET /api/cars/{id} ───────────────────────────────────────────────────────
 
 // getCarHandler returns a single listing by ID and increments its view counter.
+//
+// There is no read-modify-write of the listing, so a view can't resurrect a
+// car deleted concurrently: the count lives in carViews, and incrementViews
+// refuses to count a listing whose counter a hard delete already removed
+// (404). A delete that lands after the increment just discards the counter,
+// and recordView likewise skips listings whose counter is gone, so no view
+// history is left behind for a purged ID. Soft deletes and status changes
+// rewrite the listing under storeMu but keep its counter, so they can't lose
+// a view either.
 func getCarHandler(w http.ResponseWriter, r *http.Request) {
 	id, err := parseCarID(r.URL.Path)
 	if err != nil {
-		respond(w, http.StatusBadRequest, nil, "invalid car id")
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
 		return
 	}
 
-	storeMu.Lock()
+	// Read lock only: the view count lives in carViews and is bumped atomically
+	storeMu.RLock()
 	car, ok := carStore[id]
+	storeMu.RUnlock()
+	if !ok || car.Status == statusDeleted {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+	views, counted := incrementViews(id)
+	if !counted {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+	car.Views = views
+	now := time.Now()
+	recordView(id, now)
+
+	car.Boosted = isBoosted(car, now)
+	car.QualityScore = listingQuality(car, now)
+
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+	emitEvent(eventListingViewed, claims.Username, id, nil)
+
+	respond(w, http.StatusOK, car)
+}
+
+// ─── GET /api/cars/vin/{vin} ──────────────────────────────────────────────────
+
+// getCarByVINHandler looks a listing up by VIN (case-insensitive). Unlike
+// GET /api/cars/{id} it doesn't count as a view.
+func getCarByVINHandler(w http.ResponseWriter, r *http.Request) {
+	vin := normalizeVIN(strings.TrimPrefix(r.URL.Path, "/api/cars/vin/"))
+	if err := validateVIN(vin); err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, err.Error())
+		return
+	}
+
+	storeMu.RLock()
+	id, ok := vinIndex[vin]
+	car := carStore[id]
+	storeMu.RUnlock()
 	if !ok {
-		storeMu.Unlock()
-		respond(w, http.StatusNotFound, nil, "car not found")
+		respondError(w, http.StatusNotFound, errCodeNotFound, "no listing with this VIN")
 		return
 	}
-	car.Views++
-	carStore[id] = car
-	storeMu.Unlock()
 
-	respond(w, http.StatusOK, car, "")
+	car = liveViews(car)
+	now := time.Now()
+	car.Boosted = isBoosted(car, now)
+	car.QualityScore = listingQuality(car, now)
+	respond(w, http.StatusOK, car)
 }
 
 // ─── POST /api/cars/add ───────────────────────────────────────────────────────
@@ -109,40 +470,180 @@ func addCarHandler(w http.ResponseWriter, r *http.Request) {
 	claims := r.Context().Value(ctxKey("claims")).(*Claims)
 
 	var car CarListing
-	if err := json.NewDecoder(r.Body).Decode(&car); err != nil {
-		respond(w, http.StatusBadRequest, nil, "invalid request body")
+	if status, err := decodeJSON(w, r, &car, maxBodyBytes); err != nil {
+		respondError(w, status, errCodeInvalidBody, err.Error())
 		return
 	}
 
-	// Basic validation — all required fields must be present
-	if car.Make == "" || car.Model == "" || car.Year == 0 || car.Price <= 0 {
-		respond(w, http.StatusBadRequest, nil, "make, model, year and price are required")
+	if errs := validateListing(car); len(errs) > 0 {
+		respondInvalid(w, errs)
 		return
 	}
+	setPricePerHP(&car)
+	normalizeImages(&car)
+	car.Description = sanitizeText(car.Description, maxDescriptionLength)
+	car.VIN = normalizeVIN(car.VIN)
 
 	storeMu.Lock()
-	car.ID = nextID
+	if _, dup := vinIndex[car.VIN]; car.VIN != "" && dup {
+		storeMu.Unlock()
+		respondError(w, http.StatusConflict, errCodeConflict, "a listing with this VIN already exists")
+		return
+	}
+	// Cap the memory-backed store; admins are exempt so they can still
+	// seed or restore data when it's full
+	if maxListings > 0 && !isAdmin(claims.Username) && liveListingCount() >= maxListings {
+		storeMu.Unlock()
+		respondError(w, http.StatusInsufficientStorage, errCodeUnavailable,
+			fmt.Sprintf("listing limit reached (%d); remove a listing first", maxListings))
+		return
+	}
+	car.ID = nextCarID()
 	car.Seller = claims.Username // always from JWT, never from client body
 	car.ListedAt = time.Now().Format(time.RFC3339)
+	car.UpdatedAt = ""
+	car.BoostedUntil, car.Boosted = "", false
+	car.ReservedBy, car.ReservedUntil = "", ""
 	car.Views = 0
+	car.Status = statusActive
+	car.SoldAt, car.SalePrice = "", 0
 	carStore[car.ID] = car
-	nextID++
+	if car.VIN != "" {
+		vinIndex[car.VIN] = car.ID
+	}
+	setViews(car.ID, 0)
+	markCarsDirty()
 	storeMu.Unlock()
 
-	respond(w, http.StatusCreated, car, "")
+	emitEvent(eventListingAdded, claims.Username, car.ID, map[string]interface{}{
+		"make": car.Make, "model": car.Model, "price": car.Price,
+	})
+	respond(w, http.StatusCreated, car)
+}
+
+// ─── PUT|PATCH /api/cars/{id} ─────────────────────────────────────────────────
+
+// updateCarHandler applies a partial update to a listing. Only the seller may
+// update it. Just price, description, mileage, condition, horsepower,
+// negotiable and image_url can change; id, seller, listed_at, views and
+// anything else in the body are ignored, so edits don't lose the view
+// counter or the original listing date. The patched listing must pass
+// validateListing, which reports every invalid field with a 422 just like
+// add-car. A deleted listing can't be edited until it's restored (409).
+// Returns the full updated listing.
+func updateCarHandler(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+
+	id, err := parseCarID(r.URL.Path)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
+		return
+	}
+
+	// Pointers distinguish "absent" from zero values
+	var patch struct {
+		Price       *float64 `json:"price"`
+		Description *string  `json:"description"`
+		Mileage     *int     `json:"mileage"`
+		Condition   *string  `json:"condition"`
+		ImageURL    *string  `json:"image_url"`
+		Horsepower  *int     `json:"horsepower"`
+		Negotiable  *bool    `json:"negotiable"`
+	}
+	if status, err := decodeJSON(w, r, &patch, maxBodyBytes); err != nil {
+		respondError(w, status, errCodeInvalidBody, err.Error())
+		return
+	}
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	car, ok := carStore[id]
+	if !ok {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+
+	if car.Seller != claims.Username {
+		respondError(w, http.StatusForbidden, errCodeForbidden, "you can only update your own listings")
+		return
+	}
+	if car.Status == statusDeleted {
+		respondError(w, http.StatusConflict, errCodeConflict, "listing was deleted; restore it instead")
+		return
+	}
+
+	// Patch a copy so a rejected update leaves the stored listing untouched
+	if patch.Price != nil {
+		car.Price = *patch.Price
+	}
+	if patch.Description != nil {
+		car.Description = sanitizeText(*patch.Description, maxDescriptionLength)
+	}
+	if patch.Mileage != nil {
+		car.Mileage = *patch.Mileage
+	}
+	if patch.Condition != nil {
+		car.Condition = *patch.Condition
+	}
+	if patch.Horsepower != nil {
+		car.Horsepower = *patch.Horsepower
+	}
+	if patch.Negotiable != nil {
+		car.Negotiable = *patch.Negotiable
+	}
+	setPricePerHP(&car)
+	if patch.ImageURL != nil {
+		// Replaces (or with "", removes) the primary gallery image. Always
+		// builds a new slice: readers may still hold the old one.
+		var rest []string
+		if len(car.Images) > 0 {
+			rest = car.Images[1:]
+		}
+		car.ImageURL = *patch.ImageURL
+		if car.ImageURL != "" {
+			car.Images = append([]string{car.ImageURL}, rest...)
+		} else {
+			car.Images = append([]string{}, rest...)
+		}
+		normalizeImages(&car)
+	}
+	if errs := validateListing(car); len(errs) > 0 {
+		respondInvalid(w, errs)
+		return
+	}
+	now := time.Now()
+	car.UpdatedAt = now.Format(time.RFC3339)
+	carStore[id] = car
+	markCarsDirty()
+	pruneImageHashes(car)
+
+	car = liveViews(car)
+	car.Boosted = isBoosted(car, now)
+	car.QualityScore = listingQuality(car, now)
+	respond(w, http.StatusOK, car)
 }
 
 // ─── DELETE /api/cars/{id} ────────────────────────────────────────────────────
 
-// deleteCarHandler removes a listing. Only the original seller may delete it.
-// Returns 403 Forbidden (not 404) so the client knows the car exists but
-// they don't own it — this is intentional information disclosure here.
+// deleteCarHandler takes a listing off the market. Only the original seller
+// may delete it. Returns 403 Forbidden (not 404) so the client knows the car
+// exists but they don't own it — this is intentional information disclosure
+// here.
+//
+// By default this is a soft delete: the listing moves to statusDeleted with
+// a DeletedAt timestamp (409 if it's already deleted). It stays out of
+// searches, the sold archive and stats, can be restored within
+// restoreGraceWindow, and is then removed by the purge job. ?hard=true
+// removes it and everything attached to it for good.
+//
+// With ?dry_run=1 every check runs as normal but nothing is changed; the
+// response lists the IDs that would have been deleted.
 func deleteCarHandler(w http.ResponseWriter, r *http.Request) {
 	claims := r.Context().Value(ctxKey("claims")).(*Claims)
 
 	id, err := parseCarID(r.URL.Path)
 	if err != nil {
-		respond(w, http.StatusBadRequest, nil, "invalid car id")
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
 		return
 	}
 
@@ -151,24 +652,550 @@ func deleteCarHandler(w http.ResponseWriter, r *http.Request) {
 
 	car, ok := carStore[id]
 	if !ok {
-		respond(w, http.StatusNotFound, nil, "car not found")
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
 		return
 	}
 
 	if car.Seller != claims.Username {
 		// 403 Forbidden — authenticated but not authorised
-		respond(w, http.StatusForbidden, nil, "you can only delete your own listings")
+		respondError(w, http.StatusForbidden, errCodeForbidden, "you can only delete your own listings")
+		return
+	}
+
+	hard := isTruthy(r.URL.Query().Get("hard"))
+	if !hard && car.Status == statusDeleted {
+		respondError(w, http.StatusConflict, errCodeConflict, "listing is already deleted")
+		return
+	}
+
+	if isDryRun(r) {
+		respond(w, http.StatusOK, map[string]interface{}{
+			"dry_run":      true,
+			"hard":         hard,
+			"would_delete": []int{id},
+		})
+		return
+	}
+
+	if !hard {
+		car.Status = statusDeleted
+		car.DeletedAt = time.Now().Format(time.RFC3339)
+		car.BoostedUntil = ""
+		car.ReservedBy, car.ReservedUntil = "", ""
+		carStore[id] = car
+		markCarsDirty()
+		respond(w, http.StatusOK, map[string]interface{}{
+			"message": "listing deleted (restorable for " + restoreGraceWindow.String() + ")",
+			"listing": liveViews(car),
+		})
+		return
+	}
+
+	purgeCar(car)
+	markCarsDirty()
+
+	respond(w, http.StatusOK, map[string]string{"message": "listing deleted"})
+}
+
+// liveListingCount counts the listings that take up a slot under
+// maxListings: everything but soft-deleted ones, so deleting a listing frees
+// its slot straight away rather than after the purge. The caller must hold
+// storeMu.
+func liveListingCount() int {
+	n := 0
+	for _, car := range carStore {
+		if car.Status != statusDeleted {
+			n++
+		}
+	}
+	return n
+}
+
+// purgeCar removes a listing and everything attached to it. The caller must
+// hold storeMu for writing.
+func purgeCar(car CarListing) {
+	delete(carStore, car.ID)
+	delete(vinIndex, car.VIN)
+	deleteViews(car.ID)
+
+	commentsMu.Lock()
+	delete(carComments, car.ID)
+	commentsMu.Unlock()
+
+	offersMu.Lock()
+	delete(carOffers, car.ID)
+	offersMu.Unlock()
+
+	imageHashesMu.Lock()
+	delete(imageHashes, car.ID)
+	imageHashesMu.Unlock()
+}
+
+// ─── GET /api/models ──────────────────────────────────────────────────────────
+
+// modelsHandler returns the distinct models in stock for a given make, sorted
+// alphabetically. Used for cascading make → model dropdowns.
+//
+// Query params:
+//
+//	make — required, matched case-insensitively
+func modelsHandler(w http.ResponseWriter, r *http.Request) {
+	makeF := strings.TrimSpace(r.URL.Query().Get("make"))
+	if makeF == "" {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "make is required")
 		return
 	}
 
-	delete(carStore, id)
-	respond(w, http.StatusOK, map[string]string{"message": "listing deleted"}, "")
+	storeMu.RLock()
+	seen := map[string]bool{}
+	models := []string{} // never nil, so unknown makes encode as []
+	for _, car := range carStore {
+		if car.Status == statusDeleted || !strings.EqualFold(car.Make, makeF) || seen[car.Model] {
+			continue
+		}
+		seen[car.Model] = true
+		models = append(models, car.Model)
+	}
+	storeMu.RUnlock()
+
+	sort.Strings(models)
+
+	respondCached(w, r, map[string]interface{}{
+		"make":   makeF,
+		"models": models,
+	})
 }
 
 // ─── Helper ───────────────────────────────────────────────────────────────────
 
+// hotScore blends popularity and freshness into a single ranking score.
+// Views are log-scaled so a handful of viral listings can't dominate, and the
+// recency term halves every hotHalfLifeDays, so both a brand-new car and a
+// very popular older one rank well.
+func hotScore(car CarListing, now time.Time) float64 {
+	ageDays := now.Sub(listedTime(car)).Hours() / 24
+	if ageDays < 0 {
+		ageDays = 0
+	}
+	popularity := math.Log1p(float64(car.Views))
+	recency := math.Exp2(-ageDays / hotHalfLifeDays)
+	return hotViewsWeight*popularity + hotRecencyWeight*recency
+}
+
+// listingQuality scores how well documented a listing is, 0–100, so buyers
+// can find complete listings and sellers know what to improve. Points come
+// from a real photo, a gallery, a description (scaled by length), the
+// optional spec fields, and an edit or listing within qualityFreshWithin;
+// the weights are in config and sum to 100.
+func listingQuality(car CarListing, now time.Time) int {
+	score := 0.0
+	if hasRealPhoto(car.ImageURL) {
+		score += qualityPhotoWeight
+	}
+	if len(car.Images) >= qualityGalleryMin {
+		score += qualityGalleryWeight
+	}
+	descLen := float64(len([]rune(car.Description)))
+	score += qualityDescriptionWeight * math.Min(descLen/qualityDescriptionFull, 1)
+
+	specs := []bool{car.Mileage > 0, car.FuelType != "", car.Transmission != "",
+		car.Condition != "", car.VIN != "", car.Horsepower > 0}
+	filled := 0
+	for _, ok := range specs {
+		if ok {
+			filled++
+		}
+	}
+	score += qualitySpecWeight * float64(filled) / float64(len(specs))
+
+	last := listedTime(car)
+	if updated, err := time.Parse(time.RFC3339, car.UpdatedAt); err == nil && updated.After(last) {
+		last = updated
+	}
+	if now.Sub(last) <= qualityFreshWithin {
+		score += qualityFreshWeight
+	}
+	return int(math.Round(score))
+}
+
+// sanitizeText trims user-supplied free text, strips control characters
+// (keeping newlines and tabs) and truncates it to max characters.
+// HTML escaping is left to the renderer so the stored text stays verbatim.
+func sanitizeText(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r != '\n' && r != '\t' && unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(s))
+	if runes := []rune(s); len(runes) > max {
+		s = string(runes[:max])
+	}
+	return s
+}
+
+// emptyResultStatus resolves the status for a list request that matched
+// nothing: the ?empty= param ("404" or "200") if given, else the
+// emptyResultNotFound config.
+func emptyResultStatus(param string) int {
+	switch param {
+	case "404":
+		return http.StatusNotFound
+	case "200":
+		return http.StatusOK
+	}
+	if emptyResultNotFound {
+		return http.StatusNotFound
+	}
+	return http.StatusOK
+}
+
+// priceTolerance widens price filter bounds, by an absolute amount or by a
+// percentage of each bound.
+type priceTolerance struct {
+	amount  float64
+	percent bool
+}
+
+// parsePriceTolerance parses "500" or "5%". Empty means no tolerance;
+// negative or non-numeric values are an error.
+func parsePriceTolerance(raw string) (priceTolerance, error) {
+	if raw == "" {
+		return priceTolerance{}, nil
+	}
+	t := priceTolerance{}
+	if strings.HasSuffix(raw, "%") {
+		t.percent = true
+		raw = strings.TrimSuffix(raw, "%")
+	}
+	amount, err := strconv.ParseFloat(raw, 64)
+	if err != nil || amount < 0 || math.IsInf(amount, 0) {
+		return priceTolerance{}, errors.New(`price_tolerance must be a non-negative amount or percentage, e.g. 500 or 5%`)
+	}
+	t.amount = amount
+	return t, nil
+}
+
+// widen applies the tolerance to min/max price bounds. Unset (zero) bounds
+// stay unset, and the lower bound never drops below zero.
+func (t priceTolerance) widen(minP, maxP float64) (float64, float64) {
+	by := func(bound float64) float64 {
+		if t.percent {
+			return bound * t.amount / 100
+		}
+		return t.amount
+	}
+	if minP > 0 {
+		minP = math.Max(0, minP-by(minP))
+	}
+	if maxP > 0 {
+		maxP += by(maxP)
+	}
+	return minP, maxP
+}
+
+// parsePagination reads limit/offset query params, defaulting to
+// defaultPageLimit and capping at maxPageLimit. A limit below 1, a negative
+// offset or a non-numeric value is rejected rather than silently clamped.
+func parsePagination(q url.Values) (limit, offset int, err error) {
+	limit = defaultPageLimit
+	if raw := q.Get("limit"); raw != "" {
+		if limit, err = strconv.Atoi(raw); err != nil || limit < 1 {
+			return 0, 0, errors.New("limit must be a positive integer")
+		}
+		if limit > maxPageLimit {
+			limit = maxPageLimit
+		}
+	}
+	if raw := q.Get("offset"); raw != "" {
+		if offset, err = strconv.Atoi(raw); err != nil || offset < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+	}
+	return limit, offset, nil
+}
+
+// paginate returns the [offset, offset+limit) window of items along with
+// metadata describing it. Out-of-range offsets yield an empty, non-nil page.
+func paginate[T any](items []T, limit, offset int) ([]T, PageMeta) {
+	meta := PageMeta{Total: len(items), Limit: limit, Offset: offset}
+	if offset >= len(items) {
+		return []T{}, meta
+	}
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+	meta.HasMore = end < len(items)
+	return append([]T{}, items[offset:end]...), meta
+}
+
+// hasRealPhoto reports whether url is set and isn't a known placeholder image.
+func hasRealPhoto(url string) bool {
+	lower := strings.ToLower(strings.TrimSpace(url))
+	if lower == "" {
+		return false
+	}
+	for _, p := range placeholderImageMarkers {
+		if strings.Contains(lower, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// isDryRun reports whether the request asked for a dry run (?dry_run=1 or true).
+func isDryRun(r *http.Request) bool {
+	return isTruthy(r.URL.Query().Get("dry_run"))
+}
+
+// isTruthy reports whether a boolean query param is set ("1", "true", …).
+func isTruthy(v string) bool {
+	b, _ := strconv.ParseBool(v)
+	return b
+}
+
+// validateListing checks a listing against the rules enforced when it's
+// created and returns every field that breaks one (nil if none do). It
+// doesn't modify car, so it also serves to audit listings already in the
+// store.
+func validateListing(car CarListing) []ValidationError {
+	errs := validateVehicle(car.Make, car.Year, car.Mileage, car.FuelType, car.Condition)
+	if strings.TrimSpace(car.Model) == "" {
+		errs = append(errs, ValidationError{"model", "model is required"})
+	}
+	if car.Price <= 0 {
+		errs = append(errs, ValidationError{"price", "price must be positive"})
+	}
+	if car.Horsepower < 0 {
+		errs = append(errs, ValidationError{"horsepower", "horsepower must be positive"})
+	}
+	// A lone image_url becomes the gallery (see normalizeImages), so it's
+	// checked the same way
+	gallery := car.Images
+	if len(gallery) == 0 && car.ImageURL != "" {
+		gallery = []string{car.ImageURL}
+	}
+	if err := validateImages(gallery); err != nil {
+		errs = append(errs, ValidationError{"images", err.Error()})
+	}
+	photo := ""
+	if len(gallery) > 0 {
+		photo = gallery[0]
+	}
+	if requireListingPhoto && !hasRealPhoto(photo) {
+		errs = append(errs, ValidationError{"image_url", "a listing photo is required"})
+	}
+	if car.VIN != "" {
+		if err := validateVIN(normalizeVIN(car.VIN)); err != nil {
+			errs = append(errs, ValidationError{"vin", err.Error()})
+		}
+	}
+	return errs
+}
+
+// validateVehicle checks the fields listings and valuation requests share.
+// Fuel type and condition are optional but must be known values when set.
+func validateVehicle(brand string, year, mileage int, fuel, condition string) []ValidationError {
+	var errs []ValidationError
+	if strings.TrimSpace(brand) == "" {
+		errs = append(errs, ValidationError{"make", "make is required"})
+	}
+	if maxYear := time.Now().Year() + 1; year < minModelYear || year > maxYear {
+		errs = append(errs, ValidationError{"year", fmt.Sprintf("year must be between %d and %d", minModelYear, maxYear)})
+	}
+	if mileage < 0 {
+		errs = append(errs, ValidationError{"mileage", "mileage must not be negative"})
+	}
+	for _, f := range []struct {
+		field, name, value string
+		allowed            []string
+	}{
+		{"fuel_type", "fuel type", fuel, fuelTypes},
+		{"condition", "condition", condition, conditions},
+	} {
+		if f.value != "" && !slices.Contains(f.allowed, strings.ToLower(f.value)) {
+			errs = append(errs, ValidationError{f.field, fmt.Sprintf("unknown %s %q (expected one of %s)",
+				f.name, f.value, strings.Join(f.allowed, ", "))})
+		}
+	}
+	return errs
+}
+
+// validateImages checks a listing's gallery: at most maxListingImages
+// entries, each an absolute http(s) URL or a photo uploaded to this server
+// (/uploads/{file}).
+func validateImages(images []string) error {
+	if len(images) > maxListingImages {
+		return fmt.Errorf("at most %d images are allowed", maxListingImages)
+	}
+	for _, raw := range images {
+		if isUploadPath(raw) {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("image %q is not a valid http or https URL", raw)
+		}
+	}
+	return nil
+}
+
+// isUploadPath reports whether raw is the server-relative URL of an uploaded
+// photo: /uploads/ followed by a single clean file name.
+func isUploadPath(raw string) bool {
+	name, ok := strings.CutPrefix(raw, "/uploads/")
+	return ok && name != "" && !strings.ContainsAny(name, "/\\") && path.Clean(raw) == raw
+}
+
+// normalizeImages keeps ImageURL and Images consistent: ImageURL is the
+// first gallery image, a lone ImageURL becomes a one-image gallery, and an
+// empty gallery is [] rather than null.
+func normalizeImages(car *CarListing) {
+	switch {
+	case len(car.Images) > 0:
+		car.ImageURL = car.Images[0]
+	case car.ImageURL != "":
+		car.Images = []string{car.ImageURL}
+	default:
+		car.Images = []string{}
+	}
+}
+
+// setViews creates or resets the view counter for a listing.
+func setViews(id, n int) {
+	v := int64(n)
+	viewsMu.Lock()
+	carViews[id] = &v
+	viewsMu.Unlock()
+}
+
+// deleteViews drops the view counter and history of a deleted listing. The
+// counter must go first: recordView checks for it while holding
+// viewHistoryMu, so a racing view either lands before the history is
+// dropped or isn't recorded at all.
+func deleteViews(id int) {
+	viewsMu.Lock()
+	delete(carViews, id)
+	viewsMu.Unlock()
+
+	viewHistoryMu.Lock()
+	delete(viewHistory, id)
+	viewHistoryMu.Unlock()
+}
+
+// incrementViews atomically bumps a listing's view count and returns the new
+// value. Reports false when the listing has no counter (it was deleted), in
+// which case nothing is counted.
+func incrementViews(id int) (int, bool) {
+	viewsMu.RLock()
+	counter, ok := carViews[id]
+	viewsMu.RUnlock()
+	if !ok {
+		return 0, false
+	}
+	return int(atomic.AddInt64(counter, 1)), true
+}
+
+// liveViews returns car with Views set from its counter.
+func liveViews(car CarListing) CarListing {
+	viewsMu.RLock()
+	counter, ok := carViews[car.ID]
+	viewsMu.RUnlock()
+	if ok {
+		car.Views = int(atomic.LoadInt64(counter))
+	}
+	return car
+}
+
+// setPricePerHP recomputes the enthusiast-segment price-per-horsepower
+// metric (to the cent). Listings without a horsepower figure get none.
+func setPricePerHP(car *CarListing) {
+	car.PricePerHP = 0
+	if car.Horsepower > 0 {
+		car.PricePerHP = math.Round(car.Price/float64(car.Horsepower)*100) / 100
+	}
+}
+
+// normalizeVIN uppercases a VIN and trims surrounding whitespace.
+func normalizeVIN(vin string) string {
+	return strings.ToUpper(strings.TrimSpace(vin))
+}
+
+// validateVIN checks the ISO 3779 format of a normalized VIN: 17 characters
+// of A–Z and 0–9, excluding I, O and Q (too easily confused with 1 and 0).
+// The check digit isn't verified, since only North American VINs use one.
+func validateVIN(vin string) error {
+	if len(vin) != 17 {
+		return errors.New("vin must be 17 characters")
+	}
+	for _, c := range vin {
+		if !(c >= 'A' && c <= 'Z' || c >= '0' && c <= '9') || c == 'I' || c == 'O' || c == 'Q' {
+			return fmt.Errorf("vin contains invalid character %q", c)
+		}
+	}
+	return nil
+}
+
+// isBoosted reports whether car has a boost that hasn't expired at now.
+// Expiry is checked on read, so no job is needed to clear old boosts.
+func isBoosted(car CarListing, now time.Time) bool {
+	if car.BoostedUntil == "" {
+		return false
+	}
+	until, err := time.Parse(time.RFC3339, car.BoostedUntil)
+	return err == nil && now.Before(until)
+}
+
+// isReserved reports whether car is held by a buyer's reservation at now.
+// Like boosts, reservations expire on read: once ReservedUntil passes the car
+// is available again without any cleanup.
+func isReserved(car CarListing, now time.Time) bool {
+	if car.ReservedUntil == "" {
+		return false
+	}
+	until, err := time.Parse(time.RFC3339, car.ReservedUntil)
+	return err == nil && now.Before(until)
+}
+
+// compareBool orders false before true, in the style of cmp.Compare.
+func compareBool(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return 1
+	default:
+		return -1
+	}
+}
+
+// listedTime parses a listing's RFC3339 ListedAt timestamp.
+// Malformed values return the zero time so they sort as oldest.
+func listedTime(car CarListing) time.Time {
+	t, err := time.Parse(time.RFC3339, car.ListedAt)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
 // parseCarID strips the /api/cars/ prefix and parses the remaining ID.
+// Any trailing action segment (/api/cars/{id}/relist) is ignored.
 func parseCarID(path string) (int, error) {
 	raw := strings.TrimPrefix(path, "/api/cars/")
+	if i := strings.IndexByte(raw, '/'); i >= 0 {
+		raw = raw[:i]
+	}
 	return strconv.Atoi(raw)
 }
+
+// carAction returns the action segment of /api/cars/{id}/{action},
+// or "" for a plain /api/cars/{id} path.
+func carAction(path string) string {
+	raw := strings.TrimPrefix(path, "/api/cars/")
+	if i := strings.IndexByte(raw, '/'); i >= 0 {
+		return raw[i+1:]
+	}
+	return ""
+}
@@ -0,0 +1,791 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useStore swaps in a car store holding just cars, with empty side stores,
+// for the duration of the test. Cars without an ID are numbered from 1;
+// a missing status, seller or listing time defaults to active, "demo" and
+// now. Returns the cars as stored.
+func useStore(t *testing.T, cars ...CarListing) []CarListing {
+	t.Helper()
+	savedCars, savedVINs, savedViews, savedHistory := carStore, vinIndex, carViews, viewHistory
+	savedComments, savedOffers, savedHashes, savedLinks := carComments, carOffers, imageHashes, shareLinks
+	savedID := lastCarID.Load()
+	t.Cleanup(func() {
+		carStore, vinIndex, carViews, viewHistory = savedCars, savedVINs, savedViews, savedHistory
+		carComments, carOffers, imageHashes, shareLinks = savedComments, savedOffers, savedHashes, savedLinks
+		lastCarID.Store(savedID)
+		clearValuationCache()
+	})
+
+	carStore = make(map[int]CarListing)
+	vinIndex = make(map[string]int)
+	carViews = make(map[int]*int64)
+	viewHistory = make(map[int][]time.Time)
+	carComments = make(map[int][]Comment)
+	carOffers = make(map[int][]Offer)
+	imageHashes = make(map[int]map[string]uint64)
+	shareLinks = make(map[string]shareLink)
+	lastCarID.Store(0)
+	clearValuationCache()
+
+	stored := make([]CarListing, 0, len(cars))
+	for _, car := range cars {
+		if car.ID == 0 {
+			car.ID = nextCarID()
+		} else if int64(car.ID) > lastCarID.Load() {
+			lastCarID.Store(int64(car.ID))
+		}
+		if car.Status == "" {
+			car.Status = statusActive
+		}
+		if car.Seller == "" {
+			car.Seller = "demo"
+		}
+		if car.ListedAt == "" {
+			car.ListedAt = time.Now().Format(time.RFC3339)
+		}
+		normalizeImages(&car)
+		carStore[car.ID] = car
+		if car.VIN != "" {
+			vinIndex[car.VIN] = car.ID
+		}
+		setViews(car.ID, car.Views)
+		stored = append(stored, car)
+	}
+	return stored
+}
+
+func TestModelsHandlerDistinct(t *testing.T) {
+	useStore(t,
+		CarListing{Make: "Porsche", Model: "911"},
+		CarListing{Make: "porsche", Model: "Cayman"},
+		CarListing{Make: "Porsche", Model: "911"},
+		CarListing{Make: "Porsche", Model: "Taycan", Status: statusDeleted},
+		CarListing{Make: "BMW", Model: "M3"},
+	)
+
+	rec := httptest.NewRecorder()
+	modelsHandler(rec, httptest.NewRequest("GET", "/api/models?make=PORSCHE", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var got struct {
+		Models []string `json:"models"`
+	}
+	decodeData(t, rec, &got)
+	if want := []string{"911", "Cayman"}; !reflect.DeepEqual(got.Models, want) {
+		t.Errorf("models = %q, want %q", got.Models, want)
+	}
+
+	rec = httptest.NewRecorder()
+	modelsHandler(rec, httptest.NewRequest("GET", "/api/models?make=Lada", nil))
+	decodeData(t, rec, &got)
+	if got.Models == nil || len(got.Models) != 0 {
+		t.Errorf("unknown make: models = %#v, want []", got.Models)
+	}
+
+	rec = httptest.NewRecorder()
+	modelsHandler(rec, httptest.NewRequest("GET", "/api/models", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("missing make: status = %d, want 400", rec.Code)
+	}
+}
+
+// listCars calls GET /api/cars with query and returns the listing IDs in
+// response order.
+func listCars(t *testing.T, query string) []int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	getCarsHandler(rec, asUser(httptest.NewRequest("GET", "/api/cars?"+query, nil), "seller"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /api/cars?%s = %d: %s", query, rec.Code, rec.Body.String())
+	}
+	var got struct {
+		Listings []CarListing `json:"listings"`
+	}
+	decodeData(t, rec, &got)
+	ids := make([]int, len(got.Listings))
+	for i, car := range got.Listings {
+		ids[i] = car.ID
+	}
+	return ids
+}
+
+func TestEqualPriceTieBreak(t *testing.T) {
+	now := time.Now()
+	at := func(d time.Duration) string { return now.Add(-d).Format(time.RFC3339) }
+	useStore(t,
+		CarListing{ID: 1, Price: 50000, ListedAt: at(72 * time.Hour)},
+		CarListing{ID: 2, Price: 50000, ListedAt: at(time.Hour)},
+		CarListing{ID: 3, Price: 40000, ListedAt: at(24 * time.Hour)},
+		CarListing{ID: 4, Price: 50000, ListedAt: at(24 * time.Hour)},
+		CarListing{ID: 5, Price: 50000, ListedAt: at(24 * time.Hour)},
+	)
+
+	// Equal prices: newest first, then ascending ID between same-time listings
+	want := []int{3, 2, 4, 5, 1}
+	for i := 0; i < 5; i++ { // map iteration order must not leak through
+		if got := listCars(t, "sort=price_asc"); !reflect.DeepEqual(got, want) {
+			t.Fatalf("sort=price_asc = %v, want %v", got, want)
+		}
+	}
+
+	a := CarListing{ID: 1, ListedAt: at(48 * time.Hour)}
+	b := CarListing{ID: 2, ListedAt: at(time.Hour)}
+	if !tieBreakLess(b, a) || tieBreakLess(a, b) {
+		t.Errorf("tieBreakLess should put the newer listing first")
+	}
+}
+
+func TestHotSortFavoursNewAndPopular(t *testing.T) {
+	now := time.Now()
+	daysAgo := func(d int) string { return now.Add(-time.Duration(d) * 24 * time.Hour).Format(time.RFC3339) }
+	useStore(t,
+		CarListing{ID: 1, ListedAt: daysAgo(20), Views: 20},   // middling on both
+		CarListing{ID: 2, ListedAt: daysAgo(0), Views: 0},     // brand new
+		CarListing{ID: 3, ListedAt: daysAgo(90), Views: 2},    // stale and ignored
+		CarListing{ID: 4, ListedAt: daysAgo(60), Views: 5000}, // old but popular
+	)
+
+	got := listCars(t, "sort=hot")
+	top := map[int]bool{got[0]: true, got[1]: true}
+	if !top[2] || !top[4] {
+		t.Errorf("sort=hot = %v, want the new car (2) and the popular car (4) first", got)
+	}
+	if got[3] != 3 {
+		t.Errorf("sort=hot = %v, want the stale car (3) last", got)
+	}
+
+	fresh := CarListing{ListedAt: daysAgo(0)}
+	week := CarListing{ListedAt: daysAgo(int(hotHalfLifeDays))}
+	gain := hotScore(fresh, now) - hotScore(week, now)
+	if want := hotRecencyWeight / 2; gain < want-0.01 || gain > want+0.01 {
+		t.Errorf("recency after one half-life dropped by %.3f, want %.3f", gain, want)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	items := []int{1, 2, 3, 4, 5}
+	tests := []struct {
+		name          string
+		limit, offset int
+		want          []int
+		hasMore       bool
+	}{
+		{"first page", 2, 0, []int{1, 2}, true},
+		{"middle page", 2, 2, []int{3, 4}, true},
+		{"last partial page", 2, 4, []int{5}, false},
+		{"exact final page", 5, 0, []int{1, 2, 3, 4, 5}, false},
+		{"limit past end", 10, 3, []int{4, 5}, false},
+		{"offset at end", 2, 5, []int{}, false},
+		{"offset past end", 2, 50, []int{}, false},
+		{"zero limit", 0, 1, []int{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			page, meta := paginate(items, tt.limit, tt.offset)
+			if page == nil || !reflect.DeepEqual(page, tt.want) {
+				t.Errorf("page = %#v, want %#v", page, tt.want)
+			}
+			want := PageMeta{Total: 5, Limit: tt.limit, Offset: tt.offset, HasMore: tt.hasMore}
+			if meta != want {
+				t.Errorf("meta = %+v, want %+v", meta, want)
+			}
+		})
+	}
+
+	// The page is a copy, so callers can't write through to the source
+	page, _ := paginate(items, 2, 0)
+	page[0] = 99
+	if items[0] != 1 {
+		t.Errorf("paginate aliased its input")
+	}
+
+	if page, meta := paginate([]string(nil), 20, 0); page == nil || len(page) != 0 || meta.Total != 0 {
+		t.Errorf("nil input: page %#v meta %+v, want empty non-nil page", page, meta)
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		query         string
+		limit, offset int
+		wantErr       bool
+	}{
+		{"", defaultPageLimit, 0, false},
+		{"limit=5&offset=10", 5, 10, false},
+		{"limit=0", 0, 0, true},
+		{"limit=100000", maxPageLimit, 0, false},
+		{"limit=-1", 0, 0, true},
+		{"offset=-1", 0, 0, true},
+		{"limit=ten", 0, 0, true},
+		{"offset=1.5", 0, 0, true},
+	}
+	for _, tt := range tests {
+		q, _ := url.ParseQuery(tt.query)
+		limit, offset, err := parsePagination(q)
+		if (err != nil) != tt.wantErr || limit != tt.limit || offset != tt.offset {
+			t.Errorf("parsePagination(%q) = %d, %d, %v; want %d, %d, error %v",
+				tt.query, limit, offset, err, tt.limit, tt.offset, tt.wantErr)
+		}
+	}
+}
+
+func TestDeleteDryRunLeavesStoreUnchanged(t *testing.T) {
+	useStore(t,
+		CarListing{ID: 1, Seller: "alice", Views: 12},
+		CarListing{ID: 2, Seller: "bob"},
+	)
+	carComments[1] = []Comment{{ID: 1, CarID: 1, Author: "bob", Text: "Still available?"}}
+	before := make(map[int]CarListing, len(carStore))
+	for id, car := range carStore {
+		before[id] = car
+	}
+
+	del := func(target, user string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		deleteCarHandler(rec, asUser(httptest.NewRequest("DELETE", target, nil), user))
+		return rec
+	}
+
+	for _, target := range []string{"/api/cars/1?dry_run=1", "/api/cars/1?dry_run=true&hard=true"} {
+		rec := del(target, "alice")
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s = %d: %s", target, rec.Code, rec.Body.String())
+		}
+		var got struct {
+			DryRun      bool  `json:"dry_run"`
+			WouldDelete []int `json:"would_delete"`
+		}
+		decodeData(t, rec, &got)
+		if !got.DryRun || !reflect.DeepEqual(got.WouldDelete, []int{1}) {
+			t.Errorf("%s: got %+v, want dry_run with would_delete [1]", target, got)
+		}
+	}
+
+	// The usual checks still apply
+	if rec := del("/api/cars/2?dry_run=1", "alice"); rec.Code != http.StatusForbidden {
+		t.Errorf("dry run on someone else's listing = %d, want 403", rec.Code)
+	}
+	if rec := del("/api/cars/99?dry_run=1", "alice"); rec.Code != http.StatusNotFound {
+		t.Errorf("dry run on a missing listing = %d, want 404", rec.Code)
+	}
+
+	if !reflect.DeepEqual(carStore, before) {
+		t.Errorf("store changed by dry runs:\n got %+v\nwant %+v", carStore, before)
+	}
+	if len(carComments[1]) != 1 || carViews[1] == nil || *carViews[1] != 12 {
+		t.Errorf("dry run touched the comments or view counter of car 1")
+	}
+
+	// Without the flag the delete goes ahead
+	if rec := del("/api/cars/1", "alice"); rec.Code != http.StatusOK {
+		t.Fatalf("delete = %d: %s", rec.Code, rec.Body.String())
+	}
+	if carStore[1].Status != statusDeleted {
+		t.Errorf("status after delete = %q, want deleted", carStore[1].Status)
+	}
+}
+
+// addCar posts car to addCarHandler as user.
+func addCar(t *testing.T, user string, car CarListing) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	addCarHandler(rec, asUser(jsonRequest(t, "POST", "/api/cars", car), user))
+	return rec
+}
+
+// fieldErrors returns the fields named in a 422 validation response.
+func fieldErrors(t *testing.T, rec *httptest.ResponseRecorder) []string {
+	t.Helper()
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want 422: %s", rec.Code, rec.Body.String())
+	}
+	var got struct {
+		Errors []ValidationError `json:"errors"`
+	}
+	decodeData(t, rec, &got)
+	fields := make([]string, len(got.Errors))
+	for i, e := range got.Errors {
+		fields[i] = e.Field
+	}
+	return fields
+}
+
+func TestListingPhotoRequirement(t *testing.T) {
+	useStore(t)
+	saved := requireListingPhoto
+	t.Cleanup(func() { requireListingPhoto = saved })
+	car := CarListing{Make: "Mazda", Model: "MX-5", Year: 2019, Price: 24000, Mileage: 30000}
+
+	requireListingPhoto = false
+	if rec := addCar(t, "alice", car); rec.Code != http.StatusCreated {
+		t.Fatalf("flag off, no photo: status = %d: %s", rec.Code, rec.Body.String())
+	}
+
+	requireListingPhoto = true
+	placeholder := car
+	placeholder.ImageURL = "https://via.placeholder.com/640x480"
+	for name, c := range map[string]CarListing{"no photo": car, "placeholder": placeholder} {
+		fields := fieldErrors(t, addCar(t, "alice", c))
+		if !reflect.DeepEqual(fields, []string{"image_url"}) {
+			t.Errorf("%s: error fields = %v, want [image_url]", name, fields)
+		}
+	}
+
+	withPhoto := car
+	withPhoto.Images = []string{"/uploads/mx5.jpg"}
+	if rec := addCar(t, "alice", withPhoto); rec.Code != http.StatusCreated {
+		t.Errorf("flag on, real photo: status = %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestListingCap(t *testing.T) {
+	useStore(t, CarListing{ID: 1, Seller: "alice"}, CarListing{ID: 2, Seller: "bob"})
+	saved := maxListings
+	t.Cleanup(func() { maxListings = saved })
+	maxListings = 3
+	car := CarListing{Make: "Mazda", Model: "MX-5", Year: 2019, Price: 24000, Mileage: 30000}
+
+	if rec := addCar(t, "alice", car); rec.Code != http.StatusCreated {
+		t.Fatalf("third listing = %d: %s", rec.Code, rec.Body.String())
+	}
+	rec := addCar(t, "alice", car)
+	if rec.Code != http.StatusInsufficientStorage || !strings.Contains(rec.Body.String(), "listing limit reached (3)") {
+		t.Fatalf("past the cap = %d %s, want 507 listing limit reached", rec.Code, rec.Body.String())
+	}
+
+	// A soft delete frees the slot straight away
+	del := httptest.NewRecorder()
+	deleteCarHandler(del, asUser(httptest.NewRequest("DELETE", "/api/cars/1", nil), "alice"))
+	if del.Code != http.StatusOK {
+		t.Fatalf("delete = %d: %s", del.Code, del.Body.String())
+	}
+	if rec := addCar(t, "bob", car); rec.Code != http.StatusCreated {
+		t.Errorf("after a delete = %d, want 201", rec.Code)
+	}
+	if rec := addCar(t, "bob", car); rec.Code != http.StatusInsufficientStorage {
+		t.Errorf("full again = %d, want 507", rec.Code)
+	}
+
+	if rec := addCar(t, "seller", car); rec.Code != http.StatusCreated {
+		t.Errorf("admin past the cap = %d, want 201 (exempt)", rec.Code)
+	}
+}
+
+func TestPriceTolerance(t *testing.T) {
+	useStore(t,
+		CarListing{ID: 1, Price: 48000},
+		CarListing{ID: 2, Price: 50500}, // slightly over budget
+		CarListing{ID: 3, Price: 53000},
+		CarListing{ID: 4, Price: 45000},
+	)
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		{"max_price=50000", []int{4, 1}},
+		{"max_price=50000&price_tolerance=0", []int{4, 1}},
+		{"max_price=50000&price_tolerance=1000", []int{4, 1, 2}},
+		{"max_price=50000&price_tolerance=5%25", []int{4, 1, 2}},
+		{"max_price=50000&price_tolerance=10%25", []int{4, 1, 2, 3}},
+		{"min_price=47000&max_price=50000&price_tolerance=2500", []int{4, 1, 2}},
+	}
+	for _, tt := range tests {
+		if got := listCars(t, tt.query+"&sort=price_asc"); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ids %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	// The widened bounds are echoed back
+	rec := httptest.NewRecorder()
+	getCarsHandler(rec, asUser(httptest.NewRequest("GET", "/api/cars?min_price=40000&max_price=50000&price_tolerance=5%25", nil), "bob"))
+	var got struct {
+		Tolerance struct {
+			Value    float64 `json:"value"`
+			Percent  bool    `json:"percent"`
+			MinPrice float64 `json:"min_price"`
+			MaxPrice float64 `json:"max_price"`
+		} `json:"price_tolerance"`
+	}
+	decodeData(t, rec, &got)
+	if tol := got.Tolerance; tol.Value != 5 || !tol.Percent || tol.MinPrice != 38000 || tol.MaxPrice != 52500 {
+		t.Errorf("echoed tolerance = %+v, want 5%% widening to 38000–52500", tol)
+	}
+
+	for _, bad := range []string{"-5", "abc", "%25"} {
+		rec := httptest.NewRecorder()
+		getCarsHandler(rec, asUser(httptest.NewRequest("GET", "/api/cars?max_price=50000&price_tolerance="+bad, nil), "bob"))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("price_tolerance=%s: status = %d, want 400", bad, rec.Code)
+		}
+	}
+}
+
+func TestEnumFilterModes(t *testing.T) {
+	useStore(t,
+		CarListing{ID: 1, FuelType: "petrol", Condition: "used", Transmission: "manual"},
+		CarListing{ID: 2, FuelType: "electric", Condition: "new", Transmission: "automatic"},
+	)
+	saved := strictEnumFilters
+	t.Cleanup(func() { strictEnumFilters = saved })
+
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		getCarsHandler(rec, asUser(httptest.NewRequest("GET", "/api/cars?"+query, nil), "bob"))
+		return rec
+	}
+	invalid := []string{"fuel=banana", "condition=mint", "transmission=cvt"}
+
+	strictEnumFilters = false
+	for _, query := range invalid {
+		if got := listCars(t, query+"&empty=200"); len(got) != 0 {
+			t.Errorf("lenient %s: ids %v, want no matches", query, got)
+		}
+	}
+
+	strictEnumFilters = true
+	for _, query := range invalid {
+		rec := get(query)
+		if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "expected one of") {
+			t.Errorf("strict %s = %d %s, want 400 listing the allowed values", query, rec.Code, rec.Body.String())
+		}
+	}
+
+	// Known values, in any case, match the same in both modes
+	for _, strict := range []bool{false, true} {
+		strictEnumFilters = strict
+		if got := listCars(t, "fuel=Electric&condition=NEW&transmission=automatic"); !reflect.DeepEqual(got, []int{2}) {
+			t.Errorf("strict=%v: ids %v, want [2]", strict, got)
+		}
+	}
+}
+
+func TestEmptyResultResponse(t *testing.T) {
+	useStore(t, CarListing{ID: 1, Make: "BMW"})
+	saved := emptyResultNotFound
+	t.Cleanup(func() { emptyResultNotFound = saved })
+
+	get := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		getCarsHandler(rec, asUser(httptest.NewRequest("GET", "/api/cars?"+query, nil), "bob"))
+		return rec
+	}
+
+	tests := []struct {
+		notFound bool
+		query    string
+		want     int
+	}{
+		{false, "make=ferrari", http.StatusOK},
+		{false, "make=ferrari&empty=404", http.StatusNotFound},
+		{true, "make=ferrari", http.StatusNotFound},
+		{true, "make=ferrari&empty=200", http.StatusOK},
+		{true, "make=bmw&offset=5", http.StatusOK}, // matches, just not on this page
+	}
+	for _, tt := range tests {
+		emptyResultNotFound = tt.notFound
+		rec := get(tt.query)
+		if rec.Code != tt.want {
+			t.Errorf("emptyResultNotFound=%v %s: status = %d, want %d", tt.notFound, tt.query, rec.Code, tt.want)
+			continue
+		}
+		switch body := rec.Body.String(); {
+		case tt.want == http.StatusOK && !strings.Contains(body, `"listings":[]`):
+			t.Errorf("%s: body %s, want \"listings\":[]", tt.query, body)
+		case tt.want == http.StatusNotFound && !strings.Contains(body, errCodeNotFound):
+			t.Errorf("%s: body %s, want a not-found error", tt.query, body)
+		}
+	}
+}
+
+func TestEmptyListsEncodeAsArrays(t *testing.T) {
+	resetRateLimiter(t)
+	useStore(t, CarListing{ID: 1, Seller: "alice", Make: "BMW", Price: 90000})
+
+	tests := []struct {
+		target string
+		want   string
+	}{
+		{"/api/cars?make=ferrari", `"listings":[]`},
+		{"/api/sold-archive", `"listings":[]`},
+		{"/api/cars/in-budget?budget=100", `"listings":[]`},
+		{"/api/cars/1/comments", `"comments":[]`},
+		{"/api/cars/1/offers", `"offers":[]`},
+		{"/api/models?make=ferrari", `"models":[]`},
+	}
+	for _, tt := range tests {
+		rec := serveAPI(t, "GET", tt.target, "alice")
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: status = %d: %s", tt.target, rec.Code, rec.Body.String())
+			continue
+		}
+		if body := rec.Body.String(); !strings.Contains(body, tt.want) || strings.Contains(body, "null") {
+			t.Errorf("%s: body %s, want %s", tt.target, body, tt.want)
+		}
+	}
+
+	if ids := similarImages(0, 0); ids == nil || len(ids) != 0 {
+		t.Errorf("similarImages on an empty store = %#v, want []int{}", ids)
+	}
+}
+
+func TestNextCarIDConcurrentUnique(t *testing.T) {
+	useStore(t, CarListing{ID: 7})
+
+	const workers, perWorker = 16, 200
+	ids := make(chan int, workers*perWorker)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < perWorker; i++ {
+				ids <- nextCarID()
+			}
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := make(map[int]bool)
+	for id := range ids {
+		if id <= 7 {
+			t.Errorf("id %d reuses a seeded ID", id)
+		}
+		if seen[id] {
+			t.Errorf("id %d allocated twice", id)
+		}
+		seen[id] = true
+	}
+	if len(seen) != workers*perWorker {
+		t.Errorf("got %d distinct IDs, want %d", len(seen), workers*perWorker)
+	}
+
+	// Concurrent adds through the handler land under distinct IDs too
+	car := CarListing{Make: "Mazda", Model: "MX-5", Year: 2019, Price: 24000, Mileage: 30000}
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rec := addCar(t, "alice", car); rec.Code != http.StatusCreated {
+				t.Errorf("add = %d: %s", rec.Code, rec.Body.String())
+			}
+		}()
+	}
+	wg.Wait()
+	if len(carStore) != 21 {
+		t.Errorf("store holds %d listings, want 21", len(carStore))
+	}
+}
+
+func TestPricePerHP(t *testing.T) {
+	useStore(t)
+	car := CarListing{Make: "McLaren", Model: "765LT", Year: 2021, Price: 358000, Mileage: 8200, Horsepower: 755}
+
+	rec := addCar(t, "alice", car)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("add = %d: %s", rec.Code, rec.Body.String())
+	}
+	var added CarListing
+	decodeData(t, rec, &added)
+	if added.PricePerHP != 474.17 {
+		t.Errorf("price_per_hp = %v, want 474.17", added.PricePerHP)
+	}
+
+	car.Horsepower = 0
+	rec = addCar(t, "alice", car)
+	if rec.Code != http.StatusCreated || strings.Contains(rec.Body.String(), "price_per_hp") {
+		t.Errorf("no horsepower: %d %s, want 201 without price_per_hp", rec.Code, rec.Body.String())
+	}
+	car.Horsepower = -1
+	if fields := fieldErrors(t, addCar(t, "alice", car)); !reflect.DeepEqual(fields, []string{"horsepower"}) {
+		t.Errorf("negative horsepower: error fields = %v, want [horsepower]", fields)
+	}
+
+	// Price and horsepower edits both recompute it
+	patch := func(body interface{}) CarListing {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		updateCarHandler(rec, asUser(jsonRequest(t, "PATCH", "/api/cars/"+strconv.Itoa(added.ID), body), "alice"))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("patch %v = %d: %s", body, rec.Code, rec.Body.String())
+		}
+		var got CarListing
+		decodeData(t, rec, &got)
+		return got
+	}
+	if got := patch(map[string]float64{"price": 302000}); got.PricePerHP != 400 {
+		t.Errorf("after price edit: price_per_hp = %v, want 400", got.PricePerHP)
+	}
+	if got := patch(map[string]int{"horsepower": 604}); got.PricePerHP != 500 {
+		t.Errorf("after horsepower edit: price_per_hp = %v, want 500", got.PricePerHP)
+	}
+}
+
+func TestUpdateDeletedCarConflict(t *testing.T) {
+	useStore(t, CarListing{ID: 1, Seller: "alice", Price: 50000, Status: statusDeleted, DeletedAt: time.Now().Format(time.RFC3339)})
+
+	rec := httptest.NewRecorder()
+	updateCarHandler(rec, asUser(jsonRequest(t, "PATCH", "/api/cars/1", map[string]float64{"price": 1}), "alice"))
+	if rec.Code != http.StatusConflict {
+		t.Errorf("patch deleted listing = %d, want 409: %s", rec.Code, rec.Body.String())
+	}
+	if carStore[1].Price != 50000 {
+		t.Errorf("rejected patch changed the price to %v", carStore[1].Price)
+	}
+}
+
+func TestPricePerHPSort(t *testing.T) {
+	useStore(t,
+		CarListing{ID: 1, Price: 300000, Horsepower: 500}, // 600
+		CarListing{ID: 2, Price: 90000},                   // no horsepower
+		CarListing{ID: 3, Price: 120000, Horsepower: 600}, // 200
+		CarListing{ID: 4, Price: 200000, Horsepower: 500}, // 400
+	)
+	for id, car := range carStore {
+		setPricePerHP(&car)
+		carStore[id] = car
+	}
+
+	if got, want := listCars(t, "sort=price_per_hp_asc"), []int{3, 4, 1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("price_per_hp_asc order = %v, want %v", got, want)
+	}
+}
+
+func TestListingQuality(t *testing.T) {
+	now := time.Now()
+	recent := now.Add(-time.Hour).Format(time.RFC3339)
+	stale := now.Add(-qualityFreshWithin - 24*time.Hour).Format(time.RFC3339)
+
+	complete := CarListing{
+		ID: 1, Make: "BMW", Model: "M5 CS", Year: 2022, Price: 140000, Mileage: 9000,
+		FuelType: "petrol", Transmission: "automatic", Condition: "used",
+		VIN: "WBS83CH00NCH12345", Horsepower: 627, ListedAt: recent,
+		ImageURL:    "/uploads/a.jpg",
+		Images:      []string{"/uploads/a.jpg", "/uploads/b.jpg", "/uploads/c.jpg"},
+		Description: strings.Repeat("Carbon ceramics, full history. ", 10),
+	}
+	sparse := CarListing{ID: 2, Make: "BMW", Model: "M5", Year: 2018, Price: 60000, ListedAt: stale,
+		ImageURL: "https://via.placeholder.com/640x480"}
+	partial := complete
+	partial.ID, partial.Images, partial.Description = 3, partial.Images[:1], "Clean."
+
+	if got := listingQuality(complete, now); got != 100 {
+		t.Errorf("complete listing scored %d, want 100", got)
+	}
+	if got := listingQuality(sparse, now); got != 0 {
+		t.Errorf("sparse listing scored %d, want 0", got)
+	}
+	q := listingQuality(partial, now)
+	if q <= listingQuality(sparse, now) || q >= listingQuality(complete, now) {
+		t.Errorf("partial listing scored %d, want between sparse and complete", q)
+	}
+
+	// A recent edit counts as fresh even on an old listing
+	edited := complete
+	edited.ListedAt, edited.UpdatedAt = stale, recent
+	if got := listingQuality(edited, now); got != 100 {
+		t.Errorf("recently edited listing scored %d, want 100", got)
+	}
+	edited.UpdatedAt = ""
+	if got, want := listingQuality(edited, now), 100-int(qualityFreshWeight); got != want {
+		t.Errorf("stale listing scored %d, want %d", got, want)
+	}
+
+	useStore(t, sparse, complete, partial)
+	if got, want := listCars(t, "sort=quality_desc"), []int{1, 3, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("quality_desc order = %v, want %v", got, want)
+	}
+}
+
+// inBudget calls GET /api/cars/in-budget?query and returns the listing IDs
+// in response order.
+func inBudget(t *testing.T, query string) []int {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	inBudgetHandler(rec, asUser(httptest.NewRequest("GET", "/api/cars/in-budget?"+query, nil), "bob"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("in-budget?%s = %d: %s", query, rec.Code, rec.Body.String())
+	}
+	var got struct {
+		Listings []CarListing `json:"listings"`
+	}
+	decodeData(t, rec, &got)
+	ids := make([]int, len(got.Listings))
+	for i, car := range got.Listings {
+		ids[i] = car.ID
+	}
+	return ids
+}
+
+func TestInBudgetFlexAndOrder(t *testing.T) {
+	useStore(t,
+		CarListing{ID: 1, Make: "BMW", Price: 60000},
+		CarListing{ID: 2, Make: "Audi", Price: 79000},
+		CarListing{ID: 3, Make: "Porsche", Price: 80000},
+		CarListing{ID: 4, Make: "Audi", Price: 85000},  // 6.25% over
+		CarListing{ID: 5, Make: "BMW", Price: 95000},   // 18.75% over
+		CarListing{ID: 6, Make: "Lexus", Price: 82000}, // 2.5% over
+		CarListing{ID: 7, Make: "Audi", Price: 70000, Status: statusSold},
+		CarListing{ID: 8, Make: "Audi", Price: 75000, Status: statusArchived},
+	)
+
+	tests := []struct {
+		query string
+		want  []int
+	}{
+		// Closest to the budget first; nothing over it without flex
+		{"budget=80000", []int{3, 2, 1}},
+		// Within flex the over-budget cars follow, least over first
+		{"budget=80000&flex=10", []int{3, 2, 1, 6, 4}},
+		{"budget=80000&flex=10%25", []int{3, 2, 1, 6, 4}},
+		{"budget=80000&flex=20", []int{3, 2, 1, 6, 4, 5}},
+		{"budget=80000&flex=10&limit=2", []int{3, 2}},
+		{"budget=50000", []int{}},
+	}
+	for _, tt := range tests {
+		if got := inBudget(t, tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ids = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	for _, query := range []string{"", "budget=0", "budget=abc", "budget=80000&flex=-5", "budget=80000&limit=0"} {
+		rec := httptest.NewRecorder()
+		inBudgetHandler(rec, asUser(httptest.NewRequest("GET", "/api/cars/in-budget?"+query, nil), "bob"))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%q: status = %d, want 400", query, rec.Code)
+		}
+	}
+}
+
+func TestInBudgetMixesMakes(t *testing.T) {
+	var cars []CarListing
+	for i := 1; i <= inBudgetMaxPerMake+2; i++ {
+		cars = append(cars, CarListing{ID: i, Make: "Audi", Price: float64(70000 + i*100)})
+	}
+	cars = append(cars, CarListing{ID: 99, Make: "Mazda", Price: 30000})
+	useStore(t, cars...)
+
+	// The best-fitting Audis, up to the per-make cap, then the Mazda
+	var want []int
+	for id := inBudgetMaxPerMake + 2; len(want) < inBudgetMaxPerMake; id-- {
+		want = append(want, id)
+	}
+	want = append(want, 99)
+	if got := inBudget(t, "budget=80000"); !reflect.DeepEqual(got, want) {
+		t.Errorf("ids = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ─── GET /api/cars/{id}/comments ──────────────────────────────────────────────
+
+// listCommentsHandler returns a listing's discussion thread, oldest first.
+//
+// Query params:
+//
+//	limit  — page size (default 20, max 100)
+//	offset — number of comments to skip
+func listCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseCarID(r.URL.Path)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
+		return
+	}
+	limit, offset, err := parsePagination(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, err.Error())
+		return
+	}
+
+	if !carExists(id) {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+
+	commentsMu.RLock()
+	page, meta := paginate(carComments[id], limit, offset)
+	commentsMu.RUnlock()
+
+	respond(w, http.StatusOK, map[string]interface{}{
+		"comments":   page,
+		"pagination": meta,
+	})
+}
+
+// ─── POST /api/cars/{id}/comments ─────────────────────────────────────────────
+
+// addCommentHandler appends a comment to a listing's thread.
+// The author is taken from the JWT claims. Once a thread reaches
+// maxCommentsPerCar the oldest comment is dropped.
+//
+// Request body: { "text": "Is the service history available?" }
+func addCommentHandler(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+
+	id, err := parseCarID(r.URL.Path)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
+		return
+	}
+
+	var body struct {
+		Text string `json:"text"`
+	}
+	if status, err := decodeJSON(w, r, &body, maxBodyBytes); err != nil {
+		respondError(w, status, errCodeInvalidBody, err.Error())
+		return
+	}
+	text := sanitizeText(body.Text, maxCommentLength)
+	if text == "" {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "comment text is required")
+		return
+	}
+
+	if !carExists(id) {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+
+	commentsMu.Lock()
+	c := Comment{
+		ID:        nextCommentID,
+		CarID:     id,
+		Author:    claims.Username,
+		Text:      text,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	nextCommentID++
+	thread := append(carComments[id], c)
+	if len(thread) > maxCommentsPerCar {
+		thread = thread[len(thread)-maxCommentsPerCar:]
+	}
+	carComments[id] = thread
+	markCarsDirty()
+	commentsMu.Unlock()
+
+	respond(w, http.StatusCreated, c)
+}
+
+// ─── DELETE /api/cars/{id}/comments/{commentID} ───────────────────────────────
+
+// deleteCommentHandler removes a comment. Only the listing's seller or an
+// admin may moderate the thread.
+func deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+
+	id, err := parseCarID(r.URL.Path)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
+		return
+	}
+	commentID, err := strconv.Atoi(strings.TrimPrefix(carAction(r.URL.Path), "comments/"))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid comment id")
+		return
+	}
+
+	storeMu.RLock()
+	car, ok := carStore[id]
+	storeMu.RUnlock()
+	if !ok {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+	if car.Seller != claims.Username && !isAdmin(claims.Username) {
+		respondError(w, http.StatusForbidden, errCodeForbidden, "only the seller can delete comments")
+		return
+	}
+
+	commentsMu.Lock()
+	defer commentsMu.Unlock()
+
+	thread := carComments[id]
+	for i, c := range thread {
+		if c.ID == commentID {
+			carComments[id] = append(thread[:i:i], thread[i+1:]...)
+			markCarsDirty()
+			respond(w, http.StatusOK, map[string]string{"message": "comment deleted"})
+			return
+		}
+	}
+	respondError(w, http.StatusNotFound, errCodeNotFound, "comment not found")
+}
+
+// carExists reports whether a listing with the given ID is in the store and
+// not soft-deleted; a deleted listing is treated as missing, as in getCar.
+func carExists(id int) bool {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	car, ok := carStore[id]
+	return ok && car.Status != statusDeleted
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func postComment(t *testing.T, carID, user, text string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	r := jsonRequest(t, "POST", "/api/cars/"+carID+"/comments", map[string]string{"text": text})
+	addCommentHandler(rec, asUser(r, user))
+	return rec
+}
+
+func listComments(t *testing.T, carID string) []Comment {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	listCommentsHandler(rec, asUser(httptest.NewRequest("GET", "/api/cars/"+carID+"/comments", nil), "bob"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("list comments = %d: %s", rec.Code, rec.Body.String())
+	}
+	var got struct {
+		Comments []Comment `json:"comments"`
+	}
+	decodeData(t, rec, &got)
+	return got.Comments
+}
+
+func TestCommentThread(t *testing.T) {
+	useStore(t, CarListing{ID: 1, Seller: "alice"})
+
+	first := postComment(t, "1", "bob", "  Is the service history available?  ")
+	if first.Code != http.StatusCreated {
+		t.Fatalf("post = %d: %s", first.Code, first.Body.String())
+	}
+	var c Comment
+	decodeData(t, first, &c)
+	if c.Author != "bob" || c.Text != "Is the service history available?" || c.CarID != 1 {
+		t.Errorf("comment = %+v, want bob's trimmed text on car 1", c)
+	}
+	postComment(t, "1", "carol", "Any accident damage?")
+
+	thread := listComments(t, "1")
+	if len(thread) != 2 || thread[0].Author != "bob" || thread[1].Author != "carol" {
+		t.Fatalf("thread = %+v, want bob then carol", thread)
+	}
+
+	if rec := postComment(t, "1", "bob", "   "); rec.Code != http.StatusBadRequest {
+		t.Errorf("empty text: status = %d, want 400", rec.Code)
+	}
+	if rec := postComment(t, "99", "bob", "hello"); rec.Code != http.StatusNotFound {
+		t.Errorf("missing car: status = %d, want 404", rec.Code)
+	}
+}
+
+func TestDeleteCommentOwnerOnly(t *testing.T) {
+	useStore(t, CarListing{ID: 1, Seller: "alice"})
+	rec := postComment(t, "1", "bob", "Lowball offer incoming")
+	var c Comment
+	decodeData(t, rec, &c)
+	path := "/api/cars/1/comments/" + strconv.Itoa(c.ID)
+
+	del := func(user string) int {
+		rec := httptest.NewRecorder()
+		deleteCommentHandler(rec, asUser(httptest.NewRequest("DELETE", path, nil), user))
+		return rec.Code
+	}
+
+	// The comment's author isn't the listing owner, so can't moderate
+	if code := del("bob"); code != http.StatusForbidden {
+		t.Errorf("author delete = %d, want 403", code)
+	}
+	if code := del("alice"); code != http.StatusOK {
+		t.Errorf("owner delete = %d, want 200", code)
+	}
+	if thread := listComments(t, "1"); len(thread) != 0 {
+		t.Errorf("thread after delete = %+v, want empty", thread)
+	}
+	if code := del("alice"); code != http.StatusNotFound {
+		t.Errorf("second delete = %d, want 404", code)
+	}
+}
+
+func TestDeletedListingTreatedAsMissing(t *testing.T) {
+	useStore(t, CarListing{ID: 1, Seller: "alice"})
+	var link struct {
+		Code string `json:"code"`
+	}
+	decodeData(t, share("1"), &link) // shared while still live
+	car := carStore[1]
+	car.Status, car.DeletedAt = statusDeleted, time.Now().Format(time.RFC3339)
+	carStore[1] = car
+
+	trend := httptest.NewRecorder()
+	viewTrendHandler(trend, asUser(httptest.NewRequest("GET", "/api/cars/1/view-trend", nil), "bob"))
+	list := httptest.NewRecorder()
+	listCommentsHandler(list, asUser(httptest.NewRequest("GET", "/api/cars/1/comments", nil), "bob"))
+
+	for name, rec := range map[string]*httptest.ResponseRecorder{
+		"post comment":   postComment(t, "1", "bob", "Still available?"),
+		"list comments":  list,
+		"view trend":     trend,
+		"share":          share("1"),
+		"share redirect": resolve(link.Code),
+	} {
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s on a deleted listing = %d, want 404: %s", name, rec.Code, rec.Body.String())
+		}
+	}
+	if len(carComments[1]) != 0 {
+		t.Errorf("comment was stored on a deleted listing")
+	}
+}
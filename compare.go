@@ -0,0 +1,101 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+)
+
+// ─── GET /api/cars/diff ───────────────────────────────────────────────────────
+
+// fieldDiff is one spec field on which two listings differ.
+type fieldDiff struct {
+	Field string      `json:"field"`
+	A     interface{} `json:"a"`
+	B     interface{} `json:"b"`
+}
+
+// dealRating compares a listing's asking price with the valuation engine's
+// estimate; a price_to_value below 1 means it's priced under market.
+type dealRating struct {
+	EstimatedValue float64 `json:"estimated_value"`
+	PriceToValue   float64 `json:"price_to_value"`
+}
+
+// carDiffHandler compares two listings field by field for the compare UI.
+// Deltas are b minus a, so a positive price delta means b costs more. The
+// verdict names the better deal by asking price relative to the valuation
+// estimate ("a", "b" or "even" when within diffEvenMargin of each other).
+// A soft-deleted listing counts as missing (404).
+//
+// Query params:
+//
+//	a, b — required listing IDs
+func carDiffHandler(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	idA, errA := strconv.Atoi(q.Get("a"))
+	idB, errB := strconv.Atoi(q.Get("b"))
+	if errA != nil || errB != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "a and b must be car ids")
+		return
+	}
+
+	storeMu.RLock()
+	a, okA := carStore[idA]
+	b, okB := carStore[idB]
+	storeMu.RUnlock()
+	if !okA || !okB || a.Status == statusDeleted || b.Status == statusDeleted {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+	a, b = liveViews(a), liveViews(b)
+
+	differences := []fieldDiff{}
+	for _, f := range []struct {
+		name string
+		a, b string
+	}{
+		{"make", a.Make, b.Make},
+		{"model", a.Model, b.Model},
+		{"fuel_type", a.FuelType, b.FuelType},
+		{"transmission", a.Transmission, b.Transmission},
+		{"condition", a.Condition, b.Condition},
+	} {
+		if f.a != f.b {
+			differences = append(differences, fieldDiff{Field: f.name, A: f.a, B: f.b})
+		}
+	}
+
+	dealA, dealB := rateDeal(a), rateDeal(b)
+	verdict := "even"
+	if diff := dealA.PriceToValue - dealB.PriceToValue; math.Abs(diff) > diffEvenMargin {
+		verdict = "a"
+		if diff > 0 {
+			verdict = "b"
+		}
+	}
+
+	respond(w, http.StatusOK, map[string]interface{}{
+		"a": a,
+		"b": b,
+		"deltas": map[string]interface{}{
+			"price":      b.Price - a.Price,
+			"mileage":    b.Mileage - a.Mileage,
+			"year":       b.Year - a.Year,
+			"horsepower": b.Horsepower - a.Horsepower,
+		},
+		"differences": differences,
+		"deal":        map[string]dealRating{"a": dealA, "b": dealB},
+		"verdict":     verdict,
+	})
+}
+
+// rateDeal values car with the pricing engine and relates its asking price
+// to the estimate.
+func rateDeal(car CarListing) dealRating {
+	value, _ := cachedValuationFor(valuationRequestFor(car))
+	return dealRating{
+		EstimatedValue: roundPrice(value),
+		PriceToValue:   math.Round(car.Price/value*1000) / 1000,
+	}
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// carDiff is the data of a GET /api/cars/diff response.
+type carDiff struct {
+	Deltas struct {
+		Price      float64 `json:"price"`
+		Mileage    int     `json:"mileage"`
+		Year       int     `json:"year"`
+		Horsepower int     `json:"horsepower"`
+	} `json:"deltas"`
+	Differences []fieldDiff           `json:"differences"`
+	Deal        map[string]dealRating `json:"deal"`
+	Verdict     string                `json:"verdict"`
+}
+
+// diffCars calls carDiffHandler with query.
+func diffCars(t *testing.T, query string) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	carDiffHandler(rec, asUser(httptest.NewRequest("GET", "/api/cars/diff?"+query, nil), "bob"))
+	return rec
+}
+
+// pricedAt returns car with its asking price set to ratio × its valuation.
+func pricedAt(car CarListing, ratio float64) CarListing {
+	value, _ := calculateValue(valuationRequestFor(car))
+	car.Price = value * ratio
+	return car
+}
+
+func TestCarDiffDeltasAndVerdict(t *testing.T) {
+	t.Cleanup(clearValuationCache)
+	a := pricedAt(CarListing{ID: 1, Make: "BMW", Model: "M3", Year: 2021, Mileage: 20000, Horsepower: 473,
+		FuelType: "petrol", Transmission: "manual", Condition: "used"}, 0.85)
+	b := pricedAt(CarListing{ID: 2, Make: "BMW", Model: "M5", Year: 2018, Mileage: 45000, Horsepower: 600,
+		FuelType: "petrol", Transmission: "automatic", Condition: "used"}, 1.10)
+	useStore(t, a, b)
+
+	rec := diffCars(t, "a=1&b=2")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var got carDiff
+	decodeData(t, rec, &got)
+
+	if got.Deltas.Price != b.Price-a.Price || got.Deltas.Mileage != 25000 || got.Deltas.Year != -3 || got.Deltas.Horsepower != 127 {
+		t.Errorf("deltas = %+v, want b minus a", got.Deltas)
+	}
+	want := []fieldDiff{{"model", "M3", "M5"}, {"transmission", "manual", "automatic"}}
+	if !reflect.DeepEqual(got.Differences, want) {
+		t.Errorf("differences = %v, want %v", got.Differences, want)
+	}
+	if got.Deal["a"].PriceToValue != 0.85 || got.Deal["b"].PriceToValue != 1.1 {
+		t.Errorf("deal = %+v, want price_to_value 0.85 and 1.1", got.Deal)
+	}
+	if got.Verdict != "a" {
+		t.Errorf("verdict = %q, want a (priced under its valuation)", got.Verdict)
+	}
+
+	// Swapping the order flips every sign and the verdict
+	decodeData(t, diffCars(t, "a=2&b=1"), &got)
+	if got.Deltas.Year != 3 || got.Deltas.Mileage != -25000 || got.Verdict != "b" {
+		t.Errorf("swapped: deltas %+v verdict %q, want negated and b", got.Deltas, got.Verdict)
+	}
+}
+
+func TestCarDiffEvenAndErrors(t *testing.T) {
+	t.Cleanup(clearValuationCache)
+	car := CarListing{Make: "Audi", Model: "RS6", Year: 2020, Mileage: 30000, Condition: "used"}
+	a, b := pricedAt(car, 1.0), pricedAt(car, 1.0+diffEvenMargin/2)
+	a.ID, b.ID = 1, 2
+	deleted := pricedAt(car, 1.0)
+	deleted.ID, deleted.Status, deleted.DeletedAt = 3, statusDeleted, time.Now().Format(time.RFC3339)
+	useStore(t, a, b, deleted)
+
+	var got carDiff
+	decodeData(t, diffCars(t, "a=1&b=2"), &got)
+	if got.Verdict != "even" || len(got.Differences) != 0 {
+		t.Errorf("near-identical pair: verdict %q differences %v, want even and none", got.Verdict, got.Differences)
+	}
+
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"a=1&b=99", http.StatusNotFound},
+		{"a=99&b=1", http.StatusNotFound},
+		{"a=1&b=3", http.StatusNotFound},
+		{"a=3&b=1", http.StatusNotFound},
+		{"a=1", http.StatusBadRequest},
+		{"a=x&b=2", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := diffCars(t, tt.query); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.query, rec.Code, tt.want)
+		}
+	}
+}
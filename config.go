@@ -1,32 +1,580 @@
 package main
 
-import "time"
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // ─── Application Configuration ────────────────────────────────────────────────
-// All tuneable constants live here. Easy to swap to env vars later.
+// All tuneable constants live here. The deployment-specific ones are vars
+// that loadConfig can override from APEX_* environment variables.
 
-var jwtSecret = []byte("apex-motors-secret-change-in-production")
+// defaultJWTSecret is the development secret; refused when APEX_ENV=production.
+const defaultJWTSecret = "apex-motors-secret-change-in-production"
 
-const (
-	// Token lifetimes
+var (
+	jwtSecret = []byte(defaultJWTSecret) // APEX_JWT_SECRET
+
+	// Token lifetimes (APEX_ACCESS_TTL, APEX_REFRESH_TTL)
 	accessTokenTTL  = 15 * time.Minute
 	refreshTokenTTL = 7 * 24 * time.Hour
 
-	// Rate limiting: max requests per IP per minute on auth endpoints
-	rateLimitWindow = time.Minute
-	rateLimitMax    = 10
+	// Steady per-IP request rate on auth endpoints (APEX_RATE_LIMIT, given
+	// in requests per minute)
+	rateLimitRefillPerSec = 10.0 / 60
+
+	// Listen address (APEX_ADDR)
+	serverAddr = ":5001"
+
+	// Log output: "text" (key=value) or "json" (APEX_LOG_FORMAT)
+	logFormat = "text"
+
+	// JSON key style for request and response bodies: "snake" | "camel"
+	// (APEX_JSON_CASE)
+	jsonFieldCase = "snake"
+
+	// Analytics event stream (APEX_ANALYTICS=true) and where it goes:
+	// "stdout" or a file path (APEX_ANALYTICS_SINK)
+	analyticsEnabled = false
+	analyticsSink    = "stdout"
+
+	// JSON file the car store is saved to ("" disables persistence)
+	// (APEX_DATA_FILE)
+	dataFile = "data/cars.json"
+
+	// Optional JSON file of make → base price replacing the built-in
+	// valuation tiers (APEX_BASE_PRICES_FILE). With persistence enabled,
+	// admin updates are written back to it — or, when unset, to
+	// base-prices.json next to dataFile
+	basePricesFile = ""
+
+	// Directory holding uploaded listing photos, served under /uploads/
+	// (APEX_UPLOAD_DIR)
+	uploadDir = "static/uploads"
+
+	// Max concurrent in-flight requests per IP (0 disables the check)
+	// (APEX_MAX_CONCURRENT_PER_IP)
+	maxConcurrentPerIP = 0
+
+	// Maximum listings held in the store (0 = unlimited); admins are exempt
+	// (APEX_MAX_LISTINGS)
+	maxListings = 1000
+
+	// Valuations computed in parallel for one batch request
+	// (APEX_BATCH_WORKERS)
+	batchValuationWorkers = 4
+
+	// Public origin of this service, e.g. "https://apexmotors.example"
+	// (APEX_PUBLIC_URL). Used for absolute links in embeds shown on other
+	// sites; when empty it is derived from each request's scheme and host
+	publicBaseURL = ""
+
+	// How GET /api/cars treats an unknown fuel/condition/transmission filter:
+	// false (lenient) matches nothing, true (strict) returns 400
+	// (APEX_STRICT_FILTERS)
+	strictEnumFilters = false
+
+	// When true, GET /api/cars returns 404 instead of an empty list when no
+	// listing matches (clients can override per request with ?empty=)
+	// (APEX_EMPTY_NOT_FOUND)
+	emptyResultNotFound = false
+
+	// Request IDs: when true, a valid incoming X-Request-ID (or the trace ID
+	// of a W3C traceparent) is reused so logs correlate across services
+	// (APEX_TRUST_REQUEST_ID)
+	trustIncomingRequestID = true
+
+	// When true, new listings must include a real (non-placeholder) photo
+	// (APEX_REQUIRE_PHOTO=true)
+	requireListingPhoto = false
+
+	// Send Retry-After as an HTTP-date instead of delta-seconds
+	// (APEX_RETRY_AFTER_DATE=true)
+	retryAfterHTTPDate = false
+)
+
+const (
+	// Tolerated clock drift when checking a token's "iat" isn't in the future
+	maxClockSkew = 30 * time.Second
+
+	// Support impersonation tokens are deliberately short-lived
+	impersonationTTL = 5 * time.Minute
+
+	// Token buckets: how many requests may arrive at once before the steady
+	// refill rate applies, per IP (rateLimitRefillPerSec) and per user on
+	// expensive authenticated endpoints like valuation
+	rateLimitBurst            = 5
+	userRateLimitBurst        = 10
+	userRateLimitRefillPerSec = 30.0 / 60
+
+	// How often idle keys are swept out of the rate limiter
+	rateLimitSweepInterval = 5 * time.Minute
+
+	// Valuation confidence score: a recognised make plus four optional
+	// signals (mileage, condition, fuel type, transmission); sums to 1
+	confidenceMakeWeight  = 0.4
+	confidenceFieldWeight = 0.15
+
+	// ± range around the estimate for each confidence level
+	varianceHigh   = 0.05
+	varianceMedium = 0.07
+	varianceLow    = 0.12
+
+	// Valuation confidence drops to "low" when the matching base-price tier
+	// was last updated longer ago than this
+	basePriceStaleAfter = 180 * 24 * time.Hour
+
+	// Tie-break for listings equal on every sort key: "newest" | "oldest"
+	// (by ListedAt). Remaining ties fall back to ascending ID.
+	sortTieBreak = "newest"
+
+	// "hot" sort: score = views weight × ln(1+views) + recency weight × 2^(-age/half-life)
+	hotViewsWeight   = 1.0
+	hotRecencyWeight = 5.0
+	hotHalfLifeDays  = 7.0
+
+	// Listing quality score weights (sum to 100): a real photo, at least
+	// qualityGalleryMin images, a description (full marks at
+	// qualityDescriptionFull characters), the optional spec fields, and an
+	// edit or listing within qualityFreshWithin
+	qualityPhotoWeight       = 25.0
+	qualityGalleryWeight     = 10.0
+	qualityDescriptionWeight = 25.0
+	qualitySpecWeight        = 30.0
+	qualityFreshWeight       = 10.0
+	qualityGalleryMin        = 3
+	qualityDescriptionFull   = 200.0
+	qualityFreshWithin       = 30 * 24 * time.Hour
+
+	// Language used for valuation factors when the client asks for none we
+	// support (must have a bundle in valuationMessages)
+	defaultLanguage = "en"
+
+	// How long a cached valuation is reused before being recomputed
+	valuationCacheTTL = time.Hour
+
+	// Most valuations cached at once; keys are client input, so without a
+	// bound any caller could grow the cache indefinitely
+	valuationCacheMaxEntries = 10000
+
+	// Stats price_histogram bucket width, and where the open-ended top
+	// bucket starts
+	priceBucketSize = 50000.0
+	priceBucketMax  = 500000.0
+
+	// Days covered by stats listings_over_time by default, and the most a
+	// client may ask for with ?days=
+	statsDefaultDays = 30
+	statsMaxDays     = 365
+
+	// Listings stats cover unless ?scope= says otherwise: "active", "sold"
+	// or "all"
+	statsDefaultScope = "active"
+
+	// Valuation output rounds to the nearest 100 below roundFineBelow, 500
+	// below roundMediumBelow, and 1000 above
+	roundFineBelow   = 50000
+	roundMediumBelow = 200000
+
+	// Yearly value gain applied to appreciating classics in place of
+	// depreciation
+	appreciationRate = 0.03
+
+	// Cars older than this (years) listed as "new" are valued as used
+	newConditionMaxAge = 2
+
+	// Request body caps: single-object endpoints vs batch/import endpoints
+	maxBodyBytes  = 1 << 20 // 1 MiB
+	maxBatchBytes = 4 << 20 // 4 MiB
+	maxBatchItems = 100
+
+	// Maximum number of points in one valuation sensitivity curve
+	maxSensitivityPoints = 50
+
+	// Earliest model year accepted on listings and valuations; the latest is
+	// next calendar year, for early-release models
+	minModelYear = 1900
+
+	// Free-text limits (characters) and comment thread size per listing
+	maxDescriptionLength = 2000
+	maxCommentLength     = 1000
+	maxCommentsPerCar    = 200
+
+	// When true, offers at or above the asking price are rejected
+	// (the buyer should just pay the asking price instead)
+	offerMustBeBelowAsk = true
 
-	// Server settings
-	serverAddr     = ":5001"
+	// Most offers one listing can collect; further offers get 409
+	maxOffersPerCar = 100
+
+	// A legacy finance "apr" above this and below 1 could be a fraction or
+	// a percentage, so it's rejected in favour of apr_percent
+	aprAmbiguousAbove = 0.3
+
+	// How long one POST /api/cars/{id}/boost promotes a listing
+	boostDuration = 7 * 24 * time.Hour
+
+	// Lifetime of /s/{code} share links (0 = never expire)
+	shareLinkTTL = 30 * 24 * time.Hour
+
+	// Live (unexpired) share links one listing may have at a time
+	maxShareLinksPerCar = 20
+
+	// Per-listing view history for GET /api/cars/{id}/view-trend
+	maxViewHistory       = 5000
+	viewHistoryRetention = 30 * 24 * time.Hour
+
+	// Maximum events kept in a seller's /api/me/activity feed
+	maxActivityEvents = 500
+
+	// Listings need at least this many views to count as "most viewed"
+	minTrendingViews = 25
+
+	// Default number of listings returned by GET /api/new-arrivals and
+	// GET /api/cars/in-budget
+	newArrivalsLimit = 10
+
+	// GET /api/cars/diff calls two listings an even deal when their
+	// price-to-value ratios are within this of each other
+	diffEvenMargin = 0.02
+
+	// Most listings of one make in a GET /api/cars/in-budget result
+	inBudgetMaxPerMake = 3
+
+	// Pagination defaults for list endpoints
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+
+	// How a bare /api/cars/ (no ID) is handled:
+	// "list" serves the listing index, "redirect" 308s to /api/cars
+	bareCarsPathMode = "list"
+
+	// Serve index.html for unmatched non-API GET paths (client-side routing)
+	spaFallback = true
+
+	// Photo uploads: size cap, and duplicate detection — hashes within
+	// imageHashThreshold bits (of 64) count as the same photo.
+	// imageDuplicateMode: "warn" accepts with a warning, "reject" returns 409.
+	maxUploadBytes     = 10 << 20 // 10 MiB
+	imageHashThreshold = 6
+	imageDuplicateMode = "warn"
+
+	// Largest decoded photo accepted. Checked from the image header before
+	// decoding, since a tiny compressed file can declare enormous dimensions
+	maxImageDimension = 8000
+	maxImagePixels    = 40_000_000
+
+	// Bounds on the duplicate-photo scan so uploads stay fast on a large
+	// store: stop after maxSimilarImages matches or maxImageHashScan
+	// hashes checked (0 = no limit)
+	maxSimilarImages = 10
+	maxImageHashScan = 50000
+
+	// Maximum photos in a listing's gallery
+	maxListingImages = 20
+
+	// Emit a Server-Timing header with the handler duration on every response
+	serverTimingEnabled = true
+
+	// How long to batch car store changes before writing (0 = write after
+	// every change)
+	persistInterval = 2 * time.Second
+
+	// How long a buyer's reservation holds a listing before it's released
+	reservationHold = 48 * time.Hour
+
+	// How long a soft-deleted listing can be restored, and how often
+	// listings past that window are purged
+	restoreGraceWindow = 30 * 24 * time.Hour
+	purgeInterval      = time.Hour
+
+	// Backoff between retries after a failed persistence write
+	persistRetryBase = time.Second
+	persistRetryMax  = time.Minute
+
+	// Server timeouts
 	serverReadTTO  = 15 * time.Second
 	serverWriteTTO = 15 * time.Second
 	serverIdleTTO  = 60 * time.Second
 
-	// Demo credentials (hard-coded for portfolio demo purposes)
-	demoUsername = "seller"
-	demoPassword = "carmarket123"
+	// How long graceful shutdown waits for in-flight requests to finish
+	shutdownTimeout = 20 * time.Second
+
+	// Registration rules: usernames are 3–32 characters of [a-z0-9_.-]
+	minPasswordLength = 8
+	minUsernameLength = 3
+	maxUsernameLength = 32
+
+	// Demo account seeded into the user store.
+	// The password ("carmarket123") is stored only as a bcrypt hash.
+	demoUsername     = "seller"
+	demoPasswordHash = "$2a$10$Xi7FM5uxrI78mG3gTUxILeuWIAHSO5vQDDPX7ud.U/DbAQEkkGcDq"
 )
 
+// placeholderImageMarkers are substrings identifying stock "no photo" images
+// that don't satisfy requireListingPhoto.
+var placeholderImageMarkers = []string{"placeholder", "no-image", "noimage", "via.placeholder.com"}
+
+// adminUsernames lists the accounts allowed to call /api/admin routes.
+// The demo seller doubles as the operator account.
+var adminUsernames = []string{demoUsername}
+
+// apiKeys are the static API keys accepted by AuthMiddleware as an
+// alternative to a Bearer JWT, each mapped to a service account and role.
+// Empty by default, which disables API-key auth. Set APEX_API_KEYS to
+// comma-separated hash:username:role entries, hashing each key with e.g.
+// `printf %s "$KEY" | sha256sum`.
+//
+// Example:
+//
+//	{Hash: "9f86d0…", Username: "ci-bot", Role: "seller"}
+var apiKeys = []ServiceAPIKey{}
+
+// trustedProxies are the CIDRs of reverse proxies whose client-IP headers
+// are believed. Requests from anywhere else are identified by their socket
+// address, so clients can't spoof their IP past the rate limiter.
+var trustedProxies = []string{"127.0.0.0/8", "::1/128"}
+
+// trustedIPHeaders are the proxy headers consulted for the real client IP,
+// in priority order. "Forwarded" is parsed per RFC 7239.
+var trustedIPHeaders = []string{"X-Forwarded-For", "X-Real-IP", "Forwarded"}
+
 // allowedOrigins controls which origins the CORS middleware accepts.
-// In production, replace with your actual front-end domain.
+// In production, set APEX_ALLOWED_ORIGINS to your actual front-end domain(s).
 var allowedOrigins = []string{"http://localhost:5001"}
+
+// makeFuelAdjustments layers make-specific fuel adjustments onto the valuation
+// engine, e.g. faster depreciation for older diesels from makes phasing out ICE.
+// Empty by default, which leaves the standard fuel-type pricing untouched.
+//
+// Example:
+//
+//	{Make: "audi", FuelType: "diesel", MinAge: 5, Multiplier: 0.92, Reason: "ICE phase-out"}
+var makeFuelAdjustments = []MakeFuelAdjustment{}
+
+// appreciatingModels lists classics and limited models that gain value with
+// age; the valuation engine applies appreciationRate per year for them
+// instead of depreciation. Requests can also set "appreciating" directly.
+// Empty by default, so every car depreciates.
+//
+// Example:
+//
+//	{Make: "mclaren", Model: "765lt"},
+//	{Make: "porsche", Model: "911 gt3", Transmission: "manual"}
+var appreciatingModels = []AppreciatingModel{}
+
+// contentSecurityPolicy is sent with the HTML pages and static assets ("" to
+// omit it). The pages use inline scripts and styles, Google Fonts, and
+// listing photos from arbitrary https hosts; adjust when self-hosting the
+// demo on another origin or adding third-party assets.
+var contentSecurityPolicy = "default-src 'self'; " +
+	"script-src 'self' 'unsafe-inline'; " +
+	"style-src 'self' 'unsafe-inline' https://fonts.googleapis.com; " +
+	"font-src 'self' https://fonts.gstatic.com; " +
+	"img-src 'self' data: https:; " +
+	"connect-src 'self'; " +
+	"frame-ancestors 'none'"
+
+// embedCardStyle is the inline CSS applied to the outer element of the
+// /api/cars/{id}/embed snippet. Adjust to match a dealer's site.
+var embedCardStyle = template.CSS("max-width:360px;padding:16px;border:1px solid #ddd;" +
+	"border-radius:8px;font-family:Helvetica,Arial,sans-serif;color:#111;background:#fff")
+
+// loadConfig overrides the deployment settings above from the environment.
+// Unset variables keep their defaults; durations use time.ParseDuration
+// syntax ("15m", "168h") and APEX_ALLOWED_ORIGINS is comma-separated.
+// With APEX_ENV=production, leaving the default JWT secret is an error.
+func loadConfig() error {
+	var errs []error
+
+	if v := os.Getenv("APEX_JWT_SECRET"); v != "" {
+		jwtSecret = []byte(v)
+	}
+	if v := os.Getenv("APEX_ADDR"); v != "" {
+		serverAddr = v
+	}
+	if v := os.Getenv("APEX_LOG_FORMAT"); v != "" {
+		logFormat = v
+	}
+	if v := os.Getenv("APEX_JSON_CASE"); v != "" {
+		jsonFieldCase = v
+	}
+	if v := os.Getenv("APEX_ANALYTICS"); v != "" {
+		analyticsEnabled = isTruthy(v)
+	}
+	if v := os.Getenv("APEX_ANALYTICS_SINK"); v != "" {
+		analyticsSink = v
+	}
+	if v := os.Getenv("APEX_STRICT_FILTERS"); v != "" {
+		strictEnumFilters = isTruthy(v)
+	}
+	if v := os.Getenv("APEX_EMPTY_NOT_FOUND"); v != "" {
+		emptyResultNotFound = isTruthy(v)
+	}
+	if v := os.Getenv("APEX_TRUST_REQUEST_ID"); v != "" {
+		trustIncomingRequestID = isTruthy(v)
+	}
+	if v := os.Getenv("APEX_REQUIRE_PHOTO"); v != "" {
+		requireListingPhoto = isTruthy(v)
+	}
+	if v := os.Getenv("APEX_RETRY_AFTER_DATE"); v != "" {
+		retryAfterHTTPDate = isTruthy(v)
+	}
+	if v := os.Getenv("APEX_DATA_FILE"); v != "" {
+		dataFile = v
+	}
+	if v := os.Getenv("APEX_BASE_PRICES_FILE"); v != "" {
+		basePricesFile = v
+	}
+	if v := os.Getenv("APEX_UPLOAD_DIR"); v != "" {
+		uploadDir = v
+	}
+	for name, dst := range map[string]*time.Duration{
+		"APEX_ACCESS_TTL":  &accessTokenTTL,
+		"APEX_REFRESH_TTL": &refreshTokenTTL,
+	} {
+		if v := os.Getenv(name); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+				continue
+			}
+			*dst = d
+		}
+	}
+	if v := os.Getenv("APEX_RATE_LIMIT"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("APEX_RATE_LIMIT: %w", err))
+		} else {
+			rateLimitRefillPerSec = float64(n) / 60
+		}
+	}
+	if v := os.Getenv("APEX_API_KEYS"); v != "" {
+		apiKeys = nil
+		for _, entry := range strings.Split(v, ",") {
+			parts := strings.Split(strings.TrimSpace(entry), ":")
+			if len(parts) != 3 {
+				errs = append(errs, errors.New("APEX_API_KEYS: entries must be hash:username:role"))
+				continue
+			}
+			apiKeys = append(apiKeys, ServiceAPIKey{Hash: strings.ToLower(parts[0]), Username: parts[1], Role: parts[2]})
+		}
+	}
+	if v := os.Getenv("APEX_PUBLIC_URL"); v != "" {
+		publicBaseURL = strings.TrimSuffix(v, "/")
+	}
+	if v := os.Getenv("APEX_BATCH_WORKERS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("APEX_BATCH_WORKERS: %w", err))
+		} else {
+			batchValuationWorkers = n
+		}
+	}
+	if v := os.Getenv("APEX_MAX_CONCURRENT_PER_IP"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("APEX_MAX_CONCURRENT_PER_IP: %w", err))
+		} else {
+			maxConcurrentPerIP = n
+		}
+	}
+	if v := os.Getenv("APEX_MAX_LISTINGS"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("APEX_MAX_LISTINGS: %w", err))
+		} else {
+			maxListings = n
+		}
+	}
+	if v := os.Getenv("APEX_ALLOWED_ORIGINS"); v != "" {
+		allowedOrigins = nil
+		for _, origin := range strings.Split(v, ",") {
+			if origin = strings.TrimSpace(origin); origin != "" {
+				allowedOrigins = append(allowedOrigins, origin)
+			}
+		}
+	}
+
+	if os.Getenv("APEX_ENV") == "production" && string(jwtSecret) == defaultJWTSecret {
+		errs = append(errs, errors.New("APEX_JWT_SECRET must be set in production"))
+	}
+	return errors.Join(errs...)
+}
+
+// validateConfig checks invariants between config values so the server fails
+// fast at startup instead of running in a subtly broken state.
+func validateConfig() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(len(jwtSecret) >= 32, "JWT secret must be at least 32 bytes (got %d)", len(jwtSecret))
+	check(accessTokenTTL > 0 && accessTokenTTL < refreshTokenTTL,
+		"access token TTL (%v) must be positive and shorter than refresh TTL (%v)", accessTokenTTL, refreshTokenTTL)
+	check(maxClockSkew >= 0, "max clock skew must not be negative")
+	check(rateLimitRefillPerSec > 0 && userRateLimitRefillPerSec > 0, "rate limits must be greater than zero")
+	check(rateLimitBurst >= 1 && userRateLimitBurst >= 1, "rate limit bursts must be at least 1")
+	check(rateLimitSweepInterval > 0, "rate limit sweep interval must be positive")
+	check(maxConcurrentPerIP >= 0, "max concurrent requests per IP must not be negative")
+	check(len(allowedOrigins) > 0, `allowed origins must not be empty (use "*" to allow any origin)`)
+
+	for _, cidr := range trustedProxies {
+		_, _, err := net.ParseCIDR(cidr)
+		check(err == nil, "trusted proxy %q is not a valid CIDR", cidr)
+	}
+
+	_, _, err := net.SplitHostPort(serverAddr)
+	check(err == nil, "server address %q is not a valid host:port", serverAddr)
+
+	if publicBaseURL != "" {
+		u, err := url.Parse(publicBaseURL)
+		check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"public URL %q must be an absolute http(s) URL", publicBaseURL)
+	}
+
+	check(sortTieBreak == "newest" || sortTieBreak == "oldest", "unknown sort tie-break %q", sortTieBreak)
+	check(bareCarsPathMode == "list" || bareCarsPathMode == "redirect", "unknown bare /api/cars/ mode %q", bareCarsPathMode)
+	check(logFormat == "text" || logFormat == "json", "unknown log format %q", logFormat)
+	check(jsonFieldCase == "snake" || jsonFieldCase == "camel", "unknown JSON field case %q", jsonFieldCase)
+	check(imageDuplicateMode == "warn" || imageDuplicateMode == "reject", "unknown image duplicate mode %q", imageDuplicateMode)
+	check(valuationCacheTTL > 0, "valuation cache TTL must be positive")
+	check(valuationCacheMaxEntries >= 1, "valuation cache must hold at least one entry")
+	check(validStatsScope(statsDefaultScope), "unknown stats scope %q", statsDefaultScope)
+	seenKeys := map[string]bool{}
+	for _, k := range apiKeys {
+		hash, err := hex.DecodeString(k.Hash)
+		check(err == nil && len(hash) == sha256.Size, "API key hash for %q must be a hex SHA-256", k.Username)
+		check(validUsername(k.Username), "API key username %q is not a valid username", k.Username)
+		check(k.Role == "admin" || k.Role == "seller", "unknown API key role %q", k.Role)
+		check(!seenKeys[k.Hash], "duplicate API key hash for %q", k.Username)
+		seenKeys[k.Hash] = true
+	}
+	check(batchValuationWorkers >= 1, "batch valuation workers must be at least 1")
+	check(boostDuration > 0, "boost duration must be positive")
+	check(reservationHold > 0, "reservation hold must be positive")
+	check(qualityPhotoWeight+qualityGalleryWeight+qualityDescriptionWeight+qualitySpecWeight+qualityFreshWeight == 100,
+		"listing quality weights must sum to 100")
+	check(maxListings >= 0, "max listings must not be negative")
+	check(persistInterval >= 0, "persist interval must not be negative")
+	check(restoreGraceWindow > 0 && purgeInterval > 0, "restore grace window and purge interval must be positive")
+	check(maxShareLinksPerCar > 0, "max share links per car must be positive")
+	_, ok := valuationMessages[defaultLanguage]
+	check(ok, "no message bundle for default language %q", defaultLanguage)
+	check(defaultPageLimit > 0 && defaultPageLimit <= maxPageLimit, "default page limit must be between 1 and the max page limit")
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidateConfig(t *testing.T) {
+	if err := validateConfig(); err != nil {
+		t.Fatalf("default config: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		apply func()
+		want  []string
+	}{
+		{"short JWT secret", func() { jwtSecret = []byte("hunter2") }, []string{"JWT secret"}},
+		{"access TTL not below refresh TTL", func() {
+			accessTokenTTL, refreshTokenTTL = 2*time.Hour, time.Hour
+		}, []string{"access token TTL"}},
+		{"zero rate limit", func() { rateLimitRefillPerSec = 0 }, []string{"rate limits"}},
+		{"no allowed origins", func() { allowedOrigins = nil }, []string{"allowed origins"}},
+		{"bad bind address", func() { serverAddr = "5001" }, []string{"server address"}},
+		{"bad trusted proxy", func() { trustedProxies = []string{"10.0.0.0/33"} }, []string{"trusted proxy"}},
+		{"relative public URL", func() { publicBaseURL = "cars.example" }, []string{"public URL"}},
+		{"no batch workers", func() { batchValuationWorkers = 0 }, []string{"batch valuation workers"}},
+		{"several at once", func() {
+			jwtSecret = nil
+			serverAddr = ""
+		}, []string{"JWT secret", "server address"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			secret, access, refresh, refill := jwtSecret, accessTokenTTL, refreshTokenTTL, rateLimitRefillPerSec
+			origins, addr, proxies, base, workers := allowedOrigins, serverAddr, trustedProxies, publicBaseURL, batchValuationWorkers
+			t.Cleanup(func() {
+				jwtSecret, accessTokenTTL, refreshTokenTTL, rateLimitRefillPerSec = secret, access, refresh, refill
+				allowedOrigins, serverAddr, trustedProxies, publicBaseURL, batchValuationWorkers = origins, addr, proxies, base, workers
+			})
+
+			tt.apply()
+			err := validateConfig()
+			if err == nil {
+				t.Fatalf("validateConfig() = nil, want an error")
+			}
+			// Every problem is reported, not just the first
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("validateConfig() = %v, want it to mention %q", err, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ─── GET /api/cars/{id}/embed ─────────────────────────────────────────────────
+
+// embedTemplate renders a self-contained listing card with inline styles only,
+// so dealers can paste it into their own site or load it in an iframe.
+// html/template escapes every listing field, so seller-supplied text can't
+// inject markup or script into the host page.
+var embedTemplate = template.Must(template.New("embed").Parse(`<div style="{{.Style}}">
+  {{if .ImageURL}}<img src="{{.ImageURL}}" alt="{{.Car.Year}} {{.Car.Make}} {{.Car.Model}}" style="width:100%;display:block;border-radius:6px">{{end}}
+  <h3 style="margin:12px 0 4px">{{.Car.Year}} {{.Car.Make}} {{.Car.Model}}</h3>
+  <p style="margin:0 0 8px;font-size:20px;font-weight:bold">${{.Price}}</p>
+  <ul style="margin:0;padding-left:18px">
+    <li>{{.Mileage}} km</li>
+    <li>{{.Car.FuelType}} · {{.Car.Transmission}}</li>
+    <li>{{.Car.Condition}}</li>
+  </ul>
+</div>
+`))
+
+// embedCarHandler returns an HTML snippet for a single listing.
+// Public on purpose — the snippet is displayed on third-party sites that
+// have no JWT to send. Only active listings can be embedded; sold, archived
+// and deleted ones get 404 like a missing car.
+func embedCarHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseCarID(r.URL.Path)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
+		return
+	}
+
+	storeMu.RLock()
+	car, ok := carStore[id]
+	storeMu.RUnlock()
+	if !ok || car.Status != statusActive {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+
+	var buf bytes.Buffer
+	err = embedTemplate.Execute(&buf, map[string]interface{}{
+		"Car":      car,
+		"ImageURL": absoluteURL(r, car.ImageURL),
+		"Price":    groupThousands(int64(car.Price)),
+		"Mileage":  groupThousands(int64(car.Mileage)),
+		"Style":    embedCardStyle,
+	})
+	if err != nil {
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "failed to render embed")
+		return
+	}
+
+	// Dealers may load the snippet in an iframe
+	w.Header().Del("X-Frame-Options")
+	writeCached(w, r, "text/html; charset=utf-8", buf.Bytes())
+}
+
+// absoluteURL resolves a site-relative path such as "/uploads/7/a.jpg"
+// against publicBaseURL, or against the request's own scheme and host when
+// that is unset, so it still loads when the snippet sits on another site.
+// Absolute URLs and "" are returned unchanged.
+func absoluteURL(r *http.Request, path string) string {
+	if !strings.HasPrefix(path, "/") || strings.HasPrefix(path, "//") {
+		return path
+	}
+	if publicBaseURL != "" {
+		return publicBaseURL + path
+	}
+	return requestScheme(r) + "://" + r.Host + path
+}
+
+// requestScheme is "https" for TLS connections, or when a trusted proxy
+// says so in X-Forwarded-Proto, and "http" otherwise.
+func requestScheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	if isTrustedProxy(peer) && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		return "https"
+	}
+	return "http"
+}
+
+// groupThousands formats n with comma separators, e.g. 358000 → "358,000".
+func groupThousands(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	neg := n < 0
+	if neg {
+		s = s[1:]
+	}
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	if neg {
+		s = "-" + s
+	}
+	return s
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func embed(id string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	embedCarHandler(rec, httptest.NewRequest("GET", "http://dealer.example/api/cars/"+id+"/embed", nil))
+	return rec
+}
+
+func TestEmbedEscapesListing(t *testing.T) {
+	useStore(t, CarListing{
+		ID: 1, Make: "Porsche", Model: `<script>alert("x")</script>`, Year: 2020,
+		Price: 1234567, Mileage: 4200, ImageURL: "/uploads/abc.jpg",
+	})
+
+	rec := embed("1")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "$1,234,567") {
+		t.Errorf("price missing from embed:\n%s", body)
+	}
+	if strings.Contains(body, "<script>") {
+		t.Errorf("model was not escaped:\n%s", body)
+	}
+	if !strings.Contains(body, "&lt;script&gt;") {
+		t.Errorf("escaped model missing from embed:\n%s", body)
+	}
+	if !strings.Contains(body, `src="http://dealer.example/uploads/abc.jpg"`) {
+		t.Errorf("upload path was not made absolute:\n%s", body)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("Content-Type = %q, want text/html", ct)
+	}
+}
+
+func TestEmbedOnlyActiveListings(t *testing.T) {
+	useStore(t,
+		CarListing{ID: 1, Status: statusSold},
+		CarListing{ID: 2, Status: statusArchived},
+		CarListing{ID: 3, Status: statusDeleted},
+	)
+	for _, id := range []string{"1", "2", "3", "99"} {
+		if rec := embed(id); rec.Code != http.StatusNotFound {
+			t.Errorf("car %s: status = %d, want 404", id, rec.Code)
+		}
+	}
+}
+
+func TestGroupThousands(t *testing.T) {
+	for n, want := range map[int64]string{0: "0", 999: "999", 1000: "1,000", 358000: "358,000", -1234567: "-1,234,567"} {
+		if got := groupThousands(n); got != want {
+			t.Errorf("groupThousands(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
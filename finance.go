@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ─── POST /api/finance ────────────────────────────────────────────────────────
+
+// FinanceRequest is the input to the financing estimate.
+type FinanceRequest struct {
+	Price       float64  `json:"price"`
+	DownPayment float64  `json:"down_payment"`
+	APR         float64  `json:"apr"`         // legacy: 7.5 or 0.075 — both mean 7.5%
+	APRPercent  *float64 `json:"apr_percent"` // unambiguous: 0.5 means 0.5%
+	TermMonths  int      `json:"term_months"` // 1–600
+	Compounding string   `json:"compounding"` // monthly (default) | daily
+}
+
+// AmortizationRow is one month of a repayment schedule.
+type AmortizationRow struct {
+	Month     int     `json:"month"`
+	Payment   float64 `json:"payment"`
+	Principal float64 `json:"principal"`
+	Interest  float64 `json:"interest"`
+	Balance   float64 `json:"balance"`
+}
+
+// financeHandler estimates the monthly payment on a car loan.
+// With ?schedule=1 the full amortization schedule is included. Every amount
+// is rounded to the cent and the last payment absorbs the rounding drift so
+// the final balance is exactly zero.
+func financeHandler(w http.ResponseWriter, r *http.Request) {
+	var req FinanceRequest
+	if status, err := decodeJSON(w, r, &req, maxBodyBytes); err != nil {
+		respondError(w, status, errCodeInvalidBody, err.Error())
+		return
+	}
+
+	switch {
+	case req.Price <= 0:
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "price must be positive")
+		return
+	case req.DownPayment < 0 || req.DownPayment >= req.Price:
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "down_payment must be between 0 and the price")
+		return
+	case req.TermMonths < 1 || req.TermMonths > 600:
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "term_months must be between 1 and 600")
+		return
+	}
+
+	apr, err := resolveAPR(req)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, err.Error())
+		return
+	}
+	if apr < 0 || apr > 1 {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "apr must be between 0 and 100%")
+		return
+	}
+
+	compounding := strings.ToLower(req.Compounding)
+	if compounding == "" {
+		compounding = "monthly"
+	}
+	var rate float64
+	switch compounding {
+	case "monthly":
+		rate = apr / 12
+	case "daily":
+		// Effective monthly rate of an APR compounded daily
+		rate = math.Pow(1+apr/365, 365.0/12) - 1
+	default:
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "compounding must be monthly or daily")
+		return
+	}
+
+	principal := roundCents(req.Price - req.DownPayment)
+	payment, schedule := amortize(principal, rate, req.TermMonths)
+
+	totalPaid, totalInterest := 0.0, 0.0
+	for _, row := range schedule {
+		totalPaid += row.Payment
+		totalInterest += row.Interest
+	}
+
+	data := map[string]interface{}{
+		"principal":       principal,
+		"apr":             apr,
+		"apr_percent":     apr * 100,
+		"compounding":     compounding,
+		"term_months":     req.TermMonths,
+		"monthly_payment": payment,
+		"final_payment":   schedule[len(schedule)-1].Payment,
+		"total_interest":  roundCents(totalInterest),
+		"total_paid":      roundCents(totalPaid),
+	}
+	if wantSchedule, _ := strconv.ParseBool(r.URL.Query().Get("schedule")); wantSchedule {
+		data["schedule"] = schedule
+	}
+	respond(w, http.StatusOK, data)
+}
+
+// resolveAPR returns the request's APR as a fraction. apr_percent is always a
+// percentage. The legacy apr field guesses the unit (see normalizeAPR), so
+// values between aprAmbiguousAbove and 1 — 0.5 could be a 0.5% promo rate
+// or 50% — are rejected rather than guessed.
+func resolveAPR(req FinanceRequest) (float64, error) {
+	if req.APRPercent != nil {
+		if req.APR != 0 {
+			return 0, errors.New("set apr or apr_percent, not both")
+		}
+		return *req.APRPercent / 100, nil
+	}
+	if req.APR > aprAmbiguousAbove && req.APR < 1 {
+		return 0, fmt.Errorf("apr %g is ambiguous; send apr_percent instead (e.g. 0.5 for 0.5%%)", req.APR)
+	}
+	return normalizeAPR(req.APR), nil
+}
+
+// normalizeAPR accepts an APR as a percentage (7.5) or a fraction (0.075)
+// and returns the fraction. Values of 1 or more are read as percentages.
+func normalizeAPR(apr float64) float64 {
+	if apr >= 1 {
+		return apr / 100
+	}
+	return apr
+}
+
+// amortize returns the regular monthly payment and the month-by-month
+// schedule for a fixed-rate loan of principal over n months at the given
+// monthly rate.
+func amortize(principal, rate float64, n int) (float64, []AmortizationRow) {
+	var payment float64
+	if rate == 0 {
+		payment = principal / float64(n)
+	} else {
+		payment = principal * rate / (1 - math.Pow(1+rate, -float64(n)))
+	}
+	payment = roundCents(payment)
+
+	schedule := make([]AmortizationRow, 0, n)
+	balance := principal
+	for month := 1; month <= n; month++ {
+		interest := roundCents(balance * rate)
+		pay := payment
+		if month == n || pay > balance+interest {
+			// Final (or early-finishing) payment clears the balance exactly
+			pay = roundCents(balance + interest)
+		}
+		toPrincipal := roundCents(pay - interest)
+		balance = roundCents(balance - toPrincipal)
+
+		schedule = append(schedule, AmortizationRow{
+			Month:     month,
+			Payment:   pay,
+			Principal: toPrincipal,
+			Interest:  interest,
+			Balance:   balance,
+		})
+		if balance == 0 {
+			break
+		}
+	}
+	return payment, schedule
+}
+
+// roundCents rounds v to two decimal places.
+func roundCents(v float64) float64 {
+	return math.Round(v*100) / 100
+}
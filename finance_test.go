@@ -0,0 +1,124 @@
+package main
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// finance posts body to financeHandler and returns the response.
+func finance(t *testing.T, target string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	financeHandler(rec, jsonRequest(t, "POST", target, body))
+	return rec
+}
+
+func TestAmortizeKnownValues(t *testing.T) {
+	tests := []struct {
+		name                     string
+		principal, apr           float64
+		months                   int
+		payment, final, interest float64
+	}{
+		{"20k at 6% over 5 years", 20000, 0.06, 60, 386.66, 386.41, 3199.35},
+		{"10k at 12% over 1 year", 10000, 0.12, 12, 888.49, 888.47, 661.86},
+		{"interest free", 1200, 0, 12, 100, 100, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payment, schedule := amortize(tt.principal, tt.apr/12, tt.months)
+			if payment != tt.payment {
+				t.Errorf("payment = %v, want %v", payment, tt.payment)
+			}
+			if len(schedule) != tt.months {
+				t.Fatalf("schedule has %d rows, want %d", len(schedule), tt.months)
+			}
+			last := schedule[len(schedule)-1]
+			if last.Payment != tt.final || last.Balance != 0 {
+				t.Errorf("final row = %+v, want payment %v clearing the balance", last, tt.final)
+			}
+
+			principal, interest := 0.0, 0.0
+			for _, row := range schedule {
+				principal += row.Principal
+				interest += row.Interest
+			}
+			if math.Abs(principal-tt.principal) > 0.005 || math.Abs(interest-tt.interest) > 0.005 {
+				t.Errorf("repaid %.2f principal and %.2f interest, want %.2f and %.2f",
+					principal, interest, tt.principal, tt.interest)
+			}
+		})
+	}
+}
+
+func TestFinanceHandlerAPRUnits(t *testing.T) {
+	tests := []struct {
+		name string
+		body map[string]interface{}
+		code int
+		apr  float64
+	}{
+		{"legacy percentage", map[string]interface{}{"apr": 7.5}, http.StatusOK, 0.075},
+		{"legacy fraction", map[string]interface{}{"apr": 0.075}, http.StatusOK, 0.075},
+		{"apr_percent below 1%", map[string]interface{}{"apr_percent": 0.5}, http.StatusOK, 0.005},
+		{"apr_percent", map[string]interface{}{"apr_percent": 7.5}, http.StatusOK, 0.075},
+		{"ambiguous legacy value", map[string]interface{}{"apr": 0.5}, http.StatusBadRequest, 0},
+		{"both fields", map[string]interface{}{"apr": 7.5, "apr_percent": 7.5}, http.StatusBadRequest, 0},
+		{"over 100%", map[string]interface{}{"apr_percent": 150.0}, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := map[string]interface{}{"price": 25000, "down_payment": 5000, "term_months": 60}
+			for k, v := range tt.body {
+				body[k] = v
+			}
+			rec := finance(t, "/api/finance", body)
+			if rec.Code != tt.code {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.code, rec.Body.String())
+			}
+			if tt.code != http.StatusOK {
+				return
+			}
+			var got struct {
+				APR        float64 `json:"apr"`
+				APRPercent float64 `json:"apr_percent"`
+			}
+			decodeData(t, rec, &got)
+			if math.Abs(got.APR-tt.apr) > 1e-9 || math.Abs(got.APRPercent-tt.apr*100) > 1e-9 {
+				t.Errorf("apr = %v (%v%%), want %v", got.APR, got.APRPercent, tt.apr)
+			}
+		})
+	}
+}
+
+func TestFinanceHandlerSchedule(t *testing.T) {
+	body := map[string]interface{}{"price": 25000, "down_payment": 5000, "apr_percent": 6, "term_months": 60}
+	rec := finance(t, "/api/finance?schedule=1", body)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var got struct {
+		Principal      float64           `json:"principal"`
+		MonthlyPayment float64           `json:"monthly_payment"`
+		TotalInterest  float64           `json:"total_interest"`
+		TotalPaid      float64           `json:"total_paid"`
+		Schedule       []AmortizationRow `json:"schedule"`
+	}
+	decodeData(t, rec, &got)
+	if got.Principal != 20000 || got.MonthlyPayment != 386.66 || got.TotalInterest != 3199.35 {
+		t.Errorf("got principal %v, payment %v, interest %v; want 20000, 386.66, 3199.35",
+			got.Principal, got.MonthlyPayment, got.TotalInterest)
+	}
+	if got.TotalPaid != roundCents(got.Principal+got.TotalInterest) || len(got.Schedule) != 60 {
+		t.Errorf("total paid %v over %d months, want principal + interest over 60", got.TotalPaid, len(got.Schedule))
+	}
+
+	rec = finance(t, "/api/finance", body)
+	var bare map[string]interface{}
+	decodeData(t, rec, &bare)
+	if _, ok := bare["schedule"]; ok {
+		t.Errorf("schedule included without ?schedule=1")
+	}
+}
@@ -5,4 +5,5 @@ go 1.21
 require (
 	github.com/golang-jwt/jwt/v5 v5.2.1
 	github.com/rs/cors v1.11.0
+	golang.org/x/crypto v0.31.0
 )
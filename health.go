@@ -0,0 +1,52 @@
+package main
+
+import (
+	"net/http"
+	"runtime"
+	"sync/atomic"
+	"time"
+)
+
+// ─── Liveness & Readiness Probes ──────────────────────────────────────────────
+// For load balancers and orchestrators. Both are unauthenticated and skip
+// rate limiting and request logging, since probes hit them every few seconds.
+
+// startTime is when the process started, set first thing in main.
+var startTime time.Time
+
+// serviceReady flips to true once all startup loading has finished, just
+// before the server starts accepting connections, and back to false when
+// graceful shutdown begins.
+var serviceReady atomic.Bool
+
+// shuttingDown is set when graceful shutdown begins, so /readyz can tell a
+// draining instance from one still starting.
+var shuttingDown atomic.Bool
+
+// healthHandler (GET /healthz) reports that the process is alive.
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	respond(w, http.StatusOK, map[string]interface{}{
+		"status":     "ok",
+		"uptime":     time.Since(startTime).Round(time.Second).String(),
+		"goroutines": runtime.NumGoroutine(),
+	})
+}
+
+// readyHandler (GET /readyz) returns 503 until startup data loading has
+// finished, so no traffic is routed to an instance with an empty store, and
+// again once the instance starts shutting down.
+func readyHandler(w http.ResponseWriter, r *http.Request) {
+	if !serviceReady.Load() {
+		status := "starting"
+		if shuttingDown.Load() {
+			status = "stopping"
+		}
+		writeEnvelope(w, http.StatusServiceUnavailable, APIResponse{
+			Data:      map[string]string{"status": status},
+			Error:     "not ready",
+			ErrorCode: errCodeUnavailable,
+		})
+		return
+	}
+	respond(w, http.StatusOK, map[string]string{"status": "ready"})
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ─── Valuation Messages ───────────────────────────────────────────────────────
+// Valuation factors are generated as message keys plus arguments and only
+// rendered to text at the end, in the language the client asked for.
+// Templates use fmt verbs; a key missing from a bundle falls back to the
+// defaultLanguage text. Make/fuel adjustment reasons come from config and
+// are passed through untranslated.
+
+var valuationMessages = map[string]map[string]string{
+	"en": {
+		"depreciation":           "Annual depreciation applied (12%%/yr after year 3)",
+		"appreciation":           "Appreciating classic: +%.0f%%/yr with age",
+		"mileage_very_high":      "Very high mileage (>150k km): -28%%",
+		"mileage_high":           "High mileage (>100k km): -18%%",
+		"mileage_very_low":       "Very low mileage (<10k km): +12%%",
+		"mileage_low":            "Low mileage (<30k km): +6%%",
+		"condition_new":          "New condition: +15%%",
+		"condition_new_too_old":  "Listed as new but %d years old: priced as used",
+		"condition_certified":    "Certified pre-owned: +6%%",
+		"condition_used":         "Standard used vehicle pricing",
+		"fuel_electric":          "Electric: strong demand premium (+18%%)",
+		"fuel_hybrid":            "Hybrid: efficiency premium (+8%%)",
+		"fuel_diesel":            "Diesel: regulatory risk discount (-6%%)",
+		"make_fuel":              "%s: %+.0f%%",
+		"transmission_automatic": "Automatic gearbox: +3%%",
+		"stale_base_price":       "Base price last reviewed %s: confidence lowered",
+		"unknown_make":           "Make not recognised: mid-market default price used",
+		"more_factors":           "…and %d more",
+	},
+	"es": {
+		"depreciation":           "Depreciación anual aplicada (12%%/año a partir del tercer año)",
+		"appreciation":           "Clásico que se revaloriza: +%.0f%%/año con la antigüedad",
+		"mileage_very_high":      "Kilometraje muy alto (>150k km): -28%%",
+		"mileage_high":           "Kilometraje alto (>100k km): -18%%",
+		"mileage_very_low":       "Kilometraje muy bajo (<10k km): +12%%",
+		"mileage_low":            "Kilometraje bajo (<30k km): +6%%",
+		"condition_new":          "Estado nuevo: +15%%",
+		"condition_new_too_old":  "Anunciado como nuevo pero con %d años: valorado como usado",
+		"condition_certified":    "Seminuevo certificado: +6%%",
+		"condition_used":         "Precio estándar de vehículo usado",
+		"fuel_electric":          "Eléctrico: prima por alta demanda (+18%%)",
+		"fuel_hybrid":            "Híbrido: prima por eficiencia (+8%%)",
+		"fuel_diesel":            "Diésel: descuento por riesgo regulatorio (-6%%)",
+		"make_fuel":              "%s: %+.0f%%",
+		"transmission_automatic": "Caja automática: +3%%",
+		"stale_base_price":       "Precio base revisado por última vez en %s: confianza reducida",
+		"unknown_make":           "Marca no reconocida: se usó un precio medio por defecto",
+		"more_factors":           "…y %d más",
+	},
+}
+
+// localize renders a message key in lang.
+func localize(lang, key string, args ...interface{}) string {
+	tmpl, ok := valuationMessages[lang][key]
+	if !ok {
+		tmpl, ok = valuationMessages[defaultLanguage][key]
+	}
+	if !ok {
+		return key
+	}
+	return fmt.Sprintf(tmpl, args...)
+}
+
+// requestLanguage picks the language for a response: an explicit lang wins,
+// then the highest-weighted supported language in acceptLanguage (region
+// subtags are ignored, so "es-MX" matches "es"), then defaultLanguage.
+func requestLanguage(lang, acceptLanguage string) string {
+	if l := baseLanguage(lang); valuationMessages[l] != nil {
+		return l
+	}
+
+	type weighted struct {
+		lang string
+		q    float64
+	}
+	var prefs []weighted
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if tag != "" && q > 0 {
+			prefs = append(prefs, weighted{baseLanguage(tag), q})
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	for _, p := range prefs {
+		if valuationMessages[p.lang] != nil {
+			return p.lang
+		}
+	}
+	return defaultLanguage
+}
+
+// baseLanguage lowercases a language tag and drops any region subtag.
+func baseLanguage(tag string) string {
+	base, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	return strings.ToLower(base)
+}
+
+// ─── GET /api/valuate/messages ────────────────────────────────────────────────
+
+// valuationMessagesHandler exports the factor message bundle for ?lang= (or
+// Accept-Language), so a frontend can render factors itself or check which
+// languages are available. Templates are returned as-is, in fmt syntax
+// (%s, %d, %% for a literal percent sign).
+func valuationMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	lang := requestLanguage(r.URL.Query().Get("lang"), r.Header.Get("Accept-Language"))
+
+	languages := make([]string, 0, len(valuationMessages))
+	for l := range valuationMessages {
+		languages = append(languages, l)
+	}
+	sort.Strings(languages)
+
+	w.Header().Set("Content-Language", lang)
+	respondCached(w, r, map[string]interface{}{
+		"language":  lang,
+		"languages": languages,
+		"messages":  valuationMessages[lang],
+	})
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestValuationFactorsLocalized(t *testing.T) {
+	req := ValuationRequest{
+		Make: "Tesla", Year: 2019, Mileage: 120000, Condition: "certified",
+		FuelType: "electric", Transmission: "automatic",
+	}
+	clearValuationCache()
+	en := valuate(t, req)
+
+	valuateIn := func(lang, acceptLanguage string) (ValuationResponse, string) {
+		t.Helper()
+		r := req
+		r.Lang = lang
+		hr := asUser(jsonRequest(t, "POST", "/api/valuate", r), "seller")
+		if acceptLanguage != "" {
+			hr.Header.Set("Accept-Language", acceptLanguage)
+		}
+		rec := httptest.NewRecorder()
+		valuateHandler(rec, hr)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("valuate = %d: %s", rec.Code, rec.Body.String())
+		}
+		var v ValuationResponse
+		decodeData(t, rec, &v)
+		return v, rec.Header().Get("Content-Language")
+	}
+
+	_, factors := calculateValue(req)
+	var want []string
+	for _, f := range factors {
+		want = append(want, localize("es", f.Key, f.Args...))
+	}
+
+	for name, in := range map[string][2]string{
+		"lang field":      {"es", ""},
+		"Accept-Language": {"", "es-MX,es;q=0.9,en;q=0.5"},
+	} {
+		es, contentLang := valuateIn(in[0], in[1])
+		if contentLang != "es" {
+			t.Errorf("%s: Content-Language = %q, want es", name, contentLang)
+		}
+		if !reflect.DeepEqual(es.Factors, want) {
+			t.Errorf("%s: factors = %q, want %q", name, es.Factors, want)
+		}
+		if reflect.DeepEqual(es.Factors, en.Factors) {
+			t.Errorf("%s: factors were not translated: %q", name, es.Factors)
+		}
+		if es.EstimatedMin != en.EstimatedMin || es.EstimatedMax != en.EstimatedMax || es.Confidence != en.Confidence {
+			t.Errorf("%s: valuation changed with the language: %v–%v %s vs %v–%v %s", name,
+				es.EstimatedMin, es.EstimatedMax, es.Confidence, en.EstimatedMin, en.EstimatedMax, en.Confidence)
+		}
+	}
+
+	// Unsupported languages fall back to English
+	if fr, contentLang := valuateIn("", "fr-FR"); contentLang != defaultLanguage || !reflect.DeepEqual(fr.Factors, en.Factors) {
+		t.Errorf("fr-FR: Content-Language %q, factors %q; want the English ones", contentLang, fr.Factors)
+	}
+}
+
+func TestRequestLanguage(t *testing.T) {
+	tests := []struct {
+		lang, accept, want string
+	}{
+		{"", "", "en"},
+		{"es", "en", "es"},
+		{"ES-ar", "", "es"},
+		{"", "es", "es"},
+		{"", "en;q=0.4, es;q=0.8", "es"},
+		{"", "fr, es;q=0.1", "es"},
+		{"", "es;q=0", "en"},
+		{"xx", "es", "es"},
+	}
+	for _, tt := range tests {
+		if got := requestLanguage(tt.lang, tt.accept); got != tt.want {
+			t.Errorf("requestLanguage(%q, %q) = %q, want %q", tt.lang, tt.accept, got, tt.want)
+		}
+	}
+}
@@ -1,14 +1,17 @@
 package main
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
-
 func generateTokenPair(username string) (accessToken, refreshToken string, err error) {
-	
+
 	// Short-lived (15 min). Sent in Authorization: Bearer <token> header.
 	atClaims := &Claims{
 		Username:  username,
@@ -16,6 +19,7 @@ func generateTokenPair(username string) (accessToken, refreshToken string, err e
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 	accessToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, atClaims).SignedString(jwtSecret)
@@ -31,6 +35,7 @@ func generateTokenPair(username string) (accessToken, refreshToken string, err e
 		RegisteredClaims: jwt.RegisteredClaims{
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(refreshTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
 		},
 	}
 	refreshToken, err = jwt.NewWithClaims(jwt.SigningMethodHS256, rtClaims).SignedString(jwtSecret)
@@ -46,7 +51,24 @@ func generateTokenPair(username string) (accessToken, refreshToken string, err e
 	return
 }
 
-
+// generateImpersonationToken issues a short-lived access token that acts as
+// target but records the admin behind it in the impersonated_by claim.
+// No refresh token is issued, so the session ends when it expires.
+func generateImpersonationToken(target, admin string) (string, time.Time, error) {
+	expires := time.Now().Add(impersonationTTL)
+	claims := &Claims{
+		Username:       target,
+		TokenType:      "access",
+		ImpersonatedBy: admin,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(expires),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			NotBefore: jwt.NewNumericDate(time.Now()),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+	return token, expires, err
+}
 
 func validateJWT(tokenString, expectedType string) (*Claims, error) {
 	claims := &Claims{}
@@ -57,13 +79,30 @@ func validateJWT(tokenString, expectedType string) (*Claims, error) {
 			return nil, jwt.ErrSignatureInvalid
 		}
 		return jwtSecret,
-		nil
+			nil
 	})
 
+	// Expiry is reported separately so callers can tell "log in again"
+	// apart from a tampered or malformed token
+	if errors.Is(err, jwt.ErrTokenExpired) {
+		return nil, jwt.ErrTokenExpired
+	}
+	// The library rejects tokens used before their "nbf" claim; surface it
+	// distinctly since it's a scheduling issue rather than a bad token
+	if errors.Is(err, jwt.ErrTokenNotValidYet) {
+		return nil, jwt.ErrTokenNotValidYet
+	}
 	if err != nil || !token.Valid {
 		return nil, jwt.ErrTokenInvalidClaims
 	}
 
+	// A token issued in the future means a tampered clock or a forged
+	// token, so it's rejected outright; maxClockSkew allows for small drift
+	// between servers. Every token we mint carries "iat", so it's required.
+	if claims.IssuedAt == nil || claims.IssuedAt.Time.After(time.Now().Add(maxClockSkew)) {
+		return nil, jwt.ErrTokenInvalidClaims
+	}
+
 	// Enforce the token type to prevent misuse
 	if claims.TokenType != expectedType {
 		return nil, jwt.ErrTokenInvalidClaims
@@ -71,3 +110,33 @@ func validateJWT(tokenString, expectedType string) (*Claims, error) {
 
 	return claims, nil
 }
+
+// validateAPIKey looks up a presented X-API-Key by its SHA-256 hash and
+// returns claims for the key's service account. Every configured key is
+// compared in constant time, so timing doesn't reveal how close a guess is.
+func validateAPIKey(key string) (*Claims, bool) {
+	sum := sha256.Sum256([]byte(key))
+	presented := hex.EncodeToString(sum[:])
+
+	var match *ServiceAPIKey
+	for i := range apiKeys {
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(apiKeys[i].Hash)) == 1 {
+			match = &apiKeys[i]
+		}
+	}
+	if match == nil {
+		return nil, false
+	}
+	return &Claims{Username: match.Username, TokenType: "api_key"}, true
+}
+
+// serviceAccount returns the API key entry for username, if it is a
+// service account.
+func serviceAccount(username string) (ServiceAPIKey, bool) {
+	for _, k := range apiKeys {
+		if k.Username == username {
+			return k, true
+		}
+	}
+	return ServiceAPIKey{}, false
+}
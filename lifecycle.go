@@ -0,0 +1,464 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// ─── POST /api/cars/{id}/relist ───────────────────────────────────────────────
+
+// relistCarHandler moves a sold or archived listing back to active; deleted
+// listings go through restore instead, so the grace window applies.
+// The sale details are cleared and ListedAt is refreshed so the car shows up
+// as a fresh listing, but its view count is kept.
+// Only the original seller may relist; an already-active car returns 409.
+func relistCarHandler(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+
+	id, err := parseCarID(r.URL.Path)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
+		return
+	}
+
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	car, ok := carStore[id]
+	if !ok {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+
+	if car.Seller != claims.Username {
+		respondError(w, http.StatusForbidden, errCodeForbidden, "you can only relist your own listings")
+		return
+	}
+
+	if car.Status == statusActive {
+		respondError(w, http.StatusConflict, errCodeConflict, "listing is already active")
+		return
+	}
+	if car.Status == statusDeleted {
+		respondError(w, http.StatusConflict, errCodeConflict, "listing was deleted; restore it instead")
+		return
+	}
+
+	car.Status = statusActive
+	car.SoldAt, car.SalePrice = "", 0
+	car.ReservedBy, car.ReservedUntil = "", ""
+	car.ListedAt = time.Now().Format(time.RFC3339)
+	carStore[id] = car
+	markCarsDirty()
+
+	respond(w, http.StatusOK, liveViews(car))
+}
+
+// ─── POST /api/cars/{id}/restore ──────────────────────────────────────────────
+
+// restoreCarHandler undoes a soft delete, putting the listing back on the
+// market as it was: unlike a relist, ListedAt is left alone. The seller or
+// an admin may restore, but only within restoreGraceWindow of the delete —
+// after that the listing is as good as purged and the request gets 410 Gone.
+// Listings that were never deleted (including ones sold normally) get 409.
+func restoreCarHandler(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+
+	id, err := parseCarID(r.URL.Path)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
+		return
+	}
+
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	car, ok := carStore[id]
+	if !ok {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+
+	if car.Seller != claims.Username && !isAdmin(claims.Username) {
+		respondError(w, http.StatusForbidden, errCodeForbidden, "you can only restore your own listings")
+		return
+	}
+
+	if car.Status != statusDeleted {
+		respondError(w, http.StatusConflict, errCodeConflict, "listing was not deleted")
+		return
+	}
+	if restoreExpired(car, time.Now()) {
+		respondError(w, http.StatusGone, errCodeGone, "restore window has passed")
+		return
+	}
+
+	car.Status = statusActive
+	car.DeletedAt = ""
+	carStore[id] = car
+	markCarsDirty()
+
+	respond(w, http.StatusOK, liveViews(car))
+}
+
+// restoreExpired reports whether a soft-deleted car is past its restore
+// window at now. An unparseable DeletedAt counts as expired.
+func restoreExpired(car CarListing, now time.Time) bool {
+	deleted, err := time.Parse(time.RFC3339, car.DeletedAt)
+	return err != nil || now.Sub(deleted) > restoreGraceWindow
+}
+
+// startDeletedPurge runs the background job that removes soft-deleted
+// listings once their restore window has passed, then sweeps share links
+// that have expired or lost their listing.
+func startDeletedPurge() {
+	go func() {
+		for now := range time.Tick(purgeInterval) {
+			if n := purgeExpiredDeletes(now); n > 0 {
+				log.Printf("purged %d deleted listing(s)", n)
+			}
+			if n := sweepShareLinks(now); n > 0 {
+				log.Printf("swept %d share link(s)", n)
+			}
+		}
+	}()
+}
+
+// purgeExpiredDeletes removes every soft-deleted listing past its restore
+// window at now, and reports how many were removed.
+func purgeExpiredDeletes(now time.Time) int {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	removed := 0
+	for _, car := range carStore {
+		if car.Status == statusDeleted && restoreExpired(car, now) {
+			purgeCar(car)
+			removed++
+		}
+	}
+	if removed > 0 {
+		markCarsDirty()
+	}
+	return removed
+}
+
+// ─── POST /api/cars/mine/status ───────────────────────────────────────────────
+
+// bulkStatusResult is the outcome for one ID of a bulk status change.
+type bulkStatusResult struct {
+	ID     int    `json:"id"`
+	Status string `json:"status,omitempty"` // new status on success
+	Error  string `json:"error,omitempty"`
+}
+
+// bulkStatusHandler moves several of the caller's listings to one status at
+// once, e.g. archiving a batch of inventory. All changes happen under a
+// single store lock. Each ID succeeds or fails on its own and gets a result
+// in request order; admins may change anyone's listings.
+//
+// Request body: { "ids": [3, 7], "status": "sold", "sale_price": 95000 }
+func bulkStatusHandler(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+
+	var req struct {
+		IDs       []int   `json:"ids"`
+		Status    string  `json:"status"`
+		SalePrice float64 `json:"sale_price"`
+	}
+	if status, err := decodeJSON(w, r, &req, maxBodyBytes); err != nil {
+		respondError(w, status, errCodeInvalidBody, err.Error())
+		return
+	}
+	if len(req.IDs) == 0 {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "ids must not be empty")
+		return
+	}
+	if len(req.IDs) > maxBatchItems {
+		respondError(w, http.StatusRequestEntityTooLarge, errCodePayloadTooLarge, fmt.Sprintf("at most %d ids per request", maxBatchItems))
+		return
+	}
+	switch req.Status {
+	case statusActive, statusSold, statusArchived:
+	default:
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "status must be one of active, sold, archived")
+		return
+	}
+	admin := isAdmin(claims.Username)
+
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	now := time.Now()
+	changed := 0
+	results := make([]bulkStatusResult, len(req.IDs))
+	for i, id := range req.IDs {
+		results[i].ID = id
+		car, ok := carStore[id]
+		if !ok || (car.Seller != claims.Username && !admin) {
+			// Someone else's listing looks the same as a missing one
+			results[i].Error = "car not found"
+			continue
+		}
+		if err := changeStatus(&car, req.Status, req.SalePrice, now); err != nil {
+			results[i].Error = err.Error()
+			continue
+		}
+		carStore[id] = car
+		results[i].Status = car.Status
+		changed++
+	}
+	if changed > 0 {
+		markCarsDirty()
+	}
+
+	respond(w, http.StatusOK, map[string]interface{}{
+		"results": results,
+		"changed": changed,
+	})
+}
+
+// changeStatus applies a lifecycle transition to car. Moving back to active
+// works like a relist; marking sold requires a positive sale price, so an
+// active listing can't become sold without one. A car already in the target
+// status is an error, and deleted listings only come back through restore.
+func changeStatus(car *CarListing, target string, salePrice float64, now time.Time) error {
+	if car.Status == target {
+		return fmt.Errorf("listing is already %s", target)
+	}
+	if car.Status == statusDeleted {
+		return errors.New("listing was deleted; restore it instead")
+	}
+	switch target {
+	case statusActive:
+		car.SoldAt, car.SalePrice = "", 0
+		car.ListedAt = now.Format(time.RFC3339)
+	case statusSold:
+		if salePrice <= 0 {
+			return errors.New("sale_price is required to mark a listing sold")
+		}
+		car.SoldAt = now.Format(time.RFC3339)
+		car.SalePrice = salePrice
+		car.BoostedUntil = ""
+	case statusArchived:
+		car.BoostedUntil = ""
+	}
+	car.ReservedBy, car.ReservedUntil = "", ""
+	car.Status = target
+	return nil
+}
+
+// ─── POST|DELETE /api/cars/{id}/reserve ───────────────────────────────────────
+
+// reserveCarHandler lets a buyer in negotiation hold an active listing for
+// reservationHold, taking it out of the default listings. The seller can't
+// reserve their own car, and a car already held by someone returns 409; an
+// expired hold is simply replaced.
+func reserveCarHandler(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+
+	id, err := parseCarID(r.URL.Path)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
+		return
+	}
+
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	car, ok := carStore[id]
+	if !ok {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+
+	if car.Seller == claims.Username {
+		respondError(w, http.StatusForbidden, errCodeForbidden, "you can't reserve your own listing")
+		return
+	}
+	if car.Status != statusActive {
+		respondError(w, http.StatusConflict, errCodeConflict, "only active listings can be reserved")
+		return
+	}
+	now := time.Now()
+	if isReserved(car, now) {
+		respondError(w, http.StatusConflict, errCodeConflict, "listing is already reserved")
+		return
+	}
+
+	car.ReservedBy = claims.Username
+	car.ReservedUntil = now.Add(reservationHold).Format(time.RFC3339)
+	carStore[id] = car
+	markCarsDirty()
+
+	respond(w, http.StatusOK, liveViews(car))
+}
+
+// cancelReservationHandler releases a reservation early. The seller or the
+// reserving buyer may cancel; 409 if the car isn't currently reserved.
+func cancelReservationHandler(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+
+	id, err := parseCarID(r.URL.Path)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
+		return
+	}
+
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	car, ok := carStore[id]
+	if !ok {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+
+	if car.Seller != claims.Username && car.ReservedBy != claims.Username {
+		respondError(w, http.StatusForbidden, errCodeForbidden, "only the seller or the reserving buyer can cancel a reservation")
+		return
+	}
+	if !isReserved(car, time.Now()) {
+		respondError(w, http.StatusConflict, errCodeConflict, "listing is not reserved")
+		return
+	}
+
+	car.ReservedBy, car.ReservedUntil = "", ""
+	carStore[id] = car
+	markCarsDirty()
+
+	respond(w, http.StatusOK, liveViews(car))
+}
+
+// ─── POST /api/cars/{id}/reserve/confirm ──────────────────────────────────────
+
+// confirmReservationHandler closes the sale to the reserving buyer at the
+// listed price. Only the seller may confirm, and only while the reservation
+// is still held.
+func confirmReservationHandler(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+
+	id, err := parseCarID(r.URL.Path)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
+		return
+	}
+
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	car, ok := carStore[id]
+	if !ok {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+
+	if car.Seller != claims.Username {
+		respondError(w, http.StatusForbidden, errCodeForbidden, "only the seller can confirm a reservation")
+		return
+	}
+	now := time.Now()
+	if !isReserved(car, now) {
+		respondError(w, http.StatusConflict, errCodeConflict, "listing is not reserved")
+		return
+	}
+
+	car.Status = statusSold
+	car.SoldAt = now.Format(time.RFC3339)
+	car.SalePrice = car.Price
+	car.BoostedUntil = ""
+	car.ReservedUntil = "" // ReservedBy is kept as the buyer
+	carStore[id] = car
+	markCarsDirty()
+
+	respond(w, http.StatusOK, liveViews(car))
+}
+
+// ─── POST /api/cars/{id}/boost ────────────────────────────────────────────────
+
+// boostCarHandler promotes a listing for boostDuration, floating it above
+// unboosted listings in every sort. Boosting an already-boosted listing
+// extends the current boost rather than restarting it. Only the seller may
+// boost, and only active listings can be boosted.
+func boostCarHandler(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+
+	id, err := parseCarID(r.URL.Path)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
+		return
+	}
+
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	car, ok := carStore[id]
+	if !ok {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+
+	if car.Seller != claims.Username {
+		respondError(w, http.StatusForbidden, errCodeForbidden, "you can only boost your own listings")
+		return
+	}
+	if car.Status != statusActive {
+		respondError(w, http.StatusConflict, errCodeConflict, "only active listings can be boosted")
+		return
+	}
+
+	now := time.Now()
+	start := now
+	if until, err := time.Parse(time.RFC3339, car.BoostedUntil); err == nil && until.After(now) {
+		start = until
+	}
+	car.BoostedUntil = start.Add(boostDuration).Format(time.RFC3339)
+	carStore[id] = car
+	markCarsDirty()
+
+	car = liveViews(car)
+	car.Boosted = true
+	car.QualityScore = listingQuality(car, now)
+	respond(w, http.StatusOK, car)
+}
+
+// ─── GET /api/sold-archive ────────────────────────────────────────────────────
+
+// soldArchiveHandler returns sold listings, most recently sold first,
+// paginated like GET /api/cars. Feeds the sold-archive page.
+func soldArchiveHandler(w http.ResponseWriter, r *http.Request) {
+	limit, offset, err := parsePagination(r.URL.Query())
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, err.Error())
+		return
+	}
+
+	storeMu.RLock()
+	sold := []CarListing{}
+	for _, car := range carStore {
+		if car.Status == statusSold {
+			sold = append(sold, liveViews(car))
+		}
+	}
+	storeMu.RUnlock()
+
+	sort.SliceStable(sold, func(i, j int) bool {
+		ti, _ := time.Parse(time.RFC3339, sold[i].SoldAt)
+		tj, _ := time.Parse(time.RFC3339, sold[j].SoldAt)
+		if !ti.Equal(tj) {
+			return ti.After(tj)
+		}
+		return sold[i].ID < sold[j].ID
+	})
+
+	page, meta := paginate(sold, limit, offset)
+	respond(w, http.StatusOK, map[string]interface{}{
+		"listings":   page,
+		"count":      len(page),
+		"pagination": meta,
+	})
+}
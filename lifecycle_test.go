@@ -0,0 +1,358 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRelistSoldCar(t *testing.T) {
+	listed := time.Now().Add(-60 * 24 * time.Hour).Format(time.RFC3339)
+	useStore(t, CarListing{
+		ID: 1, Seller: "alice", Status: statusSold, ListedAt: listed, Views: 40,
+		SoldAt: time.Now().Format(time.RFC3339), SalePrice: 90000,
+	})
+
+	rec := httptest.NewRecorder()
+	relistCarHandler(rec, asUser(httptest.NewRequest("POST", "/api/cars/1/relist", nil), "alice"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("relist = %d: %s", rec.Code, rec.Body.String())
+	}
+	var got CarListing
+	decodeData(t, rec, &got)
+	if got.Status != statusActive || got.SoldAt != "" || got.SalePrice != 0 {
+		t.Errorf("relisted car = status %q, sold_at %q, sale_price %v; want active with no sale",
+			got.Status, got.SoldAt, got.SalePrice)
+	}
+	if got.ListedAt == listed {
+		t.Errorf("listed_at was not refreshed")
+	}
+	if got.Views != 40 {
+		t.Errorf("views = %d, want 40 kept", got.Views)
+	}
+	if carStore[1].Status != statusActive {
+		t.Errorf("store status = %q, want active", carStore[1].Status)
+	}
+}
+
+func TestRelistRejections(t *testing.T) {
+	useStore(t,
+		CarListing{ID: 1, Seller: "alice"},
+		CarListing{ID: 2, Seller: "alice", Status: statusSold},
+		CarListing{ID: 3, Seller: "alice", Status: statusDeleted, DeletedAt: time.Now().Format(time.RFC3339)},
+	)
+
+	tests := []struct {
+		name string
+		path string
+		user string
+		want int
+	}{
+		{"already active", "/api/cars/1/relist", "alice", http.StatusConflict},
+		{"someone else's listing", "/api/cars/2/relist", "bob", http.StatusForbidden},
+		{"deleted listing", "/api/cars/3/relist", "alice", http.StatusConflict},
+		{"missing listing", "/api/cars/99/relist", "alice", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			relistCarHandler(rec, asUser(httptest.NewRequest("POST", tt.path, nil), tt.user))
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+	if carStore[2].Status != statusSold {
+		t.Errorf("rejected relist changed the listing to %q", carStore[2].Status)
+	}
+}
+
+func TestRestoreWithinWindow(t *testing.T) {
+	now := time.Now()
+	inWindow := now.Add(-restoreGraceWindow / 2).Format(time.RFC3339)
+	expired := now.Add(-restoreGraceWindow - time.Minute).Format(time.RFC3339)
+	useStore(t,
+		CarListing{ID: 1, Seller: "alice", Status: statusDeleted, DeletedAt: inWindow},
+		CarListing{ID: 2, Seller: "alice", Status: statusDeleted, DeletedAt: expired},
+		CarListing{ID: 3, Seller: "alice", Status: statusDeleted, DeletedAt: inWindow},
+		CarListing{ID: 4, Seller: "alice"},
+	)
+
+	restore := func(id, user string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		restoreCarHandler(rec, asUser(httptest.NewRequest("POST", "/api/cars/"+id+"/restore", nil), user))
+		return rec
+	}
+
+	rec := restore("1", "alice")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("in-window restore = %d: %s", rec.Code, rec.Body.String())
+	}
+	if car := carStore[1]; car.Status != statusActive || car.DeletedAt != "" {
+		t.Errorf("restored car = status %q, deleted_at %q; want active", car.Status, car.DeletedAt)
+	}
+
+	tests := []struct {
+		name string
+		id   string
+		user string
+		want int
+	}{
+		{"past the window", "2", "alice", http.StatusGone},
+		{"someone else's listing", "3", "bob", http.StatusForbidden},
+		{"admin restores any listing", "3", demoUsername, http.StatusOK},
+		{"not deleted", "4", "alice", http.StatusConflict},
+		{"missing listing", "99", "alice", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := restore(tt.id, tt.user); rec.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", rec.Code, tt.want, rec.Body.String())
+			}
+		})
+	}
+	if carStore[2].Status != statusDeleted {
+		t.Errorf("expired listing status = %q, want still deleted", carStore[2].Status)
+	}
+}
+
+func TestPurgeExpiredDeletes(t *testing.T) {
+	now := time.Now()
+	useStore(t,
+		CarListing{ID: 1, Status: statusDeleted, DeletedAt: now.Add(-time.Minute).Format(time.RFC3339)},
+		CarListing{ID: 2, Status: statusDeleted, DeletedAt: now.Add(-restoreGraceWindow - time.Minute).Format(time.RFC3339)},
+		CarListing{ID: 3, Status: statusSold, SoldAt: now.Add(-restoreGraceWindow - time.Minute).Format(time.RFC3339)},
+	)
+
+	if n := purgeExpiredDeletes(now); n != 1 {
+		t.Errorf("purged %d, want 1", n)
+	}
+	if _, ok := carStore[2]; ok {
+		t.Errorf("expired deletion was not purged")
+	}
+	for _, id := range []int{1, 3} {
+		if _, ok := carStore[id]; !ok {
+			t.Errorf("car %d was purged", id)
+		}
+	}
+}
+
+// boost calls boostCarHandler for carID as user.
+func boost(carID, user string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	boostCarHandler(rec, asUser(httptest.NewRequest("POST", "/api/cars/"+carID+"/boost", nil), user))
+	return rec
+}
+
+func TestBoostFloatsToTop(t *testing.T) {
+	expired := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	useStore(t,
+		CarListing{ID: 1, Seller: "alice", Price: 10000},
+		CarListing{ID: 2, Seller: "alice", Price: 20000, BoostedUntil: expired},
+		CarListing{ID: 3, Seller: "alice", Price: 30000},
+	)
+
+	if got := listCars(t, "sort=price_asc"); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Fatalf("before boosting: order %v, want [1 2 3] (expired boost ignored)", got)
+	}
+
+	rec := boost("3", "alice")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("boost = %d: %s", rec.Code, rec.Body.String())
+	}
+	var car CarListing
+	decodeData(t, rec, &car)
+	until, err := time.Parse(time.RFC3339, car.BoostedUntil)
+	if !car.Boosted || err != nil || time.Until(until) < boostDuration-time.Minute {
+		t.Errorf("boosted listing = boosted %v until %q, want flagged for %v", car.Boosted, car.BoostedUntil, boostDuration)
+	}
+
+	for _, query := range []string{"sort=price_asc", "sort=price_desc", ""} {
+		got := listCars(t, query)
+		if len(got) != 3 || got[0] != 3 {
+			t.Errorf("%q: order %v, want the boosted car 3 first", query, got)
+		}
+	}
+
+	// Boosting again extends the running boost
+	decodeData(t, boost("3", "alice"), &car)
+	if extended, _ := time.Parse(time.RFC3339, car.BoostedUntil); !extended.Equal(until.Add(boostDuration)) {
+		t.Errorf("second boost ends %v, want %v", extended, until.Add(boostDuration))
+	}
+
+	// Once it lapses the car drops back into its sorted place
+	stored := carStore[3]
+	stored.BoostedUntil = expired
+	carStore[3] = stored
+	if got := listCars(t, "sort=price_asc"); !reflect.DeepEqual(got, []int{1, 2, 3}) {
+		t.Errorf("after expiry: order %v, want [1 2 3]", got)
+	}
+
+	if rec := boost("1", "bob"); rec.Code != http.StatusForbidden {
+		t.Errorf("boost by a non-owner = %d, want 403", rec.Code)
+	}
+}
+
+// reservation calls handler on /api/cars/{carID}/reserve as user.
+func reservation(handler http.HandlerFunc, method, carID, user string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	handler(rec, asUser(httptest.NewRequest(method, "/api/cars/"+carID+"/reserve", nil), user))
+	return rec
+}
+
+func TestReservationLifecycle(t *testing.T) {
+	useStore(t, CarListing{ID: 1, Seller: "alice", Price: 90000}, CarListing{ID: 2, Seller: "alice"})
+
+	rec := reservation(reserveCarHandler, "POST", "1", "bob")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reserve = %d: %s", rec.Code, rec.Body.String())
+	}
+	var held CarListing
+	decodeData(t, rec, &held)
+	until, err := time.Parse(time.RFC3339, held.ReservedUntil)
+	if held.ReservedBy != "bob" || err != nil || time.Until(until) < reservationHold-time.Minute {
+		t.Errorf("reserved car = by %q until %q, want bob for the full hold", held.ReservedBy, held.ReservedUntil)
+	}
+	if got := listCars(t, ""); !reflect.DeepEqual(got, []int{2}) {
+		t.Errorf("default listings = %v, want the reserved car hidden", got)
+	}
+	if got := listCars(t, "include_reserved=true&sort=price_desc"); !reflect.DeepEqual(got, []int{1, 2}) {
+		t.Errorf("include_reserved listings = %v, want both", got)
+	}
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		method  string
+		user    string
+		want    int
+	}{
+		{"already reserved", reserveCarHandler, "POST", "carol", http.StatusConflict},
+		{"seller reserves own", reserveCarHandler, "POST", "alice", http.StatusForbidden},
+		{"stranger cancels", cancelReservationHandler, "DELETE", "carol", http.StatusForbidden},
+		{"buyer confirms", confirmReservationHandler, "POST", "bob", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		if rec := reservation(tt.handler, tt.method, "1", tt.user); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+
+	if rec := reservation(cancelReservationHandler, "DELETE", "1", "alice"); rec.Code != http.StatusOK {
+		t.Fatalf("seller cancel = %d: %s", rec.Code, rec.Body.String())
+	}
+	if car := carStore[1]; car.ReservedBy != "" || car.ReservedUntil != "" {
+		t.Errorf("after cancel: reserved by %q until %q, want released", car.ReservedBy, car.ReservedUntil)
+	}
+	if rec := reservation(cancelReservationHandler, "DELETE", "1", "alice"); rec.Code != http.StatusConflict {
+		t.Errorf("second cancel = %d, want 409", rec.Code)
+	}
+
+	reservation(reserveCarHandler, "POST", "1", "carol")
+	if rec := reservation(confirmReservationHandler, "POST", "1", "alice"); rec.Code != http.StatusOK {
+		t.Fatalf("confirm = %d: %s", rec.Code, rec.Body.String())
+	}
+	if car := carStore[1]; car.Status != statusSold || car.SalePrice != 90000 || car.ReservedBy != "carol" || car.ReservedUntil != "" {
+		t.Errorf("confirmed car = %+v, want sold to carol at the listed price", car)
+	}
+	if rec := reservation(reserveCarHandler, "POST", "1", "bob"); rec.Code != http.StatusConflict {
+		t.Errorf("reserve sold car = %d, want 409", rec.Code)
+	}
+}
+
+func TestReservationAutoExpiry(t *testing.T) {
+	lapsed := time.Now().Add(-time.Minute).Format(time.RFC3339)
+	useStore(t, CarListing{ID: 1, Seller: "alice", ReservedBy: "bob", ReservedUntil: lapsed})
+
+	if got := listCars(t, ""); !reflect.DeepEqual(got, []int{1}) {
+		t.Errorf("listings = %v, want the lapsed reservation shown again", got)
+	}
+	if rec := reservation(confirmReservationHandler, "POST", "1", "alice"); rec.Code != http.StatusConflict {
+		t.Errorf("confirm lapsed = %d, want 409", rec.Code)
+	}
+	if rec := reservation(cancelReservationHandler, "DELETE", "1", "bob"); rec.Code != http.StatusConflict {
+		t.Errorf("cancel lapsed = %d, want 409", rec.Code)
+	}
+	if rec := reservation(reserveCarHandler, "POST", "1", "carol"); rec.Code != http.StatusOK {
+		t.Fatalf("reserve over lapsed hold = %d: %s", rec.Code, rec.Body.String())
+	}
+	if carStore[1].ReservedBy != "carol" {
+		t.Errorf("reserved by %q, want carol", carStore[1].ReservedBy)
+	}
+}
+
+// bulkStatus posts body to bulkStatusHandler as user.
+func bulkStatus(t *testing.T, user string, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	bulkStatusHandler(rec, asUser(jsonRequest(t, "POST", "/api/cars/mine/status", body), user))
+	return rec
+}
+
+func TestBulkStatusMixedTransitions(t *testing.T) {
+	useStore(t,
+		CarListing{ID: 1, Seller: "alice"},
+		CarListing{ID: 2, Seller: "alice", Status: statusArchived},
+		CarListing{ID: 3, Seller: "alice", Status: statusDeleted, DeletedAt: time.Now().Format(time.RFC3339)},
+		CarListing{ID: 4, Seller: "bob"},
+		CarListing{ID: 5, Seller: "alice"},
+	)
+
+	type result struct {
+		Results []bulkStatusResult `json:"results"`
+		Changed int                `json:"changed"`
+	}
+	call := func(user string, body interface{}) result {
+		t.Helper()
+		rec := bulkStatus(t, user, body)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("bulk status = %d: %s", rec.Code, rec.Body.String())
+		}
+		var got result
+		decodeData(t, rec, &got)
+		return got
+	}
+
+	got := call("alice", map[string]interface{}{"ids": []int{1, 2, 3, 4, 99, 5}, "status": statusArchived})
+	want := []bulkStatusResult{
+		{ID: 1, Status: statusArchived},
+		{ID: 2, Error: "listing is already archived"},
+		{ID: 3, Error: "listing was deleted; restore it instead"},
+		{ID: 4, Error: "car not found"}, // bob's
+		{ID: 99, Error: "car not found"},
+		{ID: 5, Status: statusArchived},
+	}
+	if !reflect.DeepEqual(got.Results, want) || got.Changed != 2 {
+		t.Errorf("archive results = %+v (changed %d)\nwant %+v (changed 2)", got.Results, got.Changed, want)
+	}
+	if carStore[4].Status != statusActive || carStore[3].Status != statusDeleted {
+		t.Errorf("rejected IDs changed: bob's car %q, deleted car %q", carStore[4].Status, carStore[3].Status)
+	}
+
+	// Sold needs a sale price
+	got = call("alice", map[string]interface{}{"ids": []int{1}, "status": statusSold})
+	if got.Changed != 0 || got.Results[0].Error != "sale_price is required to mark a listing sold" {
+		t.Errorf("sold without price = %+v, want rejected", got.Results)
+	}
+	got = call("alice", map[string]interface{}{"ids": []int{1, 5}, "status": statusSold, "sale_price": 42000})
+	if got.Changed != 2 || carStore[1].SalePrice != 42000 || carStore[5].SoldAt == "" {
+		t.Errorf("sold with price = %+v, store %+v", got.Results, carStore[1])
+	}
+
+	// Admins can target any seller's listings
+	if got := call(demoUsername, map[string]interface{}{"ids": []int{4}, "status": statusArchived}); got.Changed != 1 {
+		t.Errorf("admin archive = %+v, want bob's listing changed", got.Results)
+	}
+
+	for name, body := range map[string]interface{}{
+		"unknown status": map[string]interface{}{"ids": []int{1}, "status": "stolen"},
+		"deleted target": map[string]interface{}{"ids": []int{1}, "status": statusDeleted},
+		"no ids":         map[string]interface{}{"status": statusArchived},
+	} {
+		if rec := bulkStatus(t, "alice", body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, rec.Code)
+		}
+	}
+}
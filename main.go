@@ -1,28 +1,113 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"log"
+	"log/slog"
 	"math/rand"
 	"net/http"
+	"os"
+	"os/signal"
 	"path/filepath"
+	"strings"
+	"syscall"
 	"time"
 
 	"github.com/rs/cors"
 )
 
 func main() {
-	rand.Seed(time.Now().UnixNano()}
+	startTime = time.Now()
 
-			  
-	// Populate the in-memory store with demo cars
-	seedDemoInventory()
+	if err := loadConfig(); err != nil {
+		log.Fatalf("invalid environment configuration:\n%v", err)
+	}
+	if err := validateConfig(); err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+	slog.SetDefault(newLogger())
+	if err := openAnalyticsSink(); err != nil {
+		log.Fatalf("opening analytics sink: %v", err)
+	}
+	if err := loadBasePrices(); err != nil {
+		log.Fatalf("loading base prices: %v", err)
+	}
+
+	rand.Seed(time.Now().UnixNano())
+
+	// Saved listings take precedence; demo cars only on a fresh start
+	loaded, err := loadCarStore()
+	if err != nil {
+		log.Fatalf("loading car store: %v", err)
+	}
+	if !loaded {
+		seedDemoInventory()
+	}
+	startPersistence()
+	startRateLimitJanitor()
+	startDeletedPurge()
+
+	srv := &http.Server{
+		Addr:         serverAddr,
+		Handler:      newRouter(),
+		ReadTimeout:  serverReadTTO,
+		WriteTimeout: serverWriteTTO,
+		IdleTimeout:  serverIdleTTO,
+	}
+
+	log.Printf("  APEX MOTORS  →  listening on %s", serverAddr)
+	log.Println("  Login:  seller / carmarket123              ")
 
+	// SIGINT/SIGTERM: fail readiness first so the load balancer stops routing
+	// here, then drain in-flight requests and write a final snapshot.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-ctx.Done()
+		shuttingDown.Store(true)
+		serviceReady.Store(false)
+		log.Println("shutting down")
+
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("shutdown: %v", err)
+		}
+		persistNow()
+	}()
+
+	// Everything above has loaded; only now accept traffic
+	serviceReady.Store(true)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Fatal(err)
+	}
+	<-stopped
+}
+
+// newRouter registers every route and wraps the mux in the global
+// middleware and CORS policy.
+func newRouter() http.Handler {
 	mux := http.NewServeMux()
 
 	// Each route serves the corresponding HTML file from the static/ directory.
 	// The frontend uses shared.css and shared.js for styling and common behavior.
+	//
+	// "/" is also the catch-all. With spaFallback on, any other GET path serves
+	// index.html so client-side routes survive a refresh; unknown /api/ paths
+	// always get a JSON 404 rather than a page.
 	mux.HandleFunc("/", LoggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
-		http.ServeFile(w, r, filepath.Join("static", "index.html"))
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/"):
+			respondError(w, http.StatusNotFound, errCodeNotFound, "not found")
+		case r.URL.Path == "/" ||
+			(spaFallback && (r.Method == http.MethodGet || r.Method == http.MethodHead)):
+			http.ServeFile(w, r, filepath.Join("static", "index.html"))
+		default:
+			http.NotFound(w, r)
+		}
 	}))
 	mux.HandleFunc("/login", LoggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, filepath.Join("static", "login.html"))
@@ -46,9 +131,19 @@ func main() {
 		http.ServeFile(w, r, filepath.Join("static", "sold-archive.html"))
 	}))
 
+	// Liveness/readiness probes — no auth, rate limiting or logging
+	mux.HandleFunc("/healthz", MethodMiddleware("GET")(healthHandler))
+	mux.HandleFunc("/readyz", MethodMiddleware("GET")(readyHandler))
+
+	// Short share links → 302 to the listing page
+	mux.HandleFunc("/s/", LoggingMiddleware(Chain(shareRedirectHandler, MethodMiddleware("GET"))))
+
 	// Serve static assets (CSS, JS, images) from the static/ folder
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("static"))))
 
+	// Uploaded listing photos — supports Range requests for large images
+	mux.HandleFunc("/uploads/", LoggingMiddleware(Chain(uploadsHandler, MethodMiddleware("GET"))))
+
 	// Rate limited to prevent brute-force attacks.
 	mux.HandleFunc("/api/login",
 		LoggingMiddleware(Chain(loginHandler,
@@ -56,6 +151,13 @@ func main() {
 			MethodMiddleware("POST"),
 		)))
 
+	// Account creation — rate limited like login
+	mux.HandleFunc("/api/register",
+		LoggingMiddleware(Chain(registerHandler,
+			RateLimitMiddleware,
+			MethodMiddleware("POST"),
+		)))
+
 	// Token rotation — client sends old refresh token, gets a new pair back
 	mux.HandleFunc("/api/refresh",
 		LoggingMiddleware(Chain(refreshHandler,
@@ -70,6 +172,18 @@ func main() {
 			MethodMiddleware("POST"),
 		)))
 
+	// Health status for monitoring — no auth, reports degraded persistence
+	mux.HandleFunc("/api/health",
+		LoggingMiddleware(Chain(healthStatusHandler,
+			MethodMiddleware("GET"),
+		)))
+
+	// Side-effect-free check of the bearer access token
+	mux.HandleFunc("/api/token/validate",
+		LoggingMiddleware(Chain(validateTokenHandler,
+			MethodMiddleware("GET"),
+		)))
+
 	// All car routes require a valid JWT access token.
 
 	// GET  /api/cars         — list all (with optional filters)
@@ -86,23 +200,188 @@ func main() {
 			MethodMiddleware("POST"),
 		)))
 
-	// GET|DELETE /api/cars/{id} — view or remove a single listing
+	// GET|PUT|PATCH|DELETE /api/cars/{id} — view, edit or remove a listing
+	// GET  /api/cars/vin/{vin} — look a listing up by VIN
+	// POST /api/cars/{id}/relist — put a sold/archived listing back on sale
+	// POST /api/cars/{id}/boost  — promote a listing above others for a while
+	// GET  /api/cars/{id}/view-trend — daily view counts for a sparkline
+	// GET  /api/cars/{id}/embed  — HTML listing card for third-party sites
+	// GET|POST /api/cars/{id}/comments, DELETE /api/cars/{id}/comments/{cid}
+	// POST /api/cars/{id}/offer, GET /api/cars/{id}/offers (seller only)
+	// POST /api/cars/{id}/image — upload a listing photo (multipart)
+	// POST /api/cars/{id}/share — create a /s/{code} short link
 	mux.HandleFunc("/api/cars/",
 		LoggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
-			switch r.Method {
-			case http.MethodGet:
-				Chain(getCarHandler, AuthMiddleware)(w, r)
-			case http.MethodDelete:
-				Chain(deleteCarHandler, AuthMiddleware)(w, r)
+			// A bare /api/cars/ has no ID — treat it as the list endpoint
+			if r.URL.Path == "/api/cars/" {
+				if bareCarsPathMode == "redirect" {
+					target := "/api/cars"
+					if r.URL.RawQuery != "" {
+						target += "?" + r.URL.RawQuery
+					}
+					http.Redirect(w, r, target, http.StatusPermanentRedirect)
+					return
+				}
+				Chain(getCarsHandler, AuthMiddleware, MethodMiddleware("GET"))(w, r)
+				return
+			}
+
+			if r.URL.Path == "/api/cars/diff" {
+				Chain(carDiffHandler, AuthMiddleware, MethodMiddleware("GET"))(w, r)
+				return
+			}
+
+			if r.URL.Path == "/api/cars/in-budget" {
+				Chain(inBudgetHandler, AuthMiddleware, MethodMiddleware("GET"))(w, r)
+				return
+			}
+
+			if r.URL.Path == "/api/cars/mine/status" {
+				Chain(bulkStatusHandler, AuthMiddleware, MethodMiddleware("POST"))(w, r)
+				return
+			}
+
+			if strings.HasPrefix(r.URL.Path, "/api/cars/vin/") {
+				Chain(getCarByVINHandler, AuthMiddleware, MethodMiddleware("GET"))(w, r)
+				return
+			}
+
+			switch carAction(r.URL.Path) {
+			case "":
+				switch r.Method {
+				case http.MethodGet:
+					Chain(getCarHandler, AuthMiddleware)(w, r)
+				case http.MethodPut, http.MethodPatch:
+					Chain(updateCarHandler, AuthMiddleware)(w, r)
+				case http.MethodDelete:
+					Chain(deleteCarHandler, AuthMiddleware)(w, r)
+				default:
+					respondError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+				}
+			case "relist":
+				Chain(relistCarHandler, AuthMiddleware, MethodMiddleware("POST"))(w, r)
+			case "reserve":
+				switch r.Method {
+				case http.MethodPost:
+					Chain(reserveCarHandler, AuthMiddleware)(w, r)
+				case http.MethodDelete:
+					Chain(cancelReservationHandler, AuthMiddleware)(w, r)
+				default:
+					respondError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+				}
+			case "reserve/confirm":
+				Chain(confirmReservationHandler, AuthMiddleware, MethodMiddleware("POST"))(w, r)
+			case "restore":
+				Chain(restoreCarHandler, AuthMiddleware, MethodMiddleware("POST"))(w, r)
+			case "view-trend":
+				Chain(viewTrendHandler, AuthMiddleware, MethodMiddleware("GET"))(w, r)
+			case "boost":
+				Chain(boostCarHandler, AuthMiddleware, MethodMiddleware("POST"))(w, r)
+			case "embed":
+				// Public: rendered on dealer sites that don't hold a JWT
+				Chain(embedCarHandler, MethodMiddleware("GET"))(w, r)
+			case "image":
+				Chain(uploadImageHandler, AuthMiddleware, MethodMiddleware("POST"))(w, r)
+			case "share":
+				Chain(shareCarHandler, AuthMiddleware, MethodMiddleware("POST"))(w, r)
+			case "offer":
+				Chain(makeOfferHandler, AuthMiddleware, MethodMiddleware("POST"))(w, r)
+			case "offers":
+				Chain(listOffersHandler, AuthMiddleware, MethodMiddleware("GET"))(w, r)
+			case "comments":
+				switch r.Method {
+				case http.MethodGet:
+					Chain(listCommentsHandler, AuthMiddleware)(w, r)
+				case http.MethodPost:
+					Chain(addCommentHandler, AuthMiddleware)(w, r)
+				default:
+					respondError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+				}
 			default:
-				respond(w, http.StatusMethodNotAllowed, nil, "method not allowed")
+				if strings.HasPrefix(carAction(r.URL.Path), "comments/") {
+					Chain(deleteCommentHandler, AuthMiddleware, MethodMiddleware("DELETE"))(w, r)
+					return
+				}
+				respondError(w, http.StatusNotFound, errCodeNotFound, "not found")
 			}
 		}))
 
+	// GET /api/new-arrivals — most recently listed active cars
+	mux.HandleFunc("/api/new-arrivals",
+		LoggingMiddleware(Chain(newArrivalsHandler,
+			AuthMiddleware,
+			MethodMiddleware("GET"),
+		)))
+
+	// GET /api/sold-archive — sold listings, most recently sold first
+	mux.HandleFunc("/api/sold-archive",
+		LoggingMiddleware(Chain(soldArchiveHandler,
+			AuthMiddleware,
+			MethodMiddleware("GET"),
+		)))
+
+	// GET /api/me/activity — feed of events on the caller's listings
+	mux.HandleFunc("/api/me/activity",
+		LoggingMiddleware(Chain(activityHandler,
+			AuthMiddleware,
+			MethodMiddleware("GET"),
+		)))
+
+	// GET /api/models?make= — distinct models for a make
+	mux.HandleFunc("/api/models",
+		LoggingMiddleware(Chain(modelsHandler,
+			AuthMiddleware,
+			MethodMiddleware("GET"),
+		)))
 
 	// POST /api/valuate — rule-based car valuation engine
 	mux.HandleFunc("/api/valuate",
 		LoggingMiddleware(Chain(valuateHandler,
+			AuthMiddleware,
+			UserRateLimitMiddleware,
+			MethodMiddleware("POST"),
+		)))
+
+	// GET /api/valuate/brands — known makes and their base price tiers
+	mux.HandleFunc("/api/valuate/brands",
+		LoggingMiddleware(Chain(brandsHandler,
+			AuthMiddleware,
+			MethodMiddleware("GET"),
+		)))
+
+	// POST /api/valuate/batch — value many cars in one request
+	mux.HandleFunc("/api/valuate/batch",
+		LoggingMiddleware(Chain(batchValuateHandler,
+			AuthMiddleware,
+			UserRateLimitMiddleware,
+			MethodMiddleware("POST"),
+		)))
+
+	// POST /api/valuate/sensitivity — estimate curve over one varying field
+	mux.HandleFunc("/api/valuate/sensitivity",
+		LoggingMiddleware(Chain(sensitivityHandler,
+			AuthMiddleware,
+			UserRateLimitMiddleware,
+			MethodMiddleware("POST"),
+		)))
+
+	// GET /api/valuate/messages — localized valuation factor strings
+	mux.HandleFunc("/api/valuate/messages",
+		LoggingMiddleware(Chain(valuationMessagesHandler,
+			AuthMiddleware,
+			MethodMiddleware("GET"),
+		)))
+
+	// GET /api/valuate/confidence — confidence distribution across the store
+	mux.HandleFunc("/api/valuate/confidence",
+		LoggingMiddleware(Chain(confidenceDistributionHandler,
+			AuthMiddleware,
+			MethodMiddleware("GET"),
+		)))
+
+	// POST /api/finance — loan payment estimate and amortization schedule
+	mux.HandleFunc("/api/finance",
+		LoggingMiddleware(Chain(financeHandler,
 			AuthMiddleware,
 			MethodMiddleware("POST"),
 		)))
@@ -114,29 +393,83 @@ func main() {
 			MethodMiddleware("GET"),
 		)))
 
+	// GET /api/stats/sales — ask vs sale discount on sold listings
+	mux.HandleFunc("/api/stats/sales",
+		LoggingMiddleware(Chain(salesStatsHandler,
+			AuthMiddleware,
+			MethodMiddleware("GET"),
+		)))
+
+	// GET /api/stats/seller/{username} — overview scoped to one seller
+	mux.HandleFunc("/api/stats/seller/",
+		LoggingMiddleware(Chain(sellerStatsHandler,
+			AuthMiddleware,
+			MethodMiddleware("GET"),
+		)))
+
+	// GET|PUT /api/admin/valuation/base-prices — retune valuation tiers at runtime
+	mux.HandleFunc("/api/admin/valuation/base-prices",
+		LoggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet:
+				Chain(getBasePricesHandler, AuthMiddleware, AdminMiddleware)(w, r)
+			case http.MethodPut:
+				Chain(putBasePricesHandler, AuthMiddleware, AdminMiddleware)(w, r)
+			default:
+				respondError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+			}
+		}))
+
+	// POST|GET|DELETE /api/admin/valuations/recompute — start, poll or
+	// cancel the background job that refreshes cached valuations
+	mux.HandleFunc("/api/admin/valuations/recompute",
+		LoggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodPost:
+				Chain(startRecomputeHandler, AuthMiddleware, AdminMiddleware)(w, r)
+			case http.MethodGet:
+				Chain(recomputeStatusHandler, AuthMiddleware, AdminMiddleware)(w, r)
+			case http.MethodDelete:
+				Chain(cancelRecomputeHandler, AuthMiddleware, AdminMiddleware)(w, r)
+			default:
+				respondError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
+			}
+		}))
+
+	// GET /api/admin/validate-store — listings that fail current validation
+	mux.HandleFunc("/api/admin/validate-store",
+		LoggingMiddleware(Chain(validateStoreHandler,
+			AuthMiddleware,
+			AdminMiddleware,
+			MethodMiddleware("GET"),
+		)))
+
+	// POST /api/admin/impersonate — short-lived token acting as a seller
+	mux.HandleFunc("/api/admin/impersonate",
+		LoggingMiddleware(Chain(impersonateHandler,
+			AuthMiddleware,
+			AdminMiddleware,
+			MethodMiddleware("POST"),
+		)))
+
 	// Configured to only accept requests from our own origin.
 	// In production, set allowedOrigins to your actual domain.
 	c := cors.New(cors.Options{
 		AllowedOrigins:   allowedOrigins,
-		AllowedMethods:   []string{"GET", "POST", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Content-Type", "Authorization"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Content-Type", "Authorization", "X-API-Key", "X-Request-ID", "traceparent"},
+		ExposedHeaders:   []string{"X-Request-ID"},
 		AllowCredentials: true,
 		MaxAge:           300, // cache preflight for 5 minutes
 	})
 
-	srv := &http.Server{
-		Addr:         serverAddr,
-		Handler:      c.Handler(mux),
-		ReadTimeout:  serverReadTTO,
-		WriteTimeout: serverWriteTTO,
-		IdleTimeout:  serverIdleTTO,
-	}
-
-	log.Println("  APEX MOTORS  →  http://localhost:5001      ")
-	log.Println("  Login:  seller / carmarket123              ")
-	
+	// Global middleware, applied to every request before routing
+	handler := Chain(TrailingSlashMiddleware(mux),
+		RecoverMiddleware,
+		SecurityHeadersMiddleware,
+		RequestIDMiddleware,
+		ConcurrencyLimitMiddleware,
+	)
 
-	if err := srv.ListenAndServe(); err != nil {
-		log.Fatal(err)
-	}
+	return c.Handler(handler)
 }
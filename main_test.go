@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// serveAPI sends a request through the full router, authenticated as user
+// with a real access token unless user is "".
+func serveAPI(t *testing.T, method, target, user string) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(method, target, nil)
+	if user != "" {
+		token, _, err := generateTokenPair(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	newRouter().ServeHTTP(rec, r)
+	return rec
+}
+
+func TestCarsRoutesTrailingSlash(t *testing.T) {
+	resetRateLimiter(t)
+	useStore(t, CarListing{ID: 5, Make: "Porsche"}, CarListing{ID: 6, Make: "BMW"})
+
+	for _, path := range []string{"/api/cars", "/api/cars/"} {
+		rec := serveAPI(t, "GET", path, "alice")
+		if rec.Code != http.StatusOK {
+			t.Errorf("GET %s = %d, want 200", path, rec.Code)
+			continue
+		}
+		var got struct {
+			Count int `json:"count"`
+		}
+		decodeData(t, rec, &got)
+		if got.Count != 2 {
+			t.Errorf("GET %s listed %d cars, want 2", path, got.Count)
+		}
+	}
+
+	rec := serveAPI(t, "GET", "/api/cars/5", "alice")
+	var car CarListing
+	decodeData(t, rec, &car)
+	if rec.Code != http.StatusOK || car.ID != 5 || car.Make != "Porsche" {
+		t.Errorf("GET /api/cars/5 = %d, car %d %q; want the Porsche", rec.Code, car.ID, car.Make)
+	}
+	if rec := serveAPI(t, "GET", "/api/cars/99", "alice"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /api/cars/99 = %d, want 404", rec.Code)
+	}
+	if rec := serveAPI(t, "POST", "/api/cars/", "alice"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST /api/cars/ = %d, want 405", rec.Code)
+	}
+	if rec := serveAPI(t, "GET", "/api/cars/", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("anonymous GET /api/cars/ = %d, want 401", rec.Code)
+	}
+}
+
+func TestTrailingSlashCanonicalized(t *testing.T) {
+	resetRateLimiter(t)
+	useStore(t, CarListing{Seller: "alice", Make: "BMW", Model: "M3", Price: 70000})
+
+	for _, path := range []string{"/api/stats", "/api/stats/sales", "/api/new-arrivals", "/api/valuate/brands", "/api/health", "/api/me/activity"} {
+		for _, target := range []string{path, path + "/", path + "//"} {
+			rec := serveAPI(t, "GET", target, "alice")
+			if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/json") {
+				t.Errorf("GET %s = %d %s, want the 200 JSON answer of %s", target, rec.Code, rec.Header().Get("Content-Type"), path)
+			}
+		}
+	}
+
+	// Methods still apply to the canonical route
+	if rec := serveAPI(t, "DELETE", "/api/stats/", "alice"); rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("DELETE /api/stats/ = %d, want 405", rec.Code)
+	}
+	// Routes registered with their slash keep it
+	if rec := serveAPI(t, "GET", "/api/stats/seller/alice", "alice"); rec.Code != http.StatusOK {
+		t.Errorf("GET /api/stats/seller/alice = %d, want 200", rec.Code)
+	}
+	if rec := serveAPI(t, "GET", "/api/stats/seller/", "alice"); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /api/stats/seller/ = %d, want 400 from the seller stats handler", rec.Code)
+	}
+	// Unknown routes aren't rescued by trimming
+	if rec := serveAPI(t, "GET", "/api/nope/", "alice"); rec.Code != http.StatusNotFound {
+		t.Errorf("GET /api/nope/ = %d, want 404", rec.Code)
+	}
+}
+
+func TestSPAFallback(t *testing.T) {
+	resetRateLimiter(t)
+	index, err := os.ReadFile(filepath.Join("static", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{"/", "/some/spa/route", "/garage/42"} {
+		rec := serveAPI(t, "GET", path, "")
+		if rec.Code != http.StatusOK || rec.Body.String() != string(index) {
+			t.Errorf("GET %s = %d, want index.html", path, rec.Code)
+		}
+	}
+
+	rec := serveAPI(t, "GET", "/api/unknown", "")
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), errCodeNotFound) {
+		t.Errorf("GET /api/unknown = %d %s, want a JSON 404", rec.Code, rec.Body.String())
+	}
+	rec = serveAPI(t, "GET", "/static/missing.js", "")
+	if rec.Code != http.StatusNotFound || rec.Body.String() == string(index) {
+		t.Errorf("GET /static/missing.js = %d, want 404 without the index page", rec.Code)
+	}
+	if rec := serveAPI(t, "GET", "/static/hero-bg.png", ""); rec.Code != http.StatusOK {
+		t.Errorf("GET /static/hero-bg.png = %d, want the real asset", rec.Code)
+	}
+	// Only page loads fall back
+	if rec := serveAPI(t, "POST", "/some/spa/route", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("POST /some/spa/route = %d, want 404", rec.Code)
+	}
+}
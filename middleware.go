@@ -2,18 +2,27 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"math"
+	"net"
 	"net/http"
+	"os"
+	"runtime/debug"
+	"strconv"
 	"strings"
 	"time"
-)
 
+	"github.com/golang-jwt/jwt/v5"
+)
 
 // Middleware wraps an http.HandlerFunc and returns a new one.
 // This lets us compose behaviors cleanly without nesting callbacks.
-type Middleware func(http.HandlerFunc) http.Handlerfunc
-
-
+type Middleware func(http.HandlerFunc) http.HandlerFunc
 
 // Chain applies a list of middlewares to a handler.
 // Declaration order = execution order (first listed = outermost wrapper).
@@ -30,18 +39,216 @@ func Chain(h http.HandlerFunc, mws ...Middleware) http.HandlerFunc {
 	return h
 }
 
+// ─── Recover Middleware ───────────────────────────────────────────────────────
+
+// RecoverMiddleware turns a panic anywhere downstream into a logged stack
+// trace and a 500 response, instead of a dropped connection. Wired as the
+// outermost global middleware so it covers every route. http.ErrAbortHandler
+// is re-panicked, since it is net/http's deliberate way to abort a response.
+func RecoverMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			if err := recover(); err != nil {
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				log.Printf("PANIC %s %s: %v\n%s", r.Method, r.URL.Path, err, debug.Stack())
+				respondError(w, http.StatusInternalServerError, errCodeInternal, "internal server error")
+			}
+		}()
+		next(w, r)
+	}
+}
+
+// ─── Security Headers Middleware ──────────────────────────────────────────────
+
+// SecurityHeadersMiddleware sets baseline hardening headers on every
+// response: no MIME sniffing, no framing, and a referrer policy that keeps
+// paths private cross-origin. Pages and static assets (anything outside
+// /api/) also get contentSecurityPolicy. Handlers that must be framable,
+// like the listing embed, remove X-Frame-Options themselves.
+func SecurityHeadersMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Set("X-Content-Type-Options", "nosniff")
+		h.Set("X-Frame-Options", "DENY")
+		h.Set("Referrer-Policy", "strict-origin-when-cross-origin")
+		if contentSecurityPolicy != "" && !strings.HasPrefix(r.URL.Path, "/api/") {
+			h.Set("Content-Security-Policy", contentSecurityPolicy)
+		}
+		next(w, r)
+	}
+}
+
+// ─── Request ID Middleware ────────────────────────────────────────────────────
+
+// RequestIDMiddleware tags every request with an ID, stored in the context
+// and echoed in the X-Request-ID response header. An incoming X-Request-ID,
+// or failing that the trace ID from a traceparent header, is reused when
+// trustIncomingRequestID is set and the value passes validRequestID;
+// otherwise a fresh ID is generated.
+func RequestIDMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := ""
+		if trustIncomingRequestID {
+			if v := r.Header.Get("X-Request-ID"); validRequestID(v) {
+				id = v
+			} else if v := traceIDFrom(r.Header.Get("traceparent")); v != "" {
+				id = v
+			}
+		}
+		if id == "" {
+			id = newRequestID()
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		ctx := context.WithValue(r.Context(), ctxKey("request_id"), id)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// validRequestID accepts 1–128 characters of [A-Za-z0-9._-]. Anything else
+// is dropped rather than copied into log lines, which rules out log
+// injection via newlines or control characters.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9',
+			c == '.', c == '_', c == '-':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// traceIDFrom extracts the trace ID from a W3C traceparent header
+// ("00-<32 hex trace-id>-<16 hex parent-id>-<2 hex flags>"), or "" when the
+// header is missing or malformed. An all-zero trace ID is invalid per spec.
+func traceIDFrom(traceparent string) string {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 {
+		return ""
+	}
+	traceID := strings.ToLower(parts[1])
+	if _, err := hex.DecodeString(traceID); err != nil || strings.Trim(traceID, "0") == "" {
+		return ""
+	}
+	return traceID
+}
+
+// newRequestID returns 16 random bytes, hex-encoded.
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%x", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
+
+// requestIDFrom returns the ID RequestIDMiddleware attached to r, or "-".
+func requestIDFrom(r *http.Request) string {
+	if id, ok := r.Context().Value(ctxKey("request_id")).(string); ok {
+		return id
+	}
+	return "-"
+}
+
 // ─── Logging Middleware ───────────────────────────────────────────────────────
 
-// LoggingMiddleware logs the method, path, client IP, and response duration.
+// newLogger builds the process-wide structured logger in logFormat. Installed
+// with slog.SetDefault, it also formats plain log.Printf output.
+func newLogger() *slog.Logger {
+	if logFormat == "json" {
+		return slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	}
+	return slog.New(slog.NewTextHandler(os.Stderr, nil))
+}
+
+// LoggingMiddleware logs a structured record when a request arrives (method,
+// path, ip, request_id) and another when it completes (adding status and
+// duration_ms), so the two lines of concurrent requests pair up by
+// request_id. The same duration is exposed to the client as a Server-Timing
+// header so it shows up in browser dev tools.
 func LoggingMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		log.Printf("→ %s %s [%s]", r.Method, r.URL.Path, getIP(r))
-		next(w, r)
-		log.Printf("← %s %s (%v)", r.Method, r.URL.Path, time.Since(start))
+		attrs := []interface{}{
+			"method", r.Method,
+			"path", r.URL.Path,
+			"ip", getIP(r),
+			"request_id", requestIDFrom(r),
+		}
+		slog.Info("request", attrs...)
+
+		if serverTimingEnabled {
+			w = &timingWriter{ResponseWriter: w, start: start}
+		}
+		rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+
+		ms := float64(time.Since(start).Microseconds()) / 1000
+		slog.Info("response", append(attrs, "status", rec.status, "duration_ms", ms)...)
 	}
 }
 
+// statusRecorder remembers the status code a handler sends, which
+// http.ResponseWriter doesn't expose. A handler that never calls WriteHeader
+// gets the implicit 200. LoggingMiddleware passes it down the chain, and it
+// and timingWriter implement Unwrap so handlers can still use
+// http.ResponseController (Flush, deadlines) through the wrappers.
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	if !sr.wroteHeader {
+		sr.wroteHeader = true
+		sr.status = code
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	sr.wroteHeader = true
+	return sr.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (sr *statusRecorder) Unwrap() http.ResponseWriter { return sr.ResponseWriter }
+
+// timingWriter adds a Server-Timing header just before the response headers
+// are sent — the last moment a header can still be set — reporting the time
+// spent handling the request up to that point, e.g. "app;dur=12.3".
+type timingWriter struct {
+	http.ResponseWriter
+	start       time.Time
+	wroteHeader bool
+}
+
+func (tw *timingWriter) WriteHeader(code int) {
+	if !tw.wroteHeader {
+		tw.wroteHeader = true
+		ms := float64(time.Since(tw.start).Microseconds()) / 1000
+		tw.Header().Set("Server-Timing", fmt.Sprintf("app;dur=%.1f", ms))
+	}
+	tw.ResponseWriter.WriteHeader(code)
+}
+
+func (tw *timingWriter) Write(b []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	return tw.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (tw *timingWriter) Unwrap() http.ResponseWriter { return tw.ResponseWriter }
 
 // MethodMiddleware rejects requests that don't match the allowed HTTP method.
 // OPTIONS is always allowed so CORS preflight passes through.
@@ -49,7 +256,7 @@ func MethodMiddleware(method string) Middleware {
 	return func(next http.HandlerFunc) http.HandlerFunc {
 		return func(w http.ResponseWriter, r *http.Request) {
 			if r.Method != method && r.Method != http.MethodOptions {
-				respond(w, http.StatusMethodNotAllowed, nil, "method not allowed")
+				respondError(w, http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "method not allowed")
 				return
 			}
 			next(w, r)
@@ -57,74 +264,339 @@ func MethodMiddleware(method string) Middleware {
 	}
 }
 
-
-// RateLimitMiddleware uses a sliding window to cap requests per IP.
-// Applied to auth endpoints to prevent brute-force attacks.
-func RateLimitMiddleware(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		if isRateLimited(getIP(r)) {
-			w.Header().Set("Retry-After", "60")
-			respond(w, http.StatusTooManyRequests, nil, "rate limit exceeded — retry in 60s")
-			return
+// RateLimitBy builds a token-bucket rate limiter keyed by keyFunc, so each
+// route chooses the dimension it limits on (ipKey, userKey). The key's
+// "ip:"/"user:" prefix selects the budget, see rateLimitFor. Rejected
+// requests get Retry-After set to the time until the next token.
+func RateLimitBy(keyFunc func(*http.Request) string) Middleware {
+	return func(next http.HandlerFunc) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			limited, wait := isRateLimited(keyFunc(r))
+			if limited {
+				retry := int(math.Ceil(wait.Seconds()))
+				w.Header().Set("Retry-After", retryAfterValue(retry, time.Now()))
+				respondError(w, http.StatusTooManyRequests, errCodeRateLimited, fmt.Sprintf("rate limit exceeded — retry in %ds", retry))
+				return
+			}
+			next(w, r)
 		}
-		next(w, r)
 	}
 }
 
+// retryAfterValue formats a Retry-After header for a wait of seconds from
+// now: delta-seconds by default, or an HTTP-date (RFC 9110 §10.2.3) when
+// retryAfterHTTPDate is set. The date is rounded up to the next whole second
+// so a client honouring it never retries early.
+func retryAfterValue(seconds int, now time.Time) string {
+	if !retryAfterHTTPDate {
+		return strconv.Itoa(seconds)
+	}
+	at := now.Add(time.Duration(seconds) * time.Second)
+	if at.Truncate(time.Second).Before(at) {
+		at = at.Truncate(time.Second).Add(time.Second)
+	}
+	return at.UTC().Format(http.TimeFormat)
+}
+
+// RateLimitMiddleware caps requests per IP. Applied to auth endpoints to
+// prevent brute-force attacks.
+var RateLimitMiddleware = RateLimitBy(ipKey)
+
+// UserRateLimitMiddleware caps requests per authenticated user rather than
+// per IP, so users sharing a NAT don't share a budget and an attacker
+// rotating IPs doesn't get a fresh one. Must run after AuthMiddleware.
+// Applied to expensive endpoints like valuation.
+var UserRateLimitMiddleware = RateLimitBy(userKey)
+
+// ipKey is the rate limiter key for the client IP.
+func ipKey(r *http.Request) string {
+	return "ip:" + getIP(r)
+}
+
+// userKey is the rate limiter key for the authenticated user, falling back
+// to the client IP for requests without claims.
+func userKey(r *http.Request) string {
+	if claims, ok := r.Context().Value(ctxKey("claims")).(*Claims); ok {
+		return "user:" + claims.Username
+	}
+	return ipKey(r)
+}
+
+// rateLimit is the token-bucket budget for one rate-limiting dimension.
+type rateLimit struct {
+	burst     float64 // bucket capacity
+	refillSec float64 // tokens added per second
+}
 
-// AuthMiddleware validates the JWT access token in the Authorization header.
-// On success it injects the parsed Claims into the request context so
-// downstream handlers can read the username without re-parsing the token.
+// rateLimitFor returns the budget for a limiter key by its dimension prefix.
+func rateLimitFor(key string) rateLimit {
+	if strings.HasPrefix(key, "user:") {
+		return rateLimit{burst: userRateLimitBurst, refillSec: userRateLimitRefillPerSec}
+	}
+	return rateLimit{burst: rateLimitBurst, refillSec: rateLimitRefillPerSec}
+}
+
+// AuthMiddleware validates the JWT access token in the Authorization header,
+// or a static X-API-Key when apiKeys are configured. On success it injects
+// the parsed Claims into the request context so downstream handlers can read
+// the username without re-parsing the token.
 func AuthMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		if key := r.Header.Get("X-API-Key"); key != "" && len(apiKeys) > 0 {
+			claims, ok := validateAPIKey(key)
+			if !ok {
+				respondError(w, http.StatusUnauthorized, errCodeUnauthorized, "invalid API key")
+				return
+			}
+			ctx := context.WithValue(r.Context(), ctxKey("claims"), claims)
+			next(w, r.WithContext(ctx))
+			return
+		}
+
 		authHeader := r.Header.Get("Authorization")
 		if authHeader == "" {
-			respond(w, http.StatusUnauthorized, nil, "authorization header required")
+			respondError(w, http.StatusUnauthorized, errCodeUnauthorized, "authorization header required")
 			return
 		}
 
 		// Strip the "Bearer " prefix before validating
 		tokenStr := strings.TrimPrefix(authHeader, "Bearer ")
 		claims, err := validateJWT(tokenStr, "access")
+		if errors.Is(err, jwt.ErrTokenNotValidYet) {
+			respondError(w, http.StatusUnauthorized, errCodeUnauthorized, "token not yet valid")
+			return
+		}
 		if err != nil {
-			respond(w, http.StatusUnauthorized, nil, "invalid or expired access token")
+			respondError(w, http.StatusUnauthorized, errCodeUnauthorized, "invalid or expired access token")
 			return
 		}
 
+		if claims.ImpersonatedBy != "" {
+			log.Printf("AUDIT %s %s as %s (impersonated by %s)", r.Method, r.URL.Path, claims.Username, claims.ImpersonatedBy)
+		}
+
 		// Store claims in context so handlers can access them without re-parsing
 		ctx := context.WithValue(r.Context(), ctxKey("claims"), claims)
 		next(w, r.WithContext(ctx))
 	}
 }
 
+// ConcurrencyLimitMiddleware caps the number of in-flight requests per IP, so
+// one client holding hundreds of slow connections can't exhaust the server.
+// Complements the token-bucket limiter (RateLimitBy), which only meters
+// request starts.
+// Disabled when maxConcurrentPerIP is 0.
+func ConcurrencyLimitMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if maxConcurrentPerIP <= 0 {
+			next(w, r)
+			return
+		}
+
+		ip := getIP(r)
+		if !acquireSlot(ip) {
+			respondError(w, http.StatusTooManyRequests, errCodeRateLimited, "too many concurrent requests")
+			return
+		}
+		defer releaseSlot(ip)
+		next(w, r)
+	}
+}
+
+// TrailingSlashMiddleware canonicalizes trailing slashes on /api/ routes so
+// /api/stats/ behaves exactly like /api/stats instead of falling through to
+// the index page. The request is rewritten internally (no redirect, so POST
+// bodies survive). Paths the mux already routes with their slash — like the
+// /api/cars/{id} subtree — are left alone.
+func TrailingSlashMiddleware(mux *http.ServeMux) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := r.URL.Path
+		if strings.HasPrefix(p, "/api/") && len(p) > len("/api/") && strings.HasSuffix(p, "/") {
+			if _, pattern := mux.Handler(r); pattern == "/" {
+				trimmed := strings.TrimRight(p, "/")
+				r2 := r.Clone(r.Context())
+				r2.URL.Path = trimmed
+				r2.URL.RawPath = ""
+				if _, pattern := mux.Handler(r2); pattern == trimmed {
+					r = r2
+				}
+			}
+		}
+		mux.ServeHTTP(w, r)
+	}
+}
+
+// AdminMiddleware rejects authenticated users who aren't administrators.
+// Must run after AuthMiddleware so the claims are in the context.
+func AdminMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := r.Context().Value(ctxKey("claims")).(*Claims)
+		if !ok || !isAdmin(claims.Username) {
+			respondError(w, http.StatusForbidden, errCodeForbidden, "admin access required")
+			return
+		}
+		next(w, r)
+	}
+}
 
-// isRateLimited uses a sliding window to count requests per IP per minute.
-// Returns true if the IP has exceeded rateLimitMax requests.
-func isRateLimited(ip string) bool {
+// isRateLimited takes a token from key's bucket, refilled at the rate of the
+// key's dimension and capped at its burst. Keys are namespaced ("ip:…",
+// "user:…") so IP and user buckets never collide. Returns true, with the
+// time until the next token, if the bucket is empty. O(1) per check.
+func isRateLimited(key string) (limited bool, retryAfter time.Duration) {
 	rateLimiterMu.Lock()
 	defer rateLimiterMu.Unlock()
 
+	limit := rateLimitFor(key)
 	now := time.Now()
-	windowStart := now.Add(-rateLimitWindow)
 
-	// Keep only timestamps within the current window
-	var fresh []time.Time
-	for _, t := range rateLimiter[ip] {
-		if t.After(windowStart) {
-			fresh = append(fresh, t)
-		}
+	b, ok := rateLimiter[key]
+	if !ok {
+		b = &tokenBucket{tokens: limit.burst, lastRefill: now}
+		rateLimiter[key] = b
+	}
+	refill(b, limit, now)
+
+	if b.tokens < 1 {
+		wait := (1 - b.tokens) / limit.refillSec
+		return true, time.Duration(wait * float64(time.Second))
 	}
-	fresh = append(fresh, now)
-	rateLimiter[ip] = fresh
+	b.tokens--
+	return false, 0
+}
 
-	return len(fresh) > rateLimitMax
+// refill credits b with the tokens earned since its last refill, up to the
+// burst capacity.
+func refill(b *tokenBucket, limit rateLimit, now time.Time) {
+	if !now.After(b.lastRefill) {
+		return
+	}
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.tokens = math.Min(limit.burst, b.tokens+elapsed*limit.refillSec)
+	b.lastRefill = now
 }
 
-// getIP extracts the client IP from the request, honouring X-Forwarded-For
-// for requests that pass through a reverse proxy.
+// startRateLimitJanitor periodically drops rate limiter keys that have gone
+// quiet. isRateLimited only touches the key it is asked about, so without this
+// every client that ever made a request would stay in the map forever.
+func startRateLimitJanitor() {
+	go func() {
+		for now := range time.Tick(rateLimitSweepInterval) {
+			pruneRateLimiter(now)
+		}
+	}()
+}
+
+// pruneRateLimiter removes every key whose bucket has refilled to capacity
+// by now — forgetting it is then indistinguishable from keeping it — and
+// reports how many were removed.
+func pruneRateLimiter(now time.Time) int {
+	rateLimiterMu.Lock()
+	defer rateLimiterMu.Unlock()
+
+	removed := 0
+	for key, b := range rateLimiter {
+		limit := rateLimitFor(key)
+		refill(b, limit, now)
+		if b.tokens >= limit.burst {
+			delete(rateLimiter, key)
+			removed++
+		}
+	}
+	return removed
+}
+
+// getIP extracts the client IP from the request. Proxy headers are only
+// honoured when the direct peer is in trustedProxies; they are checked in
+// trustedIPHeaders order and the first one holding a valid IP wins.
+// Supports X-Forwarded-For, X-Real-IP and RFC 7239 Forwarded.
 func getIP(r *http.Request) string {
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		return strings.Split(xff, ",")[0]
+	peer := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	if !isTrustedProxy(peer) {
+		return peer
+	}
+
+	for _, name := range trustedIPHeaders {
+		value := r.Header.Get(name)
+		if value == "" {
+			continue
+		}
+		var ip string
+		switch http.CanonicalHeaderKey(name) {
+		case "Forwarded":
+			ip = forwardedFor(value)
+		default:
+			// X-Forwarded-For is "client, proxy1, proxy2" — the first hop is the client
+			ip = stripPort(strings.TrimSpace(strings.Split(value, ",")[0]))
+		}
+		if net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+	return peer
+}
+
+// forwardedFor returns the for= address of the first element of an RFC 7239
+// Forwarded header, e.g. `for="[2001:db8::17]:4711";proto=https` → 2001:db8::17.
+// Obfuscated or "unknown" identifiers yield "".
+func forwardedFor(header string) string {
+	first := strings.Split(header, ",")[0]
+	for _, pair := range strings.Split(first, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || !strings.EqualFold(key, "for") {
+			continue
+		}
+		return stripPort(strings.Trim(value, `"`))
+	}
+	return ""
+}
+
+// stripPort removes an optional port and IPv6 brackets from an address:
+// "1.2.3.4:80" → "1.2.3.4", "[::1]:80" → "::1", "[::1]" → "::1".
+func stripPort(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return strings.TrimSuffix(strings.TrimPrefix(addr, "["), "]")
+}
+
+// isTrustedProxy reports whether ip falls inside one of the trustedProxies CIDRs.
+func isTrustedProxy(ip string) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, cidr := range trustedProxies {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// acquireSlot reserves an in-flight slot for ip, returning false when the
+// IP is already at maxConcurrentPerIP.
+func acquireSlot(ip string) bool {
+	inFlightMu.Lock()
+	defer inFlightMu.Unlock()
+
+	if inFlight[ip] >= maxConcurrentPerIP {
+		return false
+	}
+	inFlight[ip]++
+	return true
+}
+
+// releaseSlot frees a slot taken by acquireSlot. Idle IPs are removed from
+// the map entirely so it only ever holds clients with requests in flight.
+func releaseSlot(ip string) {
+	inFlightMu.Lock()
+	defer inFlightMu.Unlock()
+
+	if inFlight[ip]--; inFlight[ip] <= 0 {
+		delete(inFlight, ip)
 	}
-	return strings.Split(r.RemoteAddr, ":")[0]
 }
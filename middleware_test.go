@@ -0,0 +1,440 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// resetRateLimiter gives the test an empty rate limiter store.
+func resetRateLimiter(t testing.TB) {
+	t.Helper()
+	saved := rateLimiter
+	t.Cleanup(func() { rateLimiter = saved })
+	rateLimiter = make(map[string]*tokenBucket)
+}
+
+// okHandler answers 204, standing in for a real endpoint behind middleware.
+func okHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// hit sends one GET from ip through h as user ("" for anonymous) and
+// returns the status code.
+func hit(h http.HandlerFunc, ip, user string) int {
+	r := httptest.NewRequest("GET", "/api/valuate", nil)
+	r.RemoteAddr = ip + ":40000"
+	if user != "" {
+		r = asUser(r, user)
+	}
+	rec := httptest.NewRecorder()
+	h(rec, r)
+	return rec.Code
+}
+
+func TestUserRateLimitIndependentBudgets(t *testing.T) {
+	resetRateLimiter(t)
+	h := Chain(okHandler, UserRateLimitMiddleware)
+
+	// Two users behind the same NAT address
+	const ip = "203.0.113.7"
+	for i := 0; i < userRateLimitBurst; i++ {
+		if code := hit(h, ip, "alice"); code != http.StatusNoContent {
+			t.Fatalf("alice request %d = %d, want 204", i+1, code)
+		}
+	}
+	if code := hit(h, ip, "alice"); code != http.StatusTooManyRequests {
+		t.Fatalf("alice past burst = %d, want 429", code)
+	}
+	for i := 0; i < userRateLimitBurst; i++ {
+		if code := hit(h, ip, "bob"); code != http.StatusNoContent {
+			t.Fatalf("bob request %d = %d, want 204 (own budget)", i+1, code)
+		}
+	}
+}
+
+func TestIPRateLimitSharedAcrossUsers(t *testing.T) {
+	resetRateLimiter(t)
+	h := Chain(okHandler, RateLimitMiddleware)
+
+	const ip = "203.0.113.8"
+	for i := 0; i < rateLimitBurst; i++ {
+		user := "alice"
+		if i%2 == 1 {
+			user = "bob"
+		}
+		if code := hit(h, ip, user); code != http.StatusNoContent {
+			t.Fatalf("request %d = %d, want 204", i+1, code)
+		}
+	}
+	if code := hit(h, ip, "carol"); code != http.StatusTooManyRequests {
+		t.Errorf("IP past burst = %d, want 429 regardless of user", code)
+	}
+	if code := hit(h, "203.0.113.9", "carol"); code != http.StatusNoContent {
+		t.Errorf("another IP = %d, want 204", code)
+	}
+}
+
+func TestConcurrencyLimitPerIP(t *testing.T) {
+	saved := maxConcurrentPerIP
+	t.Cleanup(func() { maxConcurrentPerIP = saved })
+	maxConcurrentPerIP = 3
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	h := ConcurrencyLimitMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	const ip = "198.51.100.4"
+	codes := make(chan int, maxConcurrentPerIP)
+	for i := 0; i < maxConcurrentPerIP; i++ {
+		go func() { codes <- hit(h, ip, "") }()
+		<-started
+	}
+
+	// All N slots are held: request N+1 from the same IP is turned away,
+	// while other clients are unaffected
+	if code := hit(h, ip, ""); code != http.StatusTooManyRequests {
+		t.Errorf("request %d = %d, want 429", maxConcurrentPerIP+1, code)
+	}
+	other := make(chan int, 1)
+	go func() { other <- hit(h, "198.51.100.5", "") }()
+	<-started // got a slot of its own
+
+	close(release)
+	for i := 0; i < maxConcurrentPerIP; i++ {
+		if code := <-codes; code != http.StatusNoContent {
+			t.Errorf("in-flight request = %d, want 204", code)
+		}
+	}
+	if code := <-other; code != http.StatusNoContent {
+		t.Errorf("other IP = %d, want 204", code)
+	}
+
+	inFlightMu.Lock()
+	left := len(inFlight)
+	inFlightMu.Unlock()
+	if left != 0 {
+		t.Errorf("inFlight holds %d IPs after all requests finished, want 0", left)
+	}
+}
+
+func TestServerTimingHeader(t *testing.T) {
+	format := regexp.MustCompile(`^app;dur=(\d+\.\d)$`)
+	handlers := map[string]http.HandlerFunc{
+		"implicit 200": func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(5 * time.Millisecond)
+			w.Write([]byte("ok"))
+		},
+		"explicit status": func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(5 * time.Millisecond)
+			respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		},
+	}
+	for name, h := range handlers {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			LoggingMiddleware(h)(rec, httptest.NewRequest("GET", "/api/cars/1", nil))
+
+			got := rec.Header().Get("Server-Timing")
+			m := format.FindStringSubmatch(got)
+			if m == nil {
+				t.Fatalf("Server-Timing = %q, want app;dur=<ms>", got)
+			}
+			if dur, _ := strconv.ParseFloat(m[1], 64); dur < 5 {
+				t.Errorf("dur = %v ms, want at least the 5ms the handler took", dur)
+			}
+		})
+	}
+}
+
+func TestGetIP(t *testing.T) {
+	tests := []struct {
+		name    string
+		peer    string
+		headers map[string]string
+		want    string
+	}{
+		{"direct client", "198.51.100.20:5123", nil, "198.51.100.20"},
+		{"untrusted peer ignores headers", "198.51.100.20:5123",
+			map[string]string{"X-Forwarded-For": "203.0.113.1"}, "198.51.100.20"},
+		{"X-Forwarded-For takes the first hop", "127.0.0.1:8080",
+			map[string]string{"X-Forwarded-For": "203.0.113.1, 10.0.0.2, 10.0.0.3"}, "203.0.113.1"},
+		{"X-Real-IP", "127.0.0.1:8080",
+			map[string]string{"X-Real-IP": "203.0.113.2"}, "203.0.113.2"},
+		{"Forwarded IPv4", "127.0.0.1:8080",
+			map[string]string{"Forwarded": "for=203.0.113.3;proto=https;by=10.0.0.1"}, "203.0.113.3"},
+		{"Forwarded quoted IPv6 with port", "[::1]:8080",
+			map[string]string{"Forwarded": `For="[2001:db8:cafe::17]:4711", for=10.0.0.2`}, "2001:db8:cafe::17"},
+		{"Forwarded bracketed IPv6", "127.0.0.1:8080",
+			map[string]string{"Forwarded": `for="[2001:db8::1]"`}, "2001:db8::1"},
+		{"Forwarded obfuscated falls back to peer", "127.0.0.1:8080",
+			map[string]string{"Forwarded": "for=_hidden"}, "127.0.0.1"},
+		{"X-Forwarded-For beats the others", "127.0.0.1:8080",
+			map[string]string{"X-Forwarded-For": "203.0.113.4", "X-Real-IP": "203.0.113.5", "Forwarded": "for=203.0.113.6"}, "203.0.113.4"},
+		{"invalid header falls through to the next", "127.0.0.1:8080",
+			map[string]string{"X-Forwarded-For": "not-an-ip", "Forwarded": "for=203.0.113.7"}, "203.0.113.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			r.RemoteAddr = tt.peer
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := getIP(r); got != tt.want {
+				t.Errorf("getIP = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	// The header order is configurable
+	saved := trustedIPHeaders
+	t.Cleanup(func() { trustedIPHeaders = saved })
+	trustedIPHeaders = []string{"Forwarded"}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "127.0.0.1:8080"
+	r.Header.Set("X-Forwarded-For", "203.0.113.8")
+	r.Header.Set("Forwarded", "for=203.0.113.9")
+	if got := getIP(r); got != "203.0.113.9" {
+		t.Errorf("Forwarded only: getIP = %q, want 203.0.113.9", got)
+	}
+}
+
+func TestRequestIDPropagation(t *testing.T) {
+	generated := regexp.MustCompile(`^[0-9a-f]{32}$`)
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string // "" means a freshly generated ID
+	}{
+		{"absent", nil, ""},
+		{"reused", map[string]string{"X-Request-ID": "gw-7f3a.01_b"}, "gw-7f3a.01_b"},
+		{"log injection", map[string]string{"X-Request-ID": "abc\nlevel=ERROR msg=forged"}, ""},
+		{"too long", map[string]string{"X-Request-ID": strings.Repeat("a", 129)}, ""},
+		{"traceparent", map[string]string{"traceparent": "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01"},
+			"4bf92f3577b34da6a3ce929d0e0e4736"},
+		{"X-Request-ID beats traceparent", map[string]string{
+			"X-Request-ID": "upstream-1",
+			"traceparent":  "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		}, "upstream-1"},
+		{"zero trace ID", map[string]string{"traceparent": "00-00000000000000000000000000000000-00f067aa0ba902b7-01"}, ""},
+		{"malformed traceparent", map[string]string{"traceparent": "00-xyz-01"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var seen string
+			h := RequestIDMiddleware(func(w http.ResponseWriter, r *http.Request) {
+				seen = requestIDFrom(r)
+			})
+			r := httptest.NewRequest("GET", "/api/cars", nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			h(rec, r)
+
+			echoed := rec.Header().Get("X-Request-ID")
+			if echoed != seen {
+				t.Errorf("header %q differs from the context ID %q", echoed, seen)
+			}
+			switch {
+			case tt.want != "" && seen != tt.want:
+				t.Errorf("request ID = %q, want %q", seen, tt.want)
+			case tt.want == "" && !generated.MatchString(seen):
+				t.Errorf("request ID = %q, want a generated one", seen)
+			}
+		})
+	}
+
+	saved := trustIncomingRequestID
+	t.Cleanup(func() { trustIncomingRequestID = saved })
+	trustIncomingRequestID = false
+	r := httptest.NewRequest("GET", "/api/cars", nil)
+	r.Header.Set("X-Request-ID", "upstream-1")
+	rec := httptest.NewRecorder()
+	RequestIDMiddleware(okHandler)(rec, r)
+	if id := rec.Header().Get("X-Request-ID"); !generated.MatchString(id) {
+		t.Errorf("untrusted incoming ID: got %q, want a generated one", id)
+	}
+}
+
+func TestPruneRateLimiter(t *testing.T) {
+	resetRateLimiter(t)
+	now := time.Now()
+	// Seconds needed to earn back one whole IP-bucket token
+	oneToken := time.Duration(float64(time.Second) / rateLimitRefillPerSec)
+
+	refilled := now.Add(-time.Duration(rateLimitBurst+1) * oneToken)
+	rateLimiter["ip:198.51.100.1"] = &tokenBucket{tokens: rateLimitBurst, lastRefill: now} // idle, full
+	rateLimiter["ip:198.51.100.2"] = &tokenBucket{tokens: 0, lastRefill: refilled}         // drained long ago
+	rateLimiter["ip:198.51.100.3"] = &tokenBucket{tokens: 0, lastRefill: now}              // just drained
+	rateLimiter["user:alice"] = &tokenBucket{tokens: userRateLimitBurst - 1, lastRefill: now}
+
+	if n := pruneRateLimiter(now); n != 2 {
+		t.Errorf("pruned %d keys, want 2", n)
+	}
+	for key, want := range map[string]bool{
+		"ip:198.51.100.1": false, "ip:198.51.100.2": false,
+		"ip:198.51.100.3": true, "user:alice": true,
+	} {
+		if _, kept := rateLimiter[key]; kept != want {
+			t.Errorf("%s kept = %v, want %v", key, kept, want)
+		}
+	}
+
+	// Pruning a refilled key doesn't change what its owner is allowed
+	if limited, _ := isRateLimited("ip:198.51.100.1"); limited {
+		t.Errorf("pruned client was rate limited on its next request")
+	}
+	// Once the drained bucket refills, it goes too
+	if n := pruneRateLimiter(now.Add(time.Duration(rateLimitBurst+1) * oneToken)); n != 3 {
+		t.Errorf("later sweep pruned %d keys, want 3", n)
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	// A panicking route behind the same global chain newRouter uses
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/boom", LoggingMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		var car *CarListing
+		_ = car.Make // nil dereference
+	}))
+	mux.HandleFunc("/api/ok", okHandler)
+	h := Chain(TrailingSlashMiddleware(mux), RecoverMiddleware, SecurityHeadersMiddleware, RequestIDMiddleware)
+
+	rec := httptest.NewRecorder()
+	h(rec, httptest.NewRequest("GET", "/api/boom", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	var env APIResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &env); err != nil {
+		t.Fatalf("body is not a JSON envelope: %q", rec.Body.String())
+	}
+	if env.Success || env.ErrorCode != errCodeInternal || env.Error != "internal server error" {
+		t.Errorf("envelope = %+v, want a generic internal error", env)
+	}
+	if strings.Contains(rec.Body.String(), "nil pointer") {
+		t.Errorf("panic details leaked to the client: %s", rec.Body.String())
+	}
+	if rec.Header().Get("X-Request-ID") == "" {
+		t.Errorf("500 is missing the X-Request-ID header")
+	}
+
+	// The server keeps serving
+	rec = httptest.NewRecorder()
+	h(rec, httptest.NewRequest("GET", "/api/ok", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("next request = %d, want 204", rec.Code)
+	}
+
+	// net/http's own abort is passed through untouched
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler re-panicked", err)
+		}
+	}()
+	RecoverMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/abort", nil))
+}
+
+// BenchmarkIsRateLimited measures one limiter check: "hot key" drains a
+// single bucket and is then refused, "spread" rotates over 10k client keys,
+// creating a bucket the first time each is seen.
+func BenchmarkIsRateLimited(b *testing.B) {
+	keys := make([]string, 10000)
+	for i := range keys {
+		keys[i] = "ip:198.51." + strconv.Itoa(i/256) + "." + strconv.Itoa(i%256)
+	}
+
+	b.Run("hot key", func(b *testing.B) {
+		resetRateLimiter(b)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			isRateLimited(keys[0])
+		}
+	})
+	b.Run("spread", func(b *testing.B) {
+		resetRateLimiter(b)
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			isRateLimited(keys[i%len(keys)])
+		}
+	})
+}
+
+// BenchmarkRateLimitMiddleware measures the full IP limiter in a chain,
+// including the 429 response once the client's burst is spent.
+func BenchmarkRateLimitMiddleware(b *testing.B) {
+	resetRateLimiter(b)
+	h := Chain(okHandler, RateLimitMiddleware)
+	r := httptest.NewRequest("GET", "/api/valuate", nil)
+	r.RemoteAddr = "203.0.113.9:40000"
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		h(httptest.NewRecorder(), r)
+	}
+}
+
+func TestRetryAfterForms(t *testing.T) {
+	saved := retryAfterHTTPDate
+	t.Cleanup(func() { retryAfterHTTPDate = saved })
+
+	// limited drains one IP's burst and returns the 429's Retry-After
+	limited := func(ip string) string {
+		t.Helper()
+		h := Chain(okHandler, RateLimitMiddleware)
+		for i := 0; i < rateLimitBurst; i++ {
+			hit(h, ip, "")
+		}
+		r := httptest.NewRequest("GET", "/api/valuate", nil)
+		r.RemoteAddr = ip + ":40000"
+		rec := httptest.NewRecorder()
+		h(rec, r)
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("status = %d, want 429", rec.Code)
+		}
+		return rec.Header().Get("Retry-After")
+	}
+
+	resetRateLimiter(t)
+	retryAfterHTTPDate = false
+	if secs, err := strconv.Atoi(limited("203.0.113.20")); err != nil || secs < 1 {
+		t.Errorf("delta-seconds form: %d, %v; want a positive integer", secs, err)
+	}
+
+	retryAfterHTTPDate = true
+	before := time.Now()
+	header := limited("203.0.113.21")
+	at, err := http.ParseTime(header)
+	if err != nil {
+		t.Fatalf("Retry-After %q is not an HTTP-date: %v", header, err)
+	}
+	if header != at.UTC().Format(http.TimeFormat) {
+		t.Errorf("Retry-After %q is not in the preferred IMF-fixdate form", header)
+	}
+	if !at.After(before) || at.After(before.Add(time.Minute+2*time.Second)) {
+		t.Errorf("Retry-After %s, want within a refill of %s", at, before)
+	}
+
+	// Rounded up so a client never retries early
+	now := time.Date(2026, 3, 1, 12, 0, 0, 500_000_000, time.UTC)
+	if got, want := retryAfterValue(3, now), "Sun, 01 Mar 2026 12:00:04 GMT"; got != want {
+		t.Errorf("retryAfterValue(3, %s) = %q, want %q", now, got, want)
+	}
+	retryAfterHTTPDate = false
+	if got := retryAfterValue(3, now); got != "3" {
+		t.Errorf("delta form = %q, want 3", got)
+	}
+}
@@ -14,8 +14,9 @@ type User struct {
 // TokenType distinguishes "access" tokens from "refresh" tokens so that
 // a refresh token cannot be used directly on protected API routes.
 type Claims struct {
-	Username  string `json:"username"`
-	TokenType string `json:"token_type"` // "access" | "refresh"
+	Username       string `json:"username"`
+	TokenType      string `json:"token_type"`                // "access" | "refresh"
+	ImpersonatedBy string `json:"impersonated_by,omitempty"` // admin acting as Username
 	jwt.RegisteredClaims
 }
 
@@ -31,20 +32,68 @@ type LoginResponse struct {
 
 // CarListing represents a single car in the marketplace.
 type CarListing struct {
-	ID           int     `json:"id"`
-	Make         string  `json:"make"`
-	Model        string  `json:"model"`
-	Year         int     `json:"year"`
-	Mileage      int     `json:"mileage"`
-	FuelType     string  `json:"fuel_type"`    // petrol | diesel | electric | hybrid
-	Transmission string  `json:"transmission"` // manual | automatic
-	Condition    string  `json:"condition"`    // new | used | certified
-	Price        float64 `json:"price"`
-	Description  string  `json:"description"`
-	ImageURL     string  `json:"image_url"`
-	Seller       string  `json:"seller"`
-	ListedAt     string  `json:"listed_at"`
-	Views        int     `json:"views"`
+	ID            int      `json:"id"`
+	Make          string   `json:"make"`
+	Model         string   `json:"model"`
+	VIN           string   `json:"vin"` // optional, unique; 17-char ISO 3779
+	Year          int      `json:"year"`
+	Mileage       int      `json:"mileage"`
+	Horsepower    int      `json:"horsepower,omitempty"`   // optional, positive
+	PricePerHP    float64  `json:"price_per_hp,omitempty"` // computed: Price / Horsepower
+	FuelType      string   `json:"fuel_type"`              // petrol | diesel | electric | hybrid
+	Transmission  string   `json:"transmission"`           // manual | automatic
+	Condition     string   `json:"condition"`              // new | used | certified
+	Price         float64  `json:"price"`
+	Description   string   `json:"description"`
+	ImageURL      string   `json:"image_url"` // primary photo/thumbnail; Images[0] when set
+	Images        []string `json:"images"`    // full gallery, never null
+	Seller        string   `json:"seller"`
+	ListedAt      string   `json:"listed_at"`
+	UpdatedAt     string   `json:"updated_at,omitempty"` // RFC3339, set on edit
+	Views         int      `json:"views"`
+	Status        string   `json:"status"`                   // active | sold | archived | deleted
+	SoldAt        string   `json:"sold_at,omitempty"`        // RFC3339, set when sold
+	DeletedAt     string   `json:"deleted_at,omitempty"`     // RFC3339, set by soft delete
+	SalePrice     float64  `json:"sale_price,omitempty"`     // final agreed price
+	Negotiable    bool     `json:"negotiable"`               // accepts buyer offers
+	BoostedUntil  string   `json:"boosted_until,omitempty"`  // RFC3339, paid promotion end
+	ReservedBy    string   `json:"reserved_by,omitempty"`    // buyer holding a reservation
+	ReservedUntil string   `json:"reserved_until,omitempty"` // RFC3339, reservation hold end
+	Boosted       bool     `json:"boosted"`                  // computed on read: boost still active
+	QualityScore  int      `json:"quality_score"`            // computed on read: 0–100 completeness
+}
+
+// Listing lifecycle states for CarListing.Status.
+const (
+	statusActive   = "active"
+	statusSold     = "sold"
+	statusArchived = "archived"
+	statusDeleted  = "deleted" // soft-deleted, restorable until purged
+)
+
+// Known values of the enum-like CarListing fields.
+var (
+	fuelTypes     = []string{"petrol", "diesel", "electric", "hybrid"}
+	transmissions = []string{"manual", "automatic"}
+	conditions    = []string{"new", "used", "certified"}
+)
+
+// Comment is a single message in a listing's public discussion thread.
+type Comment struct {
+	ID        int    `json:"id"`
+	CarID     int    `json:"car_id"`
+	Author    string `json:"author"`
+	Text      string `json:"text"`
+	CreatedAt string `json:"created_at"`
+}
+
+// Offer is a buyer's price offer on a negotiable listing.
+type Offer struct {
+	ID        int     `json:"id"`
+	CarID     int     `json:"car_id"`
+	Buyer     string  `json:"buyer"`
+	Amount    float64 `json:"amount"`
+	CreatedAt string  `json:"created_at"`
 }
 
 // ─── Valuation Models ─────────────────────────────────────────────────────────
@@ -52,30 +101,95 @@ type CarListing struct {
 // ValuationRequest is the input to the rule-based pricing engine.
 type ValuationRequest struct {
 	Make         string `json:"make"`
+	Model        string `json:"model,omitempty"` // matched against appreciatingModels
 	Year         int    `json:"year"`
 	Mileage      int    `json:"mileage"`
 	Condition    string `json:"condition"`
 	FuelType     string `json:"fuel_type"`
 	Transmission string `json:"transmission"`
+	MaxFactors   int    `json:"max_factors,omitempty"`  // keep only the N largest adjustments (0 = all)
+	Lang         string `json:"lang,omitempty"`         // factor language; overrides Accept-Language
+	Appreciating bool   `json:"appreciating,omitempty"` // value as a classic that gains value with age
 }
 
 // ValuationResponse is the output of the pricing engine.
 type ValuationResponse struct {
-	EstimatedMin float64  `json:"estimated_min"`
-	EstimatedMax float64  `json:"estimated_max"`
-	Confidence   string   `json:"confidence"`
-	Factors      []string `json:"factors"` // human-readable explanation of adjustments
+	EstimatedMin    float64  `json:"estimated_min"`
+	EstimatedMax    float64  `json:"estimated_max"`
+	Confidence      string   `json:"confidence"`       // high | medium | low
+	ConfidenceScore float64  `json:"confidence_score"` // 0.0–1.0, for a gauge
+	Factors         []string `json:"factors"`          // human-readable explanation of adjustments
+}
+
+// ServiceAPIKey is a static credential for scripts and CI, presented in the
+// X-API-Key header instead of a Bearer JWT. Only the key's hash is kept.
+type ServiceAPIKey struct {
+	Hash     string `json:"hash"`     // hex SHA-256 of the key
+	Username string `json:"username"` // service account the key acts as
+	Role     string `json:"role"`     // "admin" | "seller"
+}
+
+// AppreciatingModel classifies a make and model as a classic whose value
+// rises with age instead of depreciating.
+type AppreciatingModel struct {
+	Make         string `json:"make"`                   // case-insensitive substring
+	Model        string `json:"model"`                  // case-insensitive substring
+	Transmission string `json:"transmission,omitempty"` // optional, e.g. only manuals
+}
+
+// MakeFuelAdjustment is one row of the EV-transition adjustment table.
+// It applies Multiplier to cars of the given make and fuel type that are at
+// least MinAge years old, on top of the standard fuel-type adjustment.
+type MakeFuelAdjustment struct {
+	Make       string  `json:"make"`      // matched as a case-insensitive substring
+	FuelType   string  `json:"fuel_type"` // petrol | diesel | electric | hybrid
+	MinAge     int     `json:"min_age"`   // 0 applies to every model year
+	Multiplier float64 `json:"multiplier"`
+	Reason     string  `json:"reason"` // shown in the factors list
 }
 
 // ─── API Envelope ─────────────────────────────────────────────────────────────
 
 // APIResponse is a consistent JSON wrapper for every response.
-// Every endpoint returns { success, data?, error? } so the client
-// can always check `success` first and branch accordingly.
+// Every endpoint returns { success, data?, error?, error_code? } so the
+// client can always check `success` first and branch accordingly.
 type APIResponse struct {
-	Success bool        `json:"success"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
+	Success   bool        `json:"success"`
+	Data      interface{} `json:"data,omitempty"`
+	Error     string      `json:"error,omitempty"`
+	ErrorCode string      `json:"error_code,omitempty"`
+}
+
+// ValidationError is one invalid input field, reported alongside any others
+// in a 422 response so a form can flag every problem at once.
+type ValidationError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Machine-readable error codes sent as APIResponse.ErrorCode. These are part
+// of the API contract: add new ones freely, but never rename or reuse one.
+const (
+	errCodeInvalidBody      = "INVALID_BODY"      // body missing, malformed or too large to read
+	errCodeValidationFailed = "VALIDATION_FAILED" // well-formed input with an invalid value
+	errCodeUnauthorized     = "UNAUTHORIZED"
+	errCodeForbidden        = "FORBIDDEN"
+	errCodeNotFound         = "NOT_FOUND"
+	errCodeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+	errCodeConflict         = "CONFLICT" // the listing's current state doesn't allow it
+	errCodeGone             = "GONE"
+	errCodePayloadTooLarge  = "PAYLOAD_TOO_LARGE" // too many items in one request
+	errCodeRateLimited      = "RATE_LIMITED"
+	errCodeInternal         = "INTERNAL"
+	errCodeUnavailable      = "UNAVAILABLE"
+)
+
+// PageMeta describes one page of a paginated list response.
+type PageMeta struct {
+	Total   int  `json:"total"`
+	Limit   int  `json:"limit"`
+	Offset  int  `json:"offset"`
+	HasMore bool `json:"has_more"`
 }
 
 // ─── Context Key ──────────────────────────────────────────────────────────────
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"net/http"
+	"time"
+)
+
+// ─── POST /api/cars/{id}/offer ────────────────────────────────────────────────
+
+// makeOfferHandler records a buyer's offer on an active, negotiable listing.
+// Other listings return 409, as does one that already holds maxOffersPerCar
+// offers. Sellers can't bid on their own cars, and with offerMustBeBelowAsk
+// set the offer must undercut the asking price. The seller is notified
+// through their /api/me/activity feed, which lists every offer on their cars.
+//
+// Request body: { "amount": 172000 }
+func makeOfferHandler(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+
+	id, err := parseCarID(r.URL.Path)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
+		return
+	}
+
+	var body struct {
+		Amount float64 `json:"amount"`
+	}
+	if status, err := decodeJSON(w, r, &body, maxBodyBytes); err != nil {
+		respondError(w, status, errCodeInvalidBody, err.Error())
+		return
+	}
+	if body.Amount <= 0 {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "offer amount must be positive")
+		return
+	}
+
+	storeMu.RLock()
+	car, ok := carStore[id]
+	storeMu.RUnlock()
+	if !ok {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+	if car.Seller == claims.Username {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "you cannot make an offer on your own listing")
+		return
+	}
+	if car.Status != statusActive {
+		respondError(w, http.StatusConflict, errCodeConflict, "listing is not active")
+		return
+	}
+	if !car.Negotiable {
+		respondError(w, http.StatusConflict, errCodeConflict, "listing is not open to offers")
+		return
+	}
+	if offerMustBeBelowAsk && body.Amount >= car.Price {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "offer must be below the asking price")
+		return
+	}
+
+	offersMu.Lock()
+	if len(carOffers[id]) >= maxOffersPerCar {
+		offersMu.Unlock()
+		respondError(w, http.StatusConflict, errCodeConflict, "listing has reached its offer limit")
+		return
+	}
+	offer := Offer{
+		ID:        nextOfferID,
+		CarID:     id,
+		Buyer:     claims.Username,
+		Amount:    body.Amount,
+		CreatedAt: time.Now().Format(time.RFC3339),
+	}
+	nextOfferID++
+	carOffers[id] = append(carOffers[id], offer)
+	markCarsDirty()
+	offersMu.Unlock()
+
+	emitEvent(eventOfferMade, offer.Buyer, id, map[string]interface{}{
+		"offer_id": offer.ID, "amount": offer.Amount, "seller": car.Seller,
+	})
+	respond(w, http.StatusCreated, offer)
+}
+
+// ─── GET /api/cars/{id}/offers ────────────────────────────────────────────────
+
+// listOffersHandler returns the offers received on a listing.
+// Only the listing's seller may see them.
+func listOffersHandler(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+
+	id, err := parseCarID(r.URL.Path)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
+		return
+	}
+
+	storeMu.RLock()
+	car, ok := carStore[id]
+	storeMu.RUnlock()
+	if !ok {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+	if car.Seller != claims.Username {
+		respondError(w, http.StatusForbidden, errCodeForbidden, "only the seller can view offers")
+		return
+	}
+
+	offersMu.RLock()
+	offers := append([]Offer{}, carOffers[id]...)
+	offersMu.RUnlock()
+
+	respond(w, http.StatusOK, map[string]interface{}{
+		"offers": offers,
+		"count":  len(offers),
+	})
+}
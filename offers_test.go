@@ -0,0 +1,83 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func makeOffer(t *testing.T, carID, user string, amount float64) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	r := jsonRequest(t, "POST", "/api/cars/"+carID+"/offer", map[string]float64{"amount": amount})
+	makeOfferHandler(rec, asUser(r, user))
+	return rec
+}
+
+func TestOfferNegotiableGate(t *testing.T) {
+	useStore(t,
+		CarListing{ID: 1, Seller: "alice", Price: 100000, Negotiable: true},
+		CarListing{ID: 2, Seller: "alice", Price: 100000},
+		CarListing{ID: 3, Seller: "alice", Price: 100000, Negotiable: true, Status: statusSold},
+	)
+
+	tests := []struct {
+		name   string
+		car    string
+		user   string
+		amount float64
+		want   int
+	}{
+		{"fixed price", "2", "bob", 90000, http.StatusConflict},
+		{"sold listing", "3", "bob", 90000, http.StatusConflict},
+		{"own listing", "1", "alice", 90000, http.StatusBadRequest},
+		{"at asking price", "1", "bob", 100000, http.StatusBadRequest},
+		{"non-positive", "1", "bob", 0, http.StatusBadRequest},
+		{"missing car", "99", "bob", 90000, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := makeOffer(t, tt.car, tt.user, tt.amount); rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d: %s", tt.name, rec.Code, tt.want, rec.Body.String())
+		}
+	}
+	if len(carOffers) != 0 {
+		t.Errorf("rejected offers were recorded: %v", carOffers)
+	}
+}
+
+func TestOfferRecorded(t *testing.T) {
+	useStore(t, CarListing{ID: 1, Seller: "alice", Price: 100000, Negotiable: true})
+
+	rec := makeOffer(t, "1", "bob", 92500)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("offer = %d: %s", rec.Code, rec.Body.String())
+	}
+	makeOffer(t, "1", "carol", 95000)
+
+	list := func(user string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		listOffersHandler(rec, asUser(httptest.NewRequest("GET", "/api/cars/1/offers", nil), user))
+		return rec
+	}
+	if rec := list("bob"); rec.Code != http.StatusForbidden {
+		t.Errorf("buyer listing offers = %d, want 403", rec.Code)
+	}
+	rec = list("alice")
+	var got struct {
+		Offers []Offer `json:"offers"`
+		Count  int     `json:"count"`
+	}
+	decodeData(t, rec, &got)
+	if got.Count != 2 || got.Offers[0].Buyer != "bob" || got.Offers[0].Amount != 92500 || got.Offers[1].Buyer != "carol" {
+		t.Errorf("offers = %+v, want bob's 92500 then carol's", got.Offers)
+	}
+}
+
+func TestOfferCapPerListing(t *testing.T) {
+	useStore(t, CarListing{ID: 1, Seller: "alice", Price: 100000, Negotiable: true})
+	carOffers[1] = make([]Offer, maxOffersPerCar)
+
+	if rec := makeOffer(t, "1", "bob", 90000); rec.Code != http.StatusConflict {
+		t.Errorf("offer past the cap = %d, want 409", rec.Code)
+	}
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"maps"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ─── File Persistence ─────────────────────────────────────────────────────────
+// The car store — with the accounts, comments, offers, photo hashes and
+// share links that hang off it — is saved as JSON to dataFile. Mutating
+// handlers call markCarsDirty while still holding their store's lock; a
+// background writer batches changes for persistInterval, then snapshots each
+// store under its lock and writes it atomically (temp file + rename), so a
+// crash mid-write leaves the previous file intact.
+//
+// Accounts are saved with the cars so a seller named on a listing still
+// exists after a restart; otherwise anyone could re-register the name and
+// take over the listings.
+
+// carStoreFile is the on-disk format of dataFile. Files written before the
+// side stores were persisted simply load with them empty.
+type carStoreFile struct {
+	NextID        int                       `json:"next_id"`
+	Cars          []CarListing              `json:"cars"`
+	Users         map[string]string         `json:"users,omitempty"` // username → bcrypt hash
+	NextCommentID int                       `json:"next_comment_id,omitempty"`
+	Comments      map[int][]Comment         `json:"comments,omitempty"`
+	NextOfferID   int                       `json:"next_offer_id,omitempty"`
+	Offers        map[int][]Offer           `json:"offers,omitempty"`
+	PhotoHashes   map[int]map[string]uint64 `json:"photo_hashes,omitempty"`
+	ImageHashes   map[int]uint64            `json:"image_hashes,omitempty"` // one per listing, before per-photo hashes
+	ShareLinks    map[string]shareLink      `json:"share_links,omitempty"`
+}
+
+// persistSignal wakes the background writer. Buffered with room for one, so
+// any number of changes while a write is pending collapse into one write.
+var persistSignal = make(chan struct{}, 1)
+
+// startPersistence enables saving to dataFile. No-op when dataFile is "".
+func startPersistence() {
+	if dataFile == "" {
+		return
+	}
+	persistFunc = saveCarStore
+	go persistWriter()
+}
+
+// markCarsDirty schedules a save of the car store and its side stores.
+// Never blocks, so it is safe to call with any store lock held.
+func markCarsDirty() {
+	if persistFunc == nil {
+		return
+	}
+	select {
+	case persistSignal <- struct{}{}:
+	default: // a save is already pending and will include this change
+	}
+}
+
+func persistWriter() {
+	for range persistSignal {
+		time.Sleep(persistInterval)
+		persistNow()
+	}
+}
+
+// saveCarStore writes a snapshot of the car store and its side stores to
+// dataFile. Each store is copied under its own lock, one at a time.
+func saveCarStore() error {
+	storeMu.RLock()
+	file := carStoreFile{NextID: int(lastCarID.Load()) + 1, Cars: make([]CarListing, 0, len(carStore))}
+	for _, car := range carStore {
+		file.Cars = append(file.Cars, liveViews(car))
+	}
+	storeMu.RUnlock()
+
+	usersMu.RLock()
+	file.Users = maps.Clone(users)
+	usersMu.RUnlock()
+
+	commentsMu.RLock()
+	file.NextCommentID, file.Comments = nextCommentID, maps.Clone(carComments)
+	commentsMu.RUnlock()
+
+	offersMu.RLock()
+	file.NextOfferID, file.Offers = nextOfferID, maps.Clone(carOffers)
+	offersMu.RUnlock()
+
+	imageHashesMu.RLock()
+	file.PhotoHashes = make(map[int]map[string]uint64, len(imageHashes))
+	for id, photos := range imageHashes {
+		file.PhotoHashes[id] = maps.Clone(photos)
+	}
+	imageHashesMu.RUnlock()
+
+	shareLinksMu.Lock()
+	file.ShareLinks = maps.Clone(shareLinks)
+	shareLinksMu.Unlock()
+
+	sort.Slice(file.Cars, func(i, j int) bool { return file.Cars[i].ID < file.Cars[j].ID })
+	data, err := json.MarshalIndent(file, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(dataFile, data)
+}
+
+// writeFileAtomic writes data to a temp file in path's directory, syncs it,
+// and renames it over path.
+func writeFileAtomic(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// loadCarStore replaces the car store with the contents of dataFile.
+// Reports false (and no error) when persistence is disabled or the file
+// doesn't exist yet, in which case the caller seeds demo data instead.
+func loadCarStore() (bool, error) {
+	if dataFile == "" {
+		return false, nil
+	}
+	data, err := os.ReadFile(dataFile)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var file carStoreFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return false, fmt.Errorf("%s: %w", dataFile, err)
+	}
+	loadSideStores(file)
+
+	storeMu.Lock()
+	defer storeMu.Unlock()
+	carStore = make(map[int]CarListing, len(file.Cars))
+	vinIndex = make(map[string]int)
+	last := file.NextID - 1
+	for _, car := range file.Cars {
+		normalizeImages(&car) // files written before galleries existed
+		if car.DeletedAt != "" && car.Status == statusSold && car.SalePrice == 0 {
+			// Soft deletes used to be recorded as $0 sales
+			car.Status, car.SoldAt = statusDeleted, ""
+		}
+		setPricePerHP(&car)
+		carStore[car.ID] = car
+		if car.VIN != "" {
+			vinIndex[car.VIN] = car.ID
+		}
+		setViews(car.ID, car.Views)
+		last = max(last, car.ID)
+	}
+
+	// The single hash older files kept was of the last upload, which became
+	// the primary photo
+	imageHashesMu.Lock()
+	for id, hash := range file.ImageHashes {
+		car, ok := carStore[id]
+		if !ok || car.ImageURL == "" || imageHashes[id] != nil {
+			continue
+		}
+		imageHashes[id] = map[string]uint64{car.ImageURL: hash}
+	}
+	imageHashesMu.Unlock()
+
+	lastCarID.Store(int64(last))
+	return true, nil
+}
+
+// loadSideStores restores the accounts, comments, offers, photo hashes and
+// share links saved alongside the cars. The demo account is kept even if
+// the file predates it.
+func loadSideStores(file carStoreFile) {
+	usersMu.Lock()
+	for name, hash := range file.Users {
+		users[name] = hash
+	}
+	usersMu.Unlock()
+
+	commentsMu.Lock()
+	if file.Comments != nil {
+		carComments = file.Comments
+	}
+	nextCommentID = max(nextCommentID, file.NextCommentID)
+	commentsMu.Unlock()
+
+	offersMu.Lock()
+	if file.Offers != nil {
+		carOffers = file.Offers
+	}
+	nextOfferID = max(nextOfferID, file.NextOfferID)
+	offersMu.Unlock()
+
+	imageHashesMu.Lock()
+	if file.PhotoHashes != nil {
+		imageHashes = file.PhotoHashes
+	}
+	imageHashesMu.Unlock()
+
+	shareLinksMu.Lock()
+	if file.ShareLinks != nil {
+		shareLinks = file.ShareLinks
+	}
+	shareLinksMu.Unlock()
+}
+
+// ─── Persistence Health ───────────────────────────────────────────────────────
+// Policy when a save fails (disk full, permissions, …): the in-memory change
+// is kept, the error is logged, the server reports itself as degraded on
+// /api/health, and the save is retried with exponential backoff until it
+// succeeds. A failed write never crashes the server or rolls back a request.
+
+// persistFunc writes the current state to durable storage.
+// nil means persistence is disabled and persistNow is a no-op.
+var persistFunc func() error
+
+var (
+	persistMu       sync.Mutex
+	persistLastOK   time.Time
+	persistLastErr  string
+	persistFailures int  // consecutive failures since the last success
+	persistRetrying bool // a backoff retry loop is already running
+)
+
+// persistNow saves the current state, switching to degraded mode and
+// scheduling retries if the write fails.
+func persistNow() {
+	if persistFunc == nil {
+		return
+	}
+	if err := persistFunc(); err != nil {
+		recordPersistFailure(err)
+		return
+	}
+	recordPersistSuccess()
+}
+
+func recordPersistSuccess() {
+	persistMu.Lock()
+	defer persistMu.Unlock()
+
+	if persistFailures > 0 {
+		log.Printf("persistence recovered after %d failed attempt(s)", persistFailures)
+	}
+	persistLastOK = time.Now()
+	persistLastErr = ""
+	persistFailures = 0
+}
+
+func recordPersistFailure(err error) {
+	persistMu.Lock()
+	defer persistMu.Unlock()
+
+	persistFailures++
+	persistLastErr = err.Error()
+	log.Printf("ERROR persistence write failed (attempt %d): %v", persistFailures, err)
+
+	if !persistRetrying {
+		persistRetrying = true
+		go retryPersist()
+	}
+}
+
+// retryPersist keeps retrying the save with exponential backoff
+// (persistRetryBase doubling up to persistRetryMax) until one succeeds.
+func retryPersist() {
+	delay := persistRetryBase
+	for {
+		time.Sleep(delay)
+		err := persistFunc()
+		if err == nil {
+			recordPersistSuccess()
+			persistMu.Lock()
+			persistRetrying = false
+			persistMu.Unlock()
+			return
+		}
+
+		persistMu.Lock()
+		persistFailures++
+		persistLastErr = err.Error()
+		persistMu.Unlock()
+		log.Printf("ERROR persistence retry failed: %v", err)
+
+		if delay *= 2; delay > persistRetryMax {
+			delay = persistRetryMax
+		}
+	}
+}
+
+// ─── GET /api/health ──────────────────────────────────────────────────────────
+
+// healthStatusHandler reports overall service health, including whether the
+// last persistence write succeeded. Returns 200 in both the "ok" and
+// "degraded" states — the service is still serving from memory.
+func healthStatusHandler(w http.ResponseWriter, r *http.Request) {
+	persistMu.Lock()
+	persistence := map[string]interface{}{
+		"enabled":              persistFunc != nil,
+		"last_persist_ok":      persistFailures == 0,
+		"consecutive_failures": persistFailures,
+	}
+	if !persistLastOK.IsZero() {
+		persistence["last_success"] = persistLastOK.Format(time.RFC3339)
+	}
+	if persistLastErr != "" {
+		persistence["last_error"] = persistLastErr
+	}
+	degraded := persistFailures > 0
+	persistMu.Unlock()
+
+	status := "ok"
+	if degraded {
+		status = "degraded"
+	}
+	respond(w, http.StatusOK, map[string]interface{}{
+		"status":      status,
+		"degraded":    degraded,
+		"persistence": persistence,
+	})
+}
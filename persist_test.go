@@ -0,0 +1,99 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// persistHealth calls healthStatusHandler and decodes the response.
+func persistHealth(t *testing.T) (status string, persistence map[string]interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	healthStatusHandler(rec, httptest.NewRequest("GET", "/api/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("health = %d, want 200 even when degraded", rec.Code)
+	}
+	var got struct {
+		Status      string                 `json:"status"`
+		Persistence map[string]interface{} `json:"persistence"`
+	}
+	decodeData(t, rec, &got)
+	return got.Status, got.Persistence
+}
+
+func TestPersistFailureDegradesHealth(t *testing.T) {
+	saved := persistFunc
+	t.Cleanup(func() {
+		persistFunc = saved
+		persistMu.Lock()
+		persistLastOK, persistLastErr, persistFailures = time.Time{}, "", 0
+		persistMu.Unlock()
+	})
+
+	var failing atomic.Bool
+	failing.Store(true)
+	persistFunc = func() error {
+		if failing.Load() {
+			return errors.New("no space left on device")
+		}
+		return nil
+	}
+
+	persistNow()
+	status, p := persistHealth(t)
+	if status != "degraded" || p["last_persist_ok"] != false || p["last_error"] != "no space left on device" {
+		t.Errorf("after a failed write: status %q, persistence %v; want degraded with the error", status, p)
+	}
+	if n, _ := p["consecutive_failures"].(float64); n < 1 {
+		t.Errorf("consecutive_failures = %v, want at least 1", p["consecutive_failures"])
+	}
+
+	// The background retry clears the flag once writes succeed again
+	failing.Store(false)
+	deadline := time.Now().Add(persistRetryBase + 2*time.Second)
+	for {
+		persistMu.Lock()
+		done := !persistRetrying
+		persistMu.Unlock()
+		if done {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("persistence retry did not recover")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	status, p = persistHealth(t)
+	if status != "ok" || p["last_persist_ok"] != true || p["last_error"] != nil || p["last_success"] == nil {
+		t.Errorf("after recovery: status %q, persistence %v; want ok with a last_success", status, p)
+	}
+}
+
+func TestLoadMigratesListingImageHashes(t *testing.T) {
+	useStore(t)
+	saved := dataFile
+	t.Cleanup(func() { dataFile = saved })
+	dataFile = filepath.Join(t.TempDir(), "cars.json")
+	legacy := `{"next_id":3,"cars":[` +
+		`{"id":1,"seller":"alice","image_url":"/uploads/car-1.png"},` +
+		`{"id":2,"seller":"bob"}],` +
+		`"image_hashes":{"1":42,"2":7,"9":1}}`
+	if err := os.WriteFile(dataFile, []byte(legacy), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := loadCarStore(); !ok || err != nil {
+		t.Fatalf("loadCarStore = %v, %v", ok, err)
+	}
+	want := map[int]map[string]uint64{1: {"/uploads/car-1.png": 42}}
+	if !reflect.DeepEqual(imageHashes, want) {
+		t.Errorf("migrated hashes = %v, want %v", imageHashes, want)
+	}
+}
@@ -1,29 +1,363 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"net/http"
+	"reflect"
+	"strings"
+	"unicode"
 )
 
-// respond writes a consistent JSON envelope to the response writer.
-// Every endpoint uses this so the client always gets the same shape:
+// respond writes a successful JSON envelope to the response writer.
+// Every endpoint uses this or respondError so the client always gets the
+// same shape:
 //
 //	{ "success": true,  "data": { ... } }
-//	{ "success": false, "error": "some message" }
+//	{ "success": false, "error": "some message", "error_code": "NOT_FOUND" }
 //
 // Parameters:
 //
-//	w      — the response writer
-//	code   — HTTP status code (200, 201, 400, 401, 403, 404, 500 …)
-//	data   — payload for success responses (nil for errors)
-//	errMsg — error message; empty string means success
-func respond(w http.ResponseWriter, code int, data interface{}, errMsg string) {
+//	w    — the response writer
+//	code — HTTP status code (200, 201 …)
+//	data — the payload
+func respond(w http.ResponseWriter, code int, data interface{}) {
+	writeEnvelope(w, code, APIResponse{Success: true, Data: data})
+}
+
+// respondError writes a failed JSON envelope. errCode is one of the stable
+// errCode* values clients branch on; msg is the human-readable detail and
+// may change between releases.
+func respondError(w http.ResponseWriter, code int, errCode, msg string) {
+	writeEnvelope(w, code, APIResponse{Error: msg, ErrorCode: errCode})
+}
+
+// respondInvalid writes a 422 listing every invalid field:
+//
+//	{ "success": false, "error": "validation failed",
+//	  "error_code": "VALIDATION_FAILED", "data": { "errors": [ ... ] } }
+func respondInvalid(w http.ResponseWriter, errs []ValidationError) {
+	writeEnvelope(w, http.StatusUnprocessableEntity, APIResponse{
+		Data:      map[string]interface{}{"errors": errs},
+		Error:     "validation failed",
+		ErrorCode: errCodeValidationFailed,
+	})
+}
+
+// writeEnvelope writes resp as JSON with the given status. respond and
+// respondError cover almost every case; it's called directly only for the
+// few errors that also carry data, like a duplicate image's matches.
+func writeEnvelope(w http.ResponseWriter, code int, resp APIResponse) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
+	w.Write(encodeEnvelope(resp))
+}
 
-	json.NewEncoder(w).Encode(APIResponse{
-		Success: errMsg == "",
-		Data:    data,
-		Error:   errMsg,
-	})
+// encodeEnvelope marshals a response envelope, converting struct field names
+// to camelCase when jsonFieldCase is "camel".
+func encodeEnvelope(resp APIResponse) []byte {
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(resp)
+	if jsonFieldCase != "camel" {
+		return buf.Bytes()
+	}
+	doc, ok := parseJSON(buf.Bytes())
+	if !ok {
+		return buf.Bytes()
+	}
+	buf.Reset()
+	json.NewEncoder(&buf).Encode(camelKeys(reflect.ValueOf(resp), doc))
+	return buf.Bytes()
+}
+
+// decodeJSON decodes the request body into dst, refusing to read more than
+// limit bytes so an oversized payload can't allocate unbounded memory before
+// validation runs. The returned status is 413 for oversized bodies and 400
+// for anything else that fails to decode.
+func decodeJSON(w http.ResponseWriter, r *http.Request, dst interface{}, limit int64) (int, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+
+	var err error
+	if jsonFieldCase == "camel" {
+		// Accept camelCase keys by mapping them back onto the snake_case tags
+		var raw json.RawMessage
+		if err = json.NewDecoder(r.Body).Decode(&raw); err == nil {
+			if doc, ok := parseJSON(raw); ok {
+				var buf bytes.Buffer
+				json.NewEncoder(&buf).Encode(snakeKeys(reflect.TypeOf(dst), doc))
+				raw = buf.Bytes()
+			}
+			err = json.Unmarshal(raw, dst)
+		}
+	} else {
+		err = json.NewDecoder(r.Body).Decode(dst)
+	}
+
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return http.StatusRequestEntityTooLarge, errors.New("request body too large")
+		}
+		return http.StatusBadRequest, errors.New("invalid request body")
+	}
+	return http.StatusOK, nil
+}
+
+// parseJSON decodes doc into generic values, keeping numbers exactly as
+// encoded.
+func parseJSON(doc []byte) (interface{}, bool) {
+	dec := json.NewDecoder(bytes.NewReader(doc))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, false
+	}
+	return v, true
+}
+
+var jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+
+// camelKeys renames the keys of doc, the generic decoding of v, to
+// camelCase. Only keys that come from struct fields are renamed, along with
+// those of map[string]interface{} values, which handlers use for ad-hoc
+// objects. Keys of typed maps are data (a make, a message key, a bucket) and
+// are kept as they are; their values are still walked.
+func camelKeys(v reflect.Value, doc interface{}) interface{} {
+	for v.IsValid() && (v.Kind() == reflect.Interface || v.Kind() == reflect.Pointer) {
+		if v.IsNil() {
+			return doc
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() || v.Type().Implements(jsonMarshalerType) || reflect.PointerTo(v.Type()).Implements(jsonMarshalerType) {
+		return doc
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return doc
+		}
+		out := make(map[string]interface{}, len(obj))
+		for k, val := range obj {
+			index, ok := jsonFieldIndex(v.Type(), k)
+			if !ok {
+				out[k] = val
+				continue
+			}
+			field, err := v.FieldByIndexErr(index)
+			if err != nil {
+				field = reflect.Value{}
+			}
+			out[snakeToCamel(k)] = camelKeys(field, val)
+		}
+		return out
+	case reflect.Map:
+		obj, ok := doc.(map[string]interface{})
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			return doc
+		}
+		adHoc := v.Type().Elem().Kind() == reflect.Interface
+		out := make(map[string]interface{}, len(obj))
+		for k, val := range obj {
+			elem := v.MapIndex(reflect.ValueOf(k).Convert(v.Type().Key()))
+			if adHoc {
+				k = snakeToCamel(k)
+			}
+			out[k] = camelKeys(elem, val)
+		}
+		return out
+	case reflect.Slice, reflect.Array:
+		arr, ok := doc.([]interface{})
+		if !ok {
+			return doc
+		}
+		for i := range arr {
+			if i < v.Len() {
+				arr[i] = camelKeys(v.Index(i), arr[i])
+			}
+		}
+	}
+	return doc
+}
+
+// snakeKeys maps the camelCase keys of doc back onto the snake_case field
+// tags of t, the type being decoded into. Keys of maps are left alone, so
+// a request like the base price table keeps its makes as sent.
+func snakeKeys(t reflect.Type, doc interface{}) interface{} {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return doc
+		}
+		out := make(map[string]interface{}, len(obj))
+		for k, val := range obj {
+			name := camelToSnake(k)
+			index, ok := jsonFieldIndex(t, name)
+			if !ok {
+				out[k] = val
+				continue
+			}
+			out[name] = snakeKeys(t.FieldByIndex(index).Type, val)
+		}
+		return out
+	case reflect.Map:
+		obj, ok := doc.(map[string]interface{})
+		if !ok {
+			return doc
+		}
+		for k, val := range obj {
+			obj[k] = snakeKeys(t.Elem(), val)
+		}
+	case reflect.Slice, reflect.Array:
+		arr, ok := doc.([]interface{})
+		if !ok {
+			return doc
+		}
+		for i := range arr {
+			arr[i] = snakeKeys(t.Elem(), arr[i])
+		}
+	}
+	return doc
+}
+
+// jsonFieldIndex finds the field of struct type t that encoding/json writes
+// under name, looking through embedded structs.
+func jsonFieldIndex(t reflect.Type, name string) ([]int, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		tagName, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && tagName == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				if index, ok := jsonFieldIndex(ft, name); ok {
+					return append([]int{i}, index...), true
+				}
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if tagName == "" {
+			tagName = f.Name
+		}
+		if tagName == name {
+			return []int{i}, true
+		}
+	}
+	return nil, false
+}
+
+// snakeToCamel converts "fuel_type" to "fuelType".
+func snakeToCamel(s string) string {
+	parts := strings.Split(s, "_")
+	for i := 1; i < len(parts); i++ {
+		if parts[i] != "" {
+			parts[i] = strings.ToUpper(parts[i][:1]) + parts[i][1:]
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// camelToSnake converts "fuelType" to "fuel_type".
+func camelToSnake(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// respondCached writes a success envelope with a strong ETag and honours
+// If-None-Match, replying 304 Not Modified when the client already holds
+// the current representation. Only use it for deterministic payloads.
+func respondCached(w http.ResponseWriter, r *http.Request, data interface{}) {
+	writeCached(w, r, "application/json", encodeEnvelope(APIResponse{Success: true, Data: data}))
+}
+
+// writeCached writes body with an ETag derived from its contents, or a bare
+// 304 when it matches the request's If-None-Match header.
+func writeCached(w http.ResponseWriter, r *http.Request, contentType string, body []byte) {
+	etag := makeETag(body, false)
+	w.Header().Set("ETag", etag)
+	if etagMatch(r.Header.Get("If-None-Match"), etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body)
+}
+
+// makeETag returns a quoted entity tag for body, e.g. "1a2b…" or W/"1a2b…".
+func makeETag(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	tag := `"` + hex.EncodeToString(sum[:8]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
+
+// etagMatch reports whether an If-None-Match header value matches etag.
+// Per RFC 9110 If-None-Match uses the weak comparison, so W/"x" matches "x".
+// Handles the * wildcard and comma-separated lists; unquoted (malformed)
+// entries never match.
+func etagMatch(ifNoneMatch, etag string) bool {
+	header := strings.TrimSpace(ifNoneMatch)
+	if header == "" {
+		return false
+	}
+	if header == "*" {
+		return true
+	}
+
+	want := strings.TrimPrefix(etag, "W/")
+	for header != "" {
+		header = strings.TrimLeft(header, " \t,")
+		if header == "" {
+			break
+		}
+		weakless := strings.TrimPrefix(header, "W/")
+		if !strings.HasPrefix(weakless, `"`) {
+			// Malformed entry: skip to the next comma
+			i := strings.IndexByte(header, ',')
+			if i < 0 {
+				break
+			}
+			header = header[i+1:]
+			continue
+		}
+		end := strings.IndexByte(weakless[1:], '"')
+		if end < 0 {
+			break
+		}
+		if weakless[:end+2] == want {
+			return true
+		}
+		header = weakless[end+2:]
+	}
+	return false
 }
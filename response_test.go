@@ -0,0 +1,204 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSONBodyCap(t *testing.T) {
+	var dst map[string]interface{}
+
+	body := `{"notes":"` + strings.Repeat("x", 2048) + `"}`
+	r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+	status, err := decodeJSON(httptest.NewRecorder(), r, &dst, 1024)
+	if err == nil || status != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized body: status %d, err %v; want 413", status, err)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"notes":`))
+	status, err = decodeJSON(httptest.NewRecorder(), r, &dst, 1024)
+	if err == nil || status != http.StatusBadRequest {
+		t.Errorf("truncated body: status %d, err %v; want 400", status, err)
+	}
+
+	r = httptest.NewRequest("POST", "/", strings.NewReader(`{"notes":"ok"}`))
+	if _, err := decodeJSON(httptest.NewRecorder(), r, &dst, 1024); err != nil || dst["notes"] != "ok" {
+		t.Errorf("small body: %v, %v", dst, err)
+	}
+}
+
+func TestMakeETag(t *testing.T) {
+	strong := makeETag([]byte("body"), false)
+	if len(strong) != 18 || strong[0] != '"' || strong[17] != '"' {
+		t.Errorf("strong tag %q, want 16 hex digits in double quotes", strong)
+	}
+	if weak := makeETag([]byte("body"), true); weak != "W/"+strong {
+		t.Errorf("weak tag %q, want W/%s", weak, strong)
+	}
+	if makeETag([]byte("other"), false) == strong {
+		t.Errorf("different bodies share an ETag")
+	}
+}
+
+func TestETagMatch(t *testing.T) {
+	const tag = `"abc123"`
+	tests := []struct {
+		header string
+		want   bool
+	}{
+		{``, false},
+		{`"abc123"`, true},
+		{`W/"abc123"`, true},
+		{`"zzz"`, false},
+		{`*`, true},
+		{` * `, true},
+		{`"zzz", "abc123"`, true},
+		{`"zzz",W/"abc123"`, true},
+		{`"a,b", "abc123"`, true}, // commas inside a quoted tag
+		{`"zzz", "yyy"`, false},
+		{`abc123`, false}, // unquoted entries never match
+		{`abc123, "abc123"`, true},
+		{`"abc123`, false},
+	}
+	for _, tt := range tests {
+		if got := etagMatch(tt.header, tag); got != tt.want {
+			t.Errorf("etagMatch(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+	if !etagMatch(`"abc123"`, `W/"abc123"`) {
+		t.Errorf("weak comparison: a strong header should match a weak tag")
+	}
+}
+
+func TestWriteCachedNotModified(t *testing.T) {
+	rec := httptest.NewRecorder()
+	writeCached(rec, httptest.NewRequest("GET", "/", nil), "text/plain", []byte("hello"))
+	etag := rec.Header().Get("ETag")
+	if rec.Code != http.StatusOK || rec.Body.String() != "hello" || etag == "" {
+		t.Fatalf("first request: %d %q etag %q", rec.Code, rec.Body.String(), etag)
+	}
+
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("If-None-Match", `"stale", `+etag)
+	rec = httptest.NewRecorder()
+	writeCached(rec, r, "text/plain", []byte("hello"))
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Errorf("revalidation: %d with %d bytes, want an empty 304", rec.Code, rec.Body.Len())
+	}
+	if rec.Header().Get("ETag") != etag {
+		t.Errorf("304 should repeat the ETag")
+	}
+}
+
+func TestJSONFieldCaseRoundTrip(t *testing.T) {
+	saved := jsonFieldCase
+	t.Cleanup(func() { jsonFieldCase = saved })
+
+	car := CarListing{
+		ID: 3, Make: "Aston Martin", Model: "DB11", VIN: "SCFRMFAW0HGL01234", Year: 2017,
+		Mileage: 21000, Horsepower: 600, PricePerHP: 245.83, FuelType: "petrol",
+		Transmission: "automatic", Condition: "used", Price: 147500, Description: "V12, full history",
+		ImageURL: "/uploads/db11.jpg", Images: []string{"/uploads/db11.jpg", "/uploads/db11-rear.jpg"},
+		Seller: "alice", ListedAt: "2026-09-01T10:00:00Z", UpdatedAt: "2026-09-02T10:00:00Z", Views: 88,
+		Status: statusSold, SoldAt: "2026-09-20T10:00:00Z", SalePrice: 141000, Negotiable: true,
+		BoostedUntil: "2026-09-10T10:00:00Z", ReservedBy: "bob", ReservedUntil: "2026-09-12T10:00:00Z",
+		Boosted: true, QualityScore: 90,
+	}
+
+	tests := []struct {
+		fieldCase string
+		keys      []string // expected in the encoded listing
+		absent    []string
+	}{
+		{"snake", []string{"fuel_type", "image_url", "price_per_hp", "reserved_until", "quality_score"}, []string{"fuelType"}},
+		{"camel", []string{"fuelType", "imageUrl", "pricePerHp", "reservedUntil", "qualityScore"}, []string{"fuel_type"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.fieldCase, func(t *testing.T) {
+			jsonFieldCase = tt.fieldCase
+
+			rec := httptest.NewRecorder()
+			respond(rec, http.StatusOK, car)
+			var encoded map[string]json.RawMessage
+			decodeData(t, rec, &encoded)
+			for _, k := range tt.keys {
+				if _, ok := encoded[k]; !ok {
+					t.Errorf("encoded listing missing key %q: %s", k, rec.Body.String())
+				}
+			}
+			for _, k := range tt.absent {
+				if _, ok := encoded[k]; ok {
+					t.Errorf("encoded listing has %q in %s mode", k, tt.fieldCase)
+				}
+			}
+
+			// What a client receives it can send straight back
+			data, _ := json.Marshal(encoded)
+			r := httptest.NewRequest("POST", "/api/cars", bytes.NewReader(data))
+			var decoded CarListing
+			if status, err := decodeJSON(httptest.NewRecorder(), r, &decoded, maxBodyBytes); err != nil {
+				t.Fatalf("decodeJSON = %d %v", status, err)
+			}
+			if !reflect.DeepEqual(decoded, car) {
+				t.Errorf("round trip changed the listing:\n got %+v\nwant %+v", decoded, car)
+			}
+		})
+	}
+}
+
+func TestCamelCaseKeepsDataKeys(t *testing.T) {
+	saved := jsonFieldCase
+	t.Cleanup(func() { jsonFieldCase = saved })
+	jsonFieldCase = "camel"
+
+	t.Run("base prices", func(t *testing.T) {
+		useBasePrices(t)
+		rec := putBasePrices(t, map[string]interface{}{
+			"BMW":        99999,
+			"Land Rover": map[string]interface{}{"price": 88888, "updatedAt": "2026-01-02T00:00:00Z"},
+		})
+		if rec.Code != http.StatusOK {
+			t.Fatalf("PUT = %d: %s", rec.Code, rec.Body.String())
+		}
+		if price, ok := lookupBasePrice("BMW"); !ok || price != 99999 {
+			t.Errorf("BMW tier = %v (known %v), want 99999", price, ok)
+		}
+		if price, ok := lookupBasePrice("Land Rover"); !ok || price != 88888 {
+			t.Errorf("Land Rover tier = %v (known %v), want 88888", price, ok)
+		}
+
+		var tiers map[string]map[string]json.RawMessage
+		decodeData(t, rec, &tiers)
+		rover, ok := tiers["land rover"]
+		if !ok {
+			t.Fatalf("tier table lost the make key: %s", rec.Body.String())
+		}
+		if string(rover["updatedAt"]) != `"2026-01-02T00:00:00Z"` {
+			t.Errorf("land rover updatedAt = %s, want the sent date", rover["updatedAt"])
+		}
+		if _, ok := tiers["b_m_w"]; ok {
+			t.Errorf("make key was rewritten: %s", rec.Body.String())
+		}
+	})
+
+	t.Run("valuation messages", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		valuationMessagesHandler(rec, httptest.NewRequest("GET", "/api/valuate/messages?lang=en", nil))
+		var got struct {
+			Languages []string          `json:"languages"`
+			Messages  map[string]string `json:"messages"`
+		}
+		decodeData(t, rec, &got)
+		if _, ok := got.Messages["mileage_very_high"]; !ok {
+			t.Errorf("factor keys were rewritten: %s", rec.Body.String())
+		}
+		if _, ok := got.Messages["mileageVeryHigh"]; ok {
+			t.Errorf("factor key camelCased in %s", rec.Body.String())
+		}
+	})
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ─── POST /api/cars/{id}/share ────────────────────────────────────────────────
+
+// shareCarHandler creates a short opaque link to a listing.
+// Codes are 48 random bits (8 URL-safe characters), so guessing one is
+// impractical, and they expire after shareLinkTTL when that is non-zero.
+// A listing may have at most maxShareLinksPerCar live links; past that the
+// request gets 409 until some expire.
+//
+// Response: { "code": "q3Zx_9aB", "url": "/s/q3Zx_9aB", "expires_at": "..." }
+func shareCarHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseCarID(r.URL.Path)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
+		return
+	}
+	if !carExists(id) {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+
+	link := shareLink{CarID: id}
+	if shareLinkTTL > 0 {
+		link.ExpiresAt = time.Now().Add(shareLinkTTL)
+	}
+
+	shareLinksMu.Lock()
+	if liveShareLinks(id, time.Now()) >= maxShareLinksPerCar {
+		shareLinksMu.Unlock()
+		respondError(w, http.StatusConflict, errCodeConflict, "this listing has too many active share links")
+		return
+	}
+	var code string
+	for {
+		code, err = newShareCode()
+		if err != nil {
+			shareLinksMu.Unlock()
+			respondError(w, http.StatusInternalServerError, errCodeInternal, "failed to generate share code")
+			return
+		}
+		if _, taken := shareLinks[code]; !taken {
+			break
+		}
+	}
+	shareLinks[code] = link
+	markCarsDirty()
+	shareLinksMu.Unlock()
+
+	data := map[string]interface{}{
+		"code": code,
+		"url":  "/s/" + code,
+	}
+	if !link.ExpiresAt.IsZero() {
+		data["expires_at"] = link.ExpiresAt.Format(time.RFC3339)
+	}
+	respond(w, http.StatusCreated, data)
+}
+
+// ─── GET /s/{code} ────────────────────────────────────────────────────────────
+
+// shareRedirectHandler resolves a short code and 302s to the listing page.
+// Unknown and expired codes return 404; expired ones are dropped on the way.
+func shareRedirectHandler(w http.ResponseWriter, r *http.Request) {
+	code := strings.TrimPrefix(r.URL.Path, "/s/")
+
+	shareLinksMu.Lock()
+	link, ok := shareLinks[code]
+	if ok && link.expired(time.Now()) {
+		delete(shareLinks, code)
+		ok = false
+	}
+	shareLinksMu.Unlock()
+
+	if !ok || !carExists(link.CarID) {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "share link not found or expired")
+		return
+	}
+
+	http.Redirect(w, r, "/collection?car="+strconv.Itoa(link.CarID), http.StatusFound)
+}
+
+// ─── Helper ───────────────────────────────────────────────────────────────────
+
+// shareLink maps a short code to a listing. A zero ExpiresAt never expires.
+type shareLink struct {
+	CarID     int       `json:"car_id"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+func (l shareLink) expired(now time.Time) bool {
+	return !l.ExpiresAt.IsZero() && now.After(l.ExpiresAt)
+}
+
+// liveShareLinks counts carID's unexpired links at now. The caller holds
+// shareLinksMu.
+func liveShareLinks(carID int, now time.Time) int {
+	n := 0
+	for _, link := range shareLinks {
+		if link.CarID == carID && !link.expired(now) {
+			n++
+		}
+	}
+	return n
+}
+
+// sweepShareLinks drops links that have expired at now or whose listing is
+// gone, and reports how many were removed. It runs with the deleted-listing
+// purge so codes nobody resolves don't pile up.
+func sweepShareLinks(now time.Time) int {
+	storeMu.RLock()
+	live := make(map[int]bool, len(carStore))
+	for id := range carStore {
+		live[id] = true
+	}
+	storeMu.RUnlock()
+
+	shareLinksMu.Lock()
+	defer shareLinksMu.Unlock()
+
+	removed := 0
+	for code, link := range shareLinks {
+		if link.expired(now) || !live[link.CarID] {
+			delete(shareLinks, code)
+			removed++
+		}
+	}
+	if removed > 0 {
+		markCarsDirty()
+	}
+	return removed
+}
+
+// newShareCode returns a random 8-character URL-safe code.
+func newShareCode() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+	"time"
+)
+
+// share creates a share link for carID and returns the response.
+func share(carID string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	shareCarHandler(rec, asUser(httptest.NewRequest("POST", "/api/cars/"+carID+"/share", nil), "bob"))
+	return rec
+}
+
+// resolve requests /s/{code} and returns the response.
+func resolve(code string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	shareRedirectHandler(rec, httptest.NewRequest("GET", "/s/"+code, nil))
+	return rec
+}
+
+func TestShareLinkRedirect(t *testing.T) {
+	useStore(t, CarListing{ID: 7})
+
+	rec := share("7")
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("share = %d: %s", rec.Code, rec.Body.String())
+	}
+	var got struct {
+		Code      string `json:"code"`
+		URL       string `json:"url"`
+		ExpiresAt string `json:"expires_at"`
+	}
+	decodeData(t, rec, &got)
+	if !regexp.MustCompile(`^[A-Za-z0-9_-]{8}$`).MatchString(got.Code) || got.URL != "/s/"+got.Code {
+		t.Errorf("code %q, url %q; want an 8-character URL-safe code under /s/", got.Code, got.URL)
+	}
+	expires, err := time.Parse(time.RFC3339, got.ExpiresAt)
+	if err != nil || expires.Before(time.Now().Add(shareLinkTTL-time.Minute)) {
+		t.Errorf("expires_at = %q, want about %v from now", got.ExpiresAt, shareLinkTTL)
+	}
+
+	redirect := resolve(got.Code)
+	if redirect.Code != http.StatusFound || redirect.Header().Get("Location") != "/collection?car=7" {
+		t.Errorf("resolve = %d to %q, want 302 to /collection?car=7", redirect.Code, redirect.Header().Get("Location"))
+	}
+
+	// Codes don't repeat
+	seen := map[string]bool{got.Code: true}
+	for i := 0; i < 10; i++ {
+		var next struct {
+			Code string `json:"code"`
+		}
+		decodeData(t, share("7"), &next)
+		if seen[next.Code] {
+			t.Fatalf("code %q issued twice", next.Code)
+		}
+		seen[next.Code] = true
+	}
+
+	if rec := share("99"); rec.Code != http.StatusNotFound {
+		t.Errorf("share of a missing car = %d, want 404", rec.Code)
+	}
+	if rec := resolve("nope1234"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown code = %d, want 404", rec.Code)
+	}
+}
+
+func TestShareLinkExpiry(t *testing.T) {
+	useStore(t, CarListing{ID: 1}, CarListing{ID: 2})
+	now := time.Now()
+	shareLinks["expired1"] = shareLink{CarID: 1, ExpiresAt: now.Add(-time.Minute)}
+	shareLinks["forever1"] = shareLink{CarID: 1}
+	shareLinks["orphan01"] = shareLink{CarID: 99, ExpiresAt: now.Add(time.Hour)}
+	shareLinks["current2"] = shareLink{CarID: 2, ExpiresAt: now.Add(time.Hour)}
+
+	if rec := resolve("expired1"); rec.Code != http.StatusNotFound {
+		t.Errorf("expired code = %d, want 404", rec.Code)
+	}
+	if _, ok := shareLinks["expired1"]; ok {
+		t.Errorf("expired code was not dropped when resolved")
+	}
+	if rec := resolve("forever1"); rec.Code != http.StatusFound {
+		t.Errorf("code without expiry = %d, want 302", rec.Code)
+	}
+
+	shareLinks["expired2"] = shareLink{CarID: 2, ExpiresAt: now.Add(-time.Minute)}
+	if n := sweepShareLinks(now); n != 2 {
+		t.Errorf("sweep removed %d links, want 2 (expired and orphaned)", n)
+	}
+	for _, code := range []string{"forever1", "current2"} {
+		if _, ok := shareLinks[code]; !ok {
+			t.Errorf("sweep dropped live code %q", code)
+		}
+	}
+	if len(shareLinks) != 2 {
+		t.Errorf("after sweep: %d links, want 2", len(shareLinks))
+	}
+}
+
+func TestShareLinkCapPerCar(t *testing.T) {
+	useStore(t, CarListing{ID: 1}, CarListing{ID: 2})
+	// Expired links don't count against the cap
+	shareLinks["stale001"] = shareLink{CarID: 1, ExpiresAt: time.Now().Add(-time.Hour)}
+
+	for i := 0; i < maxShareLinksPerCar; i++ {
+		if rec := share("1"); rec.Code != http.StatusCreated {
+			t.Fatalf("link %d = %d, want 201", i+1, rec.Code)
+		}
+	}
+	if rec := share("1"); rec.Code != http.StatusConflict {
+		t.Errorf("link past the cap = %d, want 409", rec.Code)
+	}
+	if rec := share("2"); rec.Code != http.StatusCreated {
+		t.Errorf("another car = %d, want 201", rec.Code)
+	}
+}
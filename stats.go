@@ -1,29 +1,195 @@
 package main
 
-import "net/http"
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
 
 // ─── GET /api/stats ───────────────────────────────────────────────────────────
 
 // statsHandler returns a live overview of the car marketplace.
 // All calculations are done in a single pass over the store for efficiency.
+//
+// Query params:
+//
+//	per_make   — "true" adds make_extremes: the cheapest and most expensive
+//	             listing within each make
+//	top_viewed — N adds top_viewed: the N most-viewed listings (max 50)
+//	days       — N days covered by listings_over_time (default 30)
+//	scope      — "active" (default), "sold" or "all"; which listings count, so
+//	             retained sold stock doesn't skew prices and totals
 func statsHandler(w http.ResponseWriter, r *http.Request) {
+	opts, err := parseStatsOptions(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, err.Error())
+		return
+	}
+
+	storeMu.RLock()
+	cars := make([]CarListing, 0, len(carStore))
+	for _, car := range carStore {
+		if opts.covers(car) {
+			cars = append(cars, liveViews(car))
+		}
+	}
+	storeMu.RUnlock()
+
+	stats := computeStats(cars, opts)
+	stats["scope"] = opts.Scope
+	respond(w, http.StatusOK, stats)
+}
+
+// ─── GET /api/stats/seller/{username} ─────────────────────────────────────────
+
+// sellerStatsHandler returns the same overview scoped to one seller's listings.
+// Sellers may only view their own storefront; admins may view anyone's.
+func sellerStatsHandler(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+
+	opts, err := parseStatsOptions(r)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, err.Error())
+		return
+	}
+
+	seller := strings.TrimPrefix(r.URL.Path, "/api/stats/seller/")
+	if seller == "" || strings.Contains(seller, "/") {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid seller")
+		return
+	}
+	if seller != claims.Username && !isAdmin(claims.Username) {
+		respondError(w, http.StatusForbidden, errCodeForbidden, "you can only view your own stats")
+		return
+	}
+
 	storeMu.RLock()
-	defer storeMu.RUnlock()
+	var cars []CarListing
+	for _, car := range carStore {
+		if car.Seller == seller && opts.covers(car) {
+			cars = append(cars, liveViews(car))
+		}
+	}
+	storeMu.RUnlock()
+
+	stats := computeStats(cars, opts)
+	stats["seller"] = seller
+	stats["scope"] = opts.Scope
+	respond(w, http.StatusOK, stats)
+}
 
-	total := len(carStore)
+// makeExtremes holds the price extremes within a single make.
+type makeExtremes struct {
+	Cheapest      CarListing `json:"cheapest"`
+	MostExpensive CarListing `json:"most_expensive"`
+}
+
+// statsOptions selects the optional sections of a stats response.
+type statsOptions struct {
+	PerMake   bool // per-make cheapest/most-expensive
+	TopViewed int  // size of the top_viewed list (0 = omit)
+	Days      int  // days covered by listings_over_time
+	Scope     string
+}
+
+// validStatsScope reports whether scope names a set of listings stats can
+// cover.
+func validStatsScope(scope string) bool {
+	return scope == "active" || scope == "sold" || scope == "all"
+}
+
+// covers reports whether car falls within the options' scope. Archived
+// listings only count towards "all"; deleted ones never count.
+func (o statsOptions) covers(car CarListing) bool {
+	switch o.Scope {
+	case "active":
+		return car.Status == statusActive
+	case "sold":
+		return car.Status == statusSold
+	}
+	return car.Status != statusDeleted
+}
+
+// parseStatsOptions reads the optional-section query params shared by the
+// stats endpoints.
+func parseStatsOptions(r *http.Request) (statsOptions, error) {
+	q := r.URL.Query()
+	opts := statsOptions{PerMake: q.Get("per_make") == "true", Days: statsDefaultDays, Scope: statsDefaultScope}
+	if raw := q.Get("top_viewed"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > 50 {
+			return opts, errors.New("top_viewed must be an integer between 0 and 50")
+		}
+		opts.TopViewed = n
+	}
+	if raw := q.Get("days"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > statsMaxDays {
+			return opts, fmt.Errorf("days must be an integer between 1 and %d", statsMaxDays)
+		}
+		opts.Days = n
+	}
+	if raw := q.Get("scope"); raw != "" {
+		if !validStatsScope(raw) {
+			return opts, errors.New(`scope must be "active", "sold" or "all"`)
+		}
+		opts.Scope = raw
+	}
+	return opts, nil
+}
+
+// computeStats aggregates a set of listings in a single pass.
+// Shared by the marketplace-wide and per-seller stats endpoints.
+//
+// most_viewed is null unless the top listing has at least minTrendingViews,
+// since on a tiny store "most viewed" can mean a handful of clicks.
+// Percentiles are robust to outliers where average_price isn't; prices are
+// collected during the pass and sorted once at the end.
+//
+// listings_over_time counts listings by the server-local day of ListedAt
+// over the last opts.Days days, oldest first, with zero-count days filled
+// in so a chart has no holes. Listings with a malformed ListedAt are left
+// out of the series.
+func computeStats(cars []CarListing, opts statsOptions) map[string]interface{} {
+	total := len(cars)
 	totalValue := 0.0
+	totalViews := 0
 	fuelBreakdown := map[string]int{}
 	condBreakdown := map[string]int{}
+	makeBreakdown := map[string]int{}
+	makeValue := map[string]float64{}
 
 	// Track extremes for the summary cards
 	topViewed := CarListing{}
 	cheapest := CarListing{Price: 1e12} // start high so first real car wins
 	mostExpensive := CarListing{}
+	extremes := map[string]*makeExtremes{}
+	prices := make([]float64, 0, total)
+	histogram := map[string]int{}
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	firstDay := today.AddDate(0, 0, 1-opts.Days)
+	perDay := map[string]int{}
 
-	for _, car := range carStore {
+	for _, car := range cars {
 		totalValue += car.Price
+		prices = append(prices, car.Price)
+		histogram[priceBucket(car.Price)]++
+		totalViews += car.Views
 		fuelBreakdown[car.FuelType]++
 		condBreakdown[car.Condition]++
+		brand := normalizeMake(car.Make)
+		makeBreakdown[brand]++
+		makeValue[brand] += car.Price
+		if listed := listedTime(car); !listed.IsZero() && !listed.Before(firstDay) {
+			perDay[listed.In(time.Local).Format(time.DateOnly)]++
+		}
 
 		if car.Views > topViewed.Views {
 			topViewed = car
@@ -34,6 +200,16 @@ func statsHandler(w http.ResponseWriter, r *http.Request) {
 		if car.Price > mostExpensive.Price {
 			mostExpensive = car
 		}
+
+		if opts.PerMake {
+			if e, ok := extremes[brand]; !ok {
+				extremes[brand] = &makeExtremes{Cheapest: car, MostExpensive: car}
+			} else if car.Price < e.Cheapest.Price {
+				e.Cheapest = car
+			} else if car.Price > e.MostExpensive.Price {
+				e.MostExpensive = car
+			}
+		}
 	}
 
 	avgPrice := 0.0
@@ -41,14 +217,177 @@ func statsHandler(w http.ResponseWriter, r *http.Request) {
 		avgPrice = totalValue / float64(total)
 	}
 
-	respond(w, http.StatusOK, map[string]interface{}{
+	sort.Float64s(prices)
+
+	avgByMake := make(map[string]float64, len(makeBreakdown))
+	for brand, n := range makeBreakdown {
+		avgByMake[brand] = roundPrice(makeValue[brand] / float64(n))
+	}
+
+	overTime := make([]dailyCount, 0, opts.Days)
+	for day := firstDay; !day.After(today); day = day.AddDate(0, 0, 1) {
+		date := day.Format(time.DateOnly)
+		overTime = append(overTime, dailyCount{Date: date, Count: perDay[date]})
+	}
+
+	var mostViewed interface{}
+	if topViewed.Views >= minTrendingViews && topViewed.ID != 0 {
+		mostViewed = topViewed
+	}
+
+	stats := map[string]interface{}{
 		"total_listings":      total,
-		"total_value":         roundToHundred(totalValue),
-		"average_price":       roundToHundred(avgPrice),
+		"total_value":         roundPrice(totalValue),
+		"average_price":       roundPrice(avgPrice),
+		"median_price":        roundPrice(percentile(prices, 50)),
+		"p25_price":           roundPrice(percentile(prices, 25)),
+		"p75_price":           roundPrice(percentile(prices, 75)),
+		"price_histogram":     histogram,
+		"total_views":         totalViews,
 		"fuel_breakdown":      fuelBreakdown,
 		"condition_breakdown": condBreakdown,
-		"most_viewed":         topViewed,
+		"make_breakdown":      makeBreakdown,
+		"avg_price_by_make":   avgByMake,
+		"listings_over_time":  overTime,
+		"most_viewed":         mostViewed,
 		"cheapest":            cheapest,
 		"most_expensive":      mostExpensive,
-	}, "")
+	}
+	if opts.PerMake {
+		stats["make_extremes"] = extremes
+	}
+	if opts.TopViewed > 0 {
+		stats["top_viewed"] = topViewedListings(cars, opts.TopViewed)
+	}
+	return stats
+}
+
+// dailyCount is one day of the listings_over_time series.
+type dailyCount struct {
+	Date  string `json:"date"` // YYYY-MM-DD, server-local
+	Count int    `json:"count"`
+}
+
+// normalizeMake trims a make and title-cases each word, so "bmw", "BMW" and
+// " Bmw " share one bucket ("Bmw"), as do "aston martin" and "Aston Martin".
+func normalizeMake(name string) string {
+	words := strings.Fields(name)
+	for i, w := range words {
+		runes := []rune(strings.ToLower(w))
+		runes[0] = unicode.ToUpper(runes[0])
+		words[i] = string(runes)
+	}
+	return strings.Join(words, " ")
+}
+
+// percentile returns the p-th percentile (0–100) of sorted, interpolating
+// linearly between the two nearest ranks. An empty slice gives 0.
+func percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
+
+// priceBucket labels the price_histogram bucket for price: priceBucketSize
+// wide buckets like "50k-100k", with everything from priceBucketMax up in a
+// single open-ended bucket ("500k+").
+func priceBucket(price float64) string {
+	if price >= priceBucketMax {
+		return shortPrice(priceBucketMax) + "+"
+	}
+	lo := math.Floor(price/priceBucketSize) * priceBucketSize
+	return shortPrice(lo) + "-" + shortPrice(lo+priceBucketSize)
+}
+
+// shortPrice formats a round price in thousands, e.g. 50000 → "50k".
+func shortPrice(v float64) string {
+	if v == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(v/1000, 'f', -1, 64) + "k"
+}
+
+// topViewedListings returns up to n listings with at least minTrendingViews,
+// most-viewed first (ties broken by ID so the order is stable).
+func topViewedListings(cars []CarListing, n int) []CarListing {
+	top := []CarListing{}
+	for _, car := range cars {
+		if car.Views >= minTrendingViews {
+			top = append(top, car)
+		}
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Views != top[j].Views {
+			return top[i].Views > top[j].Views
+		}
+		return top[i].ID < top[j].ID
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// ─── GET /api/stats/sales ─────────────────────────────────────────────────────
+
+// saleDiscount aggregates ask-vs-sale discounts for a group of sold listings.
+type saleDiscount struct {
+	Count              int     `json:"count"`
+	AtOrAboveAsk       int     `json:"at_or_above_ask"`
+	AverageDiscountPct float64 `json:"average_discount_pct"` // negative = sold above ask
+	sumPct             float64
+}
+
+// salesStatsHandler reports how far sold cars went below their asking price,
+// overall and per make, so dealers can see their real negotiation gap.
+// Counts are returned alongside averages so small samples are obvious.
+// Sold listings without a recorded sale price are skipped.
+func salesStatsHandler(w http.ResponseWriter, r *http.Request) {
+	overall := &saleDiscount{}
+	byMake := map[string]*saleDiscount{}
+
+	storeMu.RLock()
+	for _, car := range carStore {
+		if car.Status != statusSold || car.SalePrice <= 0 || car.Price <= 0 {
+			continue
+		}
+		pct := (car.Price - car.SalePrice) / car.Price * 100
+
+		key := normalizeMake(car.Make)
+		if byMake[key] == nil {
+			byMake[key] = &saleDiscount{}
+		}
+		for _, d := range []*saleDiscount{overall, byMake[key]} {
+			d.Count++
+			d.sumPct += pct
+			if car.SalePrice >= car.Price {
+				d.AtOrAboveAsk++
+			}
+		}
+	}
+	storeMu.RUnlock()
+
+	for _, d := range append([]*saleDiscount{overall}, mapValues(byMake)...) {
+		if d.Count > 0 {
+			d.AverageDiscountPct = math.Round(d.sumPct/float64(d.Count)*10) / 10
+		}
+	}
+
+	respond(w, http.StatusOK, map[string]interface{}{
+		"overall": overall,
+		"by_make": byMake,
+	})
+}
+
+// mapValues returns the values of m in unspecified order.
+func mapValues[K comparable, V any](m map[K]V) []V {
+	vals := make([]V, 0, len(m))
+	for _, v := range m {
+		vals = append(vals, v)
+	}
+	return vals
 }
@@ -0,0 +1,252 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// statsAs calls h for target as user and returns the status and decoded data.
+func statsAs(t *testing.T, h http.HandlerFunc, target, user string) (int, map[string]interface{}) {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	h(rec, asUser(httptest.NewRequest("GET", target, nil), user))
+	var data map[string]interface{}
+	if rec.Code == http.StatusOK {
+		decodeData(t, rec, &data)
+	}
+	return rec.Code, data
+}
+
+func TestSellerStatsAccess(t *testing.T) {
+	useStore(t, CarListing{Seller: "alice", Make: "BMW", Price: 50000})
+
+	tests := []struct {
+		user, target string
+		want         int
+	}{
+		{"alice", "/api/stats/seller/alice", http.StatusOK},
+		{"bob", "/api/stats/seller/alice", http.StatusForbidden},
+		{"seller", "/api/stats/seller/alice", http.StatusOK}, // admin
+		{"alice", "/api/stats/seller/", http.StatusBadRequest},
+		{"alice", "/api/stats/seller/alice/extra", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if code, _ := statsAs(t, sellerStatsHandler, tt.target, tt.user); code != tt.want {
+			t.Errorf("%s GET %s = %d, want %d", tt.user, tt.target, code, tt.want)
+		}
+	}
+}
+
+func TestSellerStatsAggregates(t *testing.T) {
+	useStore(t,
+		CarListing{Seller: "alice", Make: "BMW", Price: 40000, Views: 10, FuelType: "petrol"},
+		CarListing{Seller: "alice", Make: "bmw", Price: 60000, Views: 30, FuelType: "diesel"},
+		CarListing{Seller: "alice", Make: "Audi", Price: 80000, Views: 5, FuelType: "petrol"},
+		CarListing{Seller: "alice", Make: "Audi", Price: 999000, Status: statusSold},
+		CarListing{Seller: "bob", Make: "Ferrari", Price: 300000, Views: 500},
+	)
+
+	_, data := statsAs(t, sellerStatsHandler, "/api/stats/seller/alice", "alice")
+	if data["seller"] != "alice" || data["scope"] != "active" {
+		t.Errorf("seller/scope = %v/%v, want alice/active", data["seller"], data["scope"])
+	}
+	checks := map[string]float64{
+		"total_listings": 3,
+		"total_value":    180000,
+		"average_price":  60000,
+		"total_views":    45,
+	}
+	for key, want := range checks {
+		if got, _ := data[key].(float64); got != want {
+			t.Errorf("%s = %v, want %v", key, data[key], want)
+		}
+	}
+	makes, _ := data["make_breakdown"].(map[string]interface{})
+	if makes["Bmw"] != 2.0 || makes["Audi"] != 1.0 || makes["Ferrari"] != nil {
+		t.Errorf("make_breakdown = %v, want Bmw 2, Audi 1 and none of bob's", makes)
+	}
+	fuel, _ := data["fuel_breakdown"].(map[string]interface{})
+	if fuel["petrol"] != 2.0 || fuel["diesel"] != 1.0 {
+		t.Errorf("fuel_breakdown = %v, want petrol 2, diesel 1", fuel)
+	}
+	if p := data["most_expensive"].(map[string]interface{})["price"]; p != 80000.0 {
+		t.Errorf("most_expensive price = %v, want 80000", p)
+	}
+}
+
+func TestStatsPerMakeExtremes(t *testing.T) {
+	useStore(t,
+		CarListing{ID: 1, Make: "BMW", Price: 60000},
+		CarListing{ID: 2, Make: "bmw", Price: 35000},
+		CarListing{ID: 3, Make: " Bmw ", Price: 90000},
+		CarListing{ID: 4, Make: "aston martin", Price: 150000},
+		CarListing{ID: 5, Make: "Aston Martin", Price: 210000},
+		CarListing{ID: 6, Make: "Honda", Price: 20000},
+		CarListing{ID: 7, Make: "BMW", Price: 10000, Status: statusSold},
+	)
+
+	code, data := statsAs(t, statsHandler, "/api/stats?per_make=true", "alice")
+	if code != http.StatusOK {
+		t.Fatalf("status = %d", code)
+	}
+	extremes, _ := data["make_extremes"].(map[string]interface{})
+	want := map[string][2]float64{ // cheapest, most expensive ID
+		"Bmw":          {2, 3},
+		"Aston Martin": {4, 5},
+		"Honda":        {6, 6},
+	}
+	if len(extremes) != len(want) {
+		t.Errorf("make_extremes has makes %v, want %v", keys(extremes), want)
+	}
+	for brand, ids := range want {
+		e, _ := extremes[brand].(map[string]interface{})
+		if e == nil {
+			t.Errorf("no extremes for %s", brand)
+			continue
+		}
+		cheapID := e["cheapest"].(map[string]interface{})["id"]
+		topID := e["most_expensive"].(map[string]interface{})["id"]
+		if cheapID != ids[0] || topID != ids[1] {
+			t.Errorf("%s: cheapest #%v, most expensive #%v; want #%v, #%v", brand, cheapID, topID, ids[0], ids[1])
+		}
+	}
+
+	if _, data := statsAs(t, statsHandler, "/api/stats", "alice"); data["make_extremes"] != nil {
+		t.Errorf("make_extremes present without per_make")
+	}
+}
+
+func keys(m map[string]interface{}) []string {
+	out := make([]string, 0, len(m))
+	for k := range m {
+		out = append(out, k)
+	}
+	return out
+}
+
+func TestSalesStatsDiscounts(t *testing.T) {
+	useStore(t,
+		CarListing{Make: "BMW", Price: 100000, SalePrice: 90000, Status: statusSold}, // 10% off
+		CarListing{Make: "bmw", Price: 50000, SalePrice: 47500, Status: statusSold},  // 5% off
+		CarListing{Make: "Audi", Price: 80000, SalePrice: 84000, Status: statusSold}, // 5% over
+		CarListing{Make: "Audi", Price: 40000, SalePrice: 40000, Status: statusSold}, // at ask
+		CarListing{Make: "Audi", Price: 60000, Status: statusSold},                   // no sale price
+		CarListing{Make: "Ferrari", Price: 300000, SalePrice: 1000, Status: statusActive},
+	)
+
+	rec := httptest.NewRecorder()
+	salesStatsHandler(rec, asUser(httptest.NewRequest("GET", "/api/stats/sales", nil), "alice"))
+	var got struct {
+		Overall saleDiscount            `json:"overall"`
+		ByMake  map[string]saleDiscount `json:"by_make"`
+	}
+	decodeData(t, rec, &got)
+
+	if got.Overall.Count != 4 || got.Overall.AtOrAboveAsk != 2 || got.Overall.AverageDiscountPct != 2.5 {
+		t.Errorf("overall = %+v, want 4 sales, 2 at/above ask, 2.5%% average", got.Overall)
+	}
+	if bmw := got.ByMake["Bmw"]; bmw.Count != 2 || bmw.AverageDiscountPct != 7.5 {
+		t.Errorf("Bmw = %+v, want 2 sales averaging 7.5%%", bmw)
+	}
+	if audi := got.ByMake["Audi"]; audi.Count != 2 || audi.AtOrAboveAsk != 2 || audi.AverageDiscountPct != -2.5 {
+		t.Errorf("Audi = %+v, want 2 sales at/above ask averaging -2.5%%", audi)
+	}
+	if _, ok := got.ByMake["Ferrari"]; ok {
+		t.Errorf("an active listing was counted as a sale")
+	}
+}
+
+func TestStatsTopViewed(t *testing.T) {
+	useStore(t,
+		CarListing{ID: 1, Make: "BMW", Price: 40000, Views: minTrendingViews + 10},
+		CarListing{ID: 2, Make: "Audi", Price: 50000, Views: minTrendingViews + 50},
+		CarListing{ID: 3, Make: "Kia", Price: 20000, Views: minTrendingViews - 1},
+		CarListing{ID: 4, Make: "Fiat", Price: 15000, Views: minTrendingViews + 10},
+		CarListing{ID: 5, Make: "Mini", Price: 25000, Views: minTrendingViews},
+	)
+
+	_, data := statsAs(t, statsHandler, "/api/stats?top_viewed=3", "bob")
+	top, _ := data["top_viewed"].([]interface{})
+	var ids []float64
+	for _, car := range top {
+		ids = append(ids, car.(map[string]interface{})["id"].(float64))
+	}
+	// Most viewed first, equal views by ID, capped at 3
+	if want := []float64{2, 1, 4}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("top_viewed ids = %v, want %v", ids, want)
+	}
+	if mv, _ := data["most_viewed"].(map[string]interface{}); mv == nil || mv["id"] != 2.0 {
+		t.Errorf("most_viewed = %v, want car 2", data["most_viewed"])
+	}
+
+	// The threshold is inclusive; cars below it never show up
+	_, data = statsAs(t, statsHandler, "/api/stats?top_viewed=50", "bob")
+	if top, _ := data["top_viewed"].([]interface{}); len(top) != 4 {
+		t.Errorf("top_viewed has %d cars, want the 4 at or above %d views", len(top), minTrendingViews)
+	}
+	if _, data = statsAs(t, statsHandler, "/api/stats", "bob"); data["top_viewed"] != nil {
+		t.Errorf("top_viewed present without the param: %v", data["top_viewed"])
+	}
+}
+
+func TestStatsMostViewedThreshold(t *testing.T) {
+	useStore(t,
+		CarListing{Make: "BMW", Price: 40000, Views: minTrendingViews - 1},
+		CarListing{Make: "Audi", Price: 50000, Views: 3},
+	)
+	_, data := statsAs(t, statsHandler, "/api/stats?top_viewed=5", "bob")
+	if mv, ok := data["most_viewed"]; !ok || mv != nil {
+		t.Errorf("most_viewed = %v (present %v), want null below %d views", mv, ok, minTrendingViews)
+	}
+	if top, ok := data["top_viewed"].([]interface{}); !ok || len(top) != 0 {
+		t.Errorf("top_viewed = %v, want []", data["top_viewed"])
+	}
+}
+
+func TestStatsScopes(t *testing.T) {
+	deleted := time.Now().Format(time.RFC3339)
+	useStore(t,
+		CarListing{Seller: "alice", Make: "BMW", Price: 40000},
+		CarListing{Seller: "alice", Make: "BMW", Price: 60000},
+		CarListing{Seller: "alice", Make: "Audi", Price: 100000, Status: statusSold, SalePrice: 95000},
+		CarListing{Seller: "bob", Make: "Audi", Price: 200000, Status: statusSold, SalePrice: 190000},
+		CarListing{Seller: "alice", Make: "Kia", Price: 20000, Status: statusArchived},
+		CarListing{Seller: "alice", Make: "Kia", Price: 999000, Status: statusDeleted, DeletedAt: deleted},
+	)
+
+	tests := []struct {
+		target       string
+		handler      http.HandlerFunc
+		scope        string
+		listings     float64
+		totalValue   float64
+		averagePrice float64
+	}{
+		{"/api/stats", statsHandler, "active", 2, 100000, 50000},
+		{"/api/stats?scope=active", statsHandler, "active", 2, 100000, 50000},
+		{"/api/stats?scope=sold", statsHandler, "sold", 2, 300000, 150000},
+		{"/api/stats?scope=all", statsHandler, "all", 5, 420000, 84000},
+		{"/api/stats/seller/alice", sellerStatsHandler, "active", 2, 100000, 50000},
+		{"/api/stats/seller/alice?scope=sold", sellerStatsHandler, "sold", 1, 100000, 100000},
+		{"/api/stats/seller/alice?scope=all", sellerStatsHandler, "all", 4, 220000, 55000},
+	}
+	for _, tt := range tests {
+		code, data := statsAs(t, tt.handler, tt.target, "alice")
+		if code != http.StatusOK {
+			t.Errorf("%s: status = %d, want 200", tt.target, code)
+			continue
+		}
+		got := []interface{}{data["scope"], data["total_listings"], data["total_value"], data["average_price"]}
+		want := []interface{}{tt.scope, tt.listings, tt.totalValue, tt.averagePrice}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: scope, listings, total, average = %v, want %v", tt.target, got, want)
+		}
+	}
+
+	if code, _ := statsAs(t, statsHandler, "/api/stats?scope=deleted", "alice"); code != http.StatusBadRequest {
+		t.Errorf("unknown scope: status = %d, want 400", code)
+	}
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"math/rand"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -10,8 +11,97 @@ import (
 
 var (
 	carStore = make(map[int]CarListing)
-	nextID   = 1
 	storeMu  sync.RWMutex // RWMutex: many concurrent readers, one writer
+
+	// lastCarID is the most recently allocated listing ID. It's atomic so
+	// IDs can be handed out without holding storeMu; use nextCarID.
+	lastCarID atomic.Int64
+
+	// vinIndex maps VIN → car ID for listings that have one, so VIN lookups
+	// and duplicate checks are O(1). Guarded by storeMu like carStore.
+	vinIndex = make(map[string]int)
+)
+
+// nextCarID allocates a new, never-reused listing ID. Safe for concurrent use.
+func nextCarID() int {
+	return int(lastCarID.Add(1))
+}
+
+// ─── View Counter Store ───────────────────────────────────────────────────────
+// Maps car ID → view count, the authoritative source for CarListing.Views.
+// Counters are bumped with sync/atomic, so a detail view needs only the car
+// store's read lock; viewsMu guards the map itself (entries are added and
+// removed alongside listings). Listings read out of carStore get their
+// Views filled in by liveViews.
+
+var (
+	carViews = make(map[int]*int64)
+	viewsMu  sync.RWMutex
+)
+
+// ─── View History Store ───────────────────────────────────────────────────────
+// Maps car ID → timestamps of recent views, oldest first, for the view-trend
+// endpoint. Bounded: at most maxViewHistory entries per listing, and entries
+// older than viewHistoryRetention are dropped as new views arrive.
+
+var (
+	viewHistory   = make(map[int][]time.Time)
+	viewHistoryMu sync.Mutex
+)
+
+// ─── User Store ───────────────────────────────────────────────────────────────
+// Maps username → bcrypt password hash. Seeded with the demo account;
+// POST /api/register adds more.
+
+var (
+	users   = map[string]string{demoUsername: demoPasswordHash}
+	usersMu sync.RWMutex
+)
+
+// ─── Comment Store ────────────────────────────────────────────────────────────
+// Maps car ID → comments, oldest first. Capped at maxCommentsPerCar per car.
+
+var (
+	carComments   = make(map[int][]Comment)
+	nextCommentID = 1
+	commentsMu    sync.RWMutex
+)
+
+// ─── Offer Store ──────────────────────────────────────────────────────────────
+// Maps car ID → offers received, oldest first.
+
+var (
+	carOffers   = make(map[int][]Offer)
+	nextOfferID = 1
+	offersMu    sync.RWMutex
+)
+
+// ─── Image Hash Store ─────────────────────────────────────────────────────────
+// Maps car ID → image URL → perceptual hash of each uploaded photo still in
+// the gallery, for duplicate detection.
+
+var (
+	imageHashes   = make(map[int]map[string]uint64)
+	imageHashesMu sync.RWMutex
+)
+
+// ─── Share Link Store ─────────────────────────────────────────────────────────
+// Maps short share code → target listing (and optional expiry).
+
+var (
+	shareLinks   = make(map[string]shareLink)
+	shareLinksMu sync.Mutex
+)
+
+// ─── Valuation Cache ──────────────────────────────────────────────────────────
+// Maps normalized valuation input (see valuationCacheKey) → engine result, so
+// repeated valuations of the same car skip the pricing engine. Cleared
+// whenever the base price table changes; bounded at valuationCacheMaxEntries.
+
+var (
+	valuationCache     = make(map[valuationKey]cachedValuation)
+	valuationCacheYear int // calendar year of the newest entries
+	valuationCacheMu   sync.RWMutex
 )
 
 // ─── Refresh Token Store ──────────────────────────────────────────────────────
@@ -24,13 +114,28 @@ var (
 )
 
 // ─── Rate Limit Store ─────────────────────────────────────────────────────────
-// Maps IP address → slice of request timestamps (sliding window).
+// Maps limiter key ("ip:…" or "user:…") → its token bucket.
+
+// tokenBucket holds the tokens left for one key as of lastRefill; the
+// refill since then is computed lazily on the next check.
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
 
 var (
-	rateLimiter   = make(map[string][]time.Time)
+	rateLimiter   = make(map[string]*tokenBucket)
 	rateLimiterMu sync.Mutex
 )
 
+// ─── In-Flight Request Store ──────────────────────────────────────────────────
+// Maps IP address → number of requests currently being served.
+
+var (
+	inFlight   = make(map[string]int)
+	inFlightMu sync.Mutex
+)
+
 // ─── Seed Demo Data ───────────────────────────────────────────────────────────
 
 // seedDemoInventory populates the car store with realistic demo listings.
@@ -38,37 +143,37 @@ var (
 func seedDemoInventory() {
 	demo := []CarListing{
 		{
-			Make: "McLaren", Model: "765LT", Year: 2021, Price: 358000,
+			Make: "McLaren", Model: "765LT", Year: 2021, Price: 358000, Horsepower: 755,
 			Mileage: 8200, FuelType: "petrol", Transmission: "automatic", Condition: "used",
 			Description: "Track-focused supercar. Titanium exhaust, MSO carbon pack.",
 			ImageURL:    "https://images.unsplash.com/photo-1621135802920-133df287f89c?w=800",
 		},
 		{
-			Make: "Porsche", Model: "911 GT3", Year: 2023, Price: 185000,
+			Make: "Porsche", Model: "911 GT3", Year: 2023, Price: 185000, Horsepower: 502,
 			Mileage: 3100, FuelType: "petrol", Transmission: "manual", Condition: "certified",
 			Description: "Weissach package, carbon ceramics, clubsport seats.",
 			ImageURL:    "https://images.unsplash.com/photo-1503376780353-7e6692767b70?w=800",
 		},
 		{
-			Make: "BMW", Model: "M5 CS", Year: 2022, Price: 142000,
+			Make: "BMW", Model: "M5 CS", Year: 2022, Price: 142000, Horsepower: 627,
 			Mileage: 14000, FuelType: "petrol", Transmission: "automatic", Condition: "used",
 			Description: "630hp, carbon roof, M bucket seats.",
 			ImageURL:    "https://images.unsplash.com/photo-1555215695-3004980ad54e?w=800",
 		},
 		{
-			Make: "Tesla", Model: "Model S Plaid", Year: 2023, Price: 118000,
+			Make: "Tesla", Model: "Model S Plaid", Year: 2023, Price: 118000, Horsepower: 1020,
 			Mileage: 5600, FuelType: "electric", Transmission: "automatic", Condition: "certified",
 			Description: "1020hp tri-motor. 0-100 in 2.1s. FSD included.",
 			ImageURL:    "https://images.unsplash.com/photo-1560958089-b8a1929cea89?w=800",
 		},
 		{
-			Make: "Mercedes", Model: "AMG GT R", Year: 2021, Price: 167000,
+			Make: "Mercedes", Model: "AMG GT R", Year: 2021, Price: 167000, Horsepower: 585,
 			Mileage: 11300, FuelType: "petrol", Transmission: "automatic", Condition: "used",
 			Description: "585hp V8, aero package, Green Hell Magno paint.",
 			ImageURL:    "https://images.unsplash.com/photo-1618843479313-40f8afb4b4d8?w=800",
 		},
 		{
-			Make: "Audi", Model: "R8 V10 Plus", Year: 2022, Price: 195000,
+			Make: "Audi", Model: "R8 V10 Plus", Year: 2022, Price: 195000, Horsepower: 620,
 			Mileage: 6700, FuelType: "petrol", Transmission: "automatic", Condition: "new",
 			Description: "620hp naturally aspirated V10. Laser headlights.",
 			ImageURL:    "https://images.unsplash.com/photo-1606016159991-dfe4f2746ad5?w=800",
@@ -78,11 +183,15 @@ func seedDemoInventory() {
 	storeMu.Lock()
 	defer storeMu.Unlock()
 	for i, car := range demo {
-		car.ID = nextID
+		car.ID = nextCarID()
 		car.Seller = "demo"
 		car.ListedAt = time.Now().Add(-time.Duration(i*5) * 24 * time.Hour).Format(time.RFC3339)
 		car.Views = rand.Intn(200) + 10
+		car.Status = statusActive
+		// Demo galleries: the listing photo plus a tighter crop of it
+		car.Images = []string{car.ImageURL, car.ImageURL + "&h=600&fit=crop"}
+		setPricePerHP(&car)
 		carStore[car.ID] = car
-		nextID++
+		setViews(car.ID, car.Views)
 	}
 }
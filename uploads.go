@@ -0,0 +1,278 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // register decoders for image.Decode
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"math/bits"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ─── GET /uploads/{file} ──────────────────────────────────────────────────────
+
+// uploadsHandler serves listing photos from uploadDir.
+// It uses http.ServeContent rather than http.FileServer so Range requests work
+// (206 Partial Content) for progressive loading of large photos on mobile,
+// while keeping directory listings disabled.
+func uploadsHandler(w http.ResponseWriter, r *http.Request) {
+	// path.Clean on a rooted path strips any ../ segments before we join
+	name := strings.TrimPrefix(path.Clean("/"+strings.TrimPrefix(r.URL.Path, "/uploads/")), "/")
+	if name == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	f, err := os.Open(filepath.Join(uploadDir, filepath.FromSlash(name)))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Accept-Ranges", "bytes")
+	http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+}
+
+// ─── POST /api/cars/{id}/image ────────────────────────────────────────────────
+
+// uploadImageHandler stores a photo for a listing and makes it the listing's
+// ImageURL. Only the seller may upload. The multipart form field is "image".
+//
+// Each photo is fingerprinted with a perceptual hash; if it is near-identical
+// to another listing's photo (a reused stock shot or a stolen image) the upload
+// is rejected with 409 or accepted with a warning, depending on
+// imageDuplicateMode.
+func uploadImageHandler(w http.ResponseWriter, r *http.Request) {
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+
+	id, err := parseCarID(r.URL.Path)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
+		return
+	}
+
+	storeMu.RLock()
+	car, ok := carStore[id]
+	storeMu.RUnlock()
+	if !ok {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+	if car.Seller != claims.Username {
+		respondError(w, http.StatusForbidden, errCodeForbidden, "you can only upload images to your own listings")
+		return
+	}
+	if len(car.Images) >= maxListingImages {
+		respondError(w, http.StatusConflict, errCodeConflict, galleryFullMessage())
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxUploadBytes)
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeInvalidBody, "multipart field \"image\" is required (max 10 MiB)")
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		respondError(w, http.StatusRequestEntityTooLarge, errCodePayloadTooLarge, "image too large")
+		return
+	}
+	// Read the dimensions from the header first: a few-KB PNG can declare
+	// 50000×50000 pixels and allocate gigabytes when fully decoded
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "unsupported or corrupt image (jpeg, png or gif)")
+		return
+	}
+	if cfg.Width > maxImageDimension || cfg.Height > maxImageDimension || cfg.Width*cfg.Height > maxImagePixels {
+		respondError(w, http.StatusRequestEntityTooLarge, errCodePayloadTooLarge,
+			fmt.Sprintf("image is %d×%d; at most %d pixels per side and %d in total are allowed",
+				cfg.Width, cfg.Height, maxImageDimension, maxImagePixels))
+		return
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "unsupported or corrupt image (jpeg, png or gif)")
+		return
+	}
+
+	hash := perceptualHash(img)
+	duplicates := similarImages(hash, id)
+	if len(duplicates) > 0 && imageDuplicateMode == "reject" {
+		writeEnvelope(w, http.StatusConflict, APIResponse{
+			Data:      map[string]interface{}{"duplicate_of": duplicates},
+			Error:     "image matches a photo on another listing",
+			ErrorCode: errCodeConflict,
+		})
+		return
+	}
+
+	name := fmt.Sprintf("car-%d-%d.%s", id, time.Now().UnixNano(), format)
+	if err := os.MkdirAll(uploadDir, 0o755); err != nil {
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "failed to store image")
+		return
+	}
+	if err := os.WriteFile(filepath.Join(uploadDir, name), data, 0o644); err != nil {
+		respondError(w, http.StatusInternalServerError, errCodeInternal, "failed to store image")
+		return
+	}
+
+	storeMu.Lock()
+	car, ok = carStore[id]
+	if !ok {
+		// Deleted while we were decoding
+		storeMu.Unlock()
+		os.Remove(filepath.Join(uploadDir, name))
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+	if len(car.Images) >= maxListingImages {
+		// Filled up by another upload while we were decoding
+		storeMu.Unlock()
+		os.Remove(filepath.Join(uploadDir, name))
+		respondError(w, http.StatusConflict, errCodeConflict, galleryFullMessage())
+		return
+	}
+	// The upload becomes the primary photo, ahead of the existing gallery
+	car.ImageURL = "/uploads/" + name
+	car.Images = append([]string{car.ImageURL}, car.Images...)
+	carStore[id] = car
+	markCarsDirty()
+
+	// Recorded under storeMu so a concurrent edit can't remove the photo
+	// before its hash exists
+	imageHashesMu.Lock()
+	if imageHashes[id] == nil {
+		imageHashes[id] = make(map[string]uint64)
+	}
+	imageHashes[id][car.ImageURL] = hash
+	imageHashesMu.Unlock()
+	storeMu.Unlock()
+
+	result := map[string]interface{}{"listing": liveViews(car)}
+	if len(duplicates) > 0 {
+		result["warning"] = "image matches a photo on another listing"
+		result["duplicate_of"] = duplicates
+	}
+	respond(w, http.StatusCreated, result)
+}
+
+// galleryFullMessage explains why an upload to a full gallery was refused.
+func galleryFullMessage() string {
+	return fmt.Sprintf("gallery is full (at most %d images); remove one first", maxListingImages)
+}
+
+// perceptualHash computes a 64-bit difference hash (dHash) of img.
+// The image is reduced to a 9×8 grayscale grid and each bit records whether a
+// cell is brighter than its right-hand neighbour, so re-encoding, resizing
+// and small colour tweaks barely change the hash.
+func perceptualHash(img image.Image) uint64 {
+	const cols, rows = 9, 8
+	b := img.Bounds()
+	var grid [rows][cols]float64
+
+	for y := 0; y < rows; y++ {
+		y0 := b.Min.Y + y*b.Dy()/rows
+		y1 := b.Min.Y + (y+1)*b.Dy()/rows
+		for x := 0; x < cols; x++ {
+			x0 := b.Min.X + x*b.Dx()/cols
+			x1 := b.Min.X + (x+1)*b.Dx()/cols
+			var sum float64
+			var n int
+			for py := y0; py < y1 || py == y0; py++ {
+				for px := x0; px < x1 || px == x0; px++ {
+					r, g, bl, _ := img.At(px, py).RGBA()
+					sum += 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+					n++
+				}
+			}
+			grid[y][x] = sum / float64(n)
+		}
+	}
+
+	var hash uint64
+	for y := 0; y < rows; y++ {
+		for x := 0; x < cols-1; x++ {
+			hash <<= 1
+			if grid[y][x] > grid[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash
+}
+
+// similarImages returns the IDs of other listings with any photo whose hash
+// is within imageHashThreshold bits of hash, sorted ascending. Never nil, so it
+// encodes as [] when there are none.
+//
+// The scan stops early once maxSimilarImages matches are found or
+// maxImageHashScan hashes have been checked, so on a very large store the
+// result is a sample rather than every match — enough to flag or reject a
+// duplicate. Stores below both limits always get the full result.
+func similarImages(hash uint64, excludeID int) []int {
+	imageHashesMu.RLock()
+	defer imageHashesMu.RUnlock()
+
+	ids := []int{}
+	scanned := 0
+scan:
+	for id, photos := range imageHashes {
+		if id == excludeID {
+			continue
+		}
+		for _, other := range photos {
+			if maxImageHashScan > 0 && scanned >= maxImageHashScan {
+				break scan
+			}
+			scanned++
+			if bits.OnesCount64(hash^other) <= imageHashThreshold {
+				ids = append(ids, id)
+				if maxSimilarImages > 0 && len(ids) >= maxSimilarImages {
+					break scan
+				}
+				break // one matching photo is enough for this listing
+			}
+		}
+	}
+	sort.Ints(ids)
+	return ids
+}
+
+// pruneImageHashes forgets the hashes of photos no longer in car's gallery,
+// so a removed photo stops matching new uploads. The caller must hold
+// storeMu.
+func pruneImageHashes(car CarListing) {
+	imageHashesMu.Lock()
+	defer imageHashesMu.Unlock()
+	photos := imageHashes[car.ID]
+	for url := range photos {
+		if !slices.Contains(car.Images, url) {
+			delete(photos, url)
+			markCarsDirty()
+		}
+	}
+	if photos != nil && len(photos) == 0 {
+		delete(imageHashes, car.ID)
+	}
+}
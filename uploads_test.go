@@ -0,0 +1,271 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"math/rand"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// useUploadDir points uploadDir at a fresh temporary directory.
+func useUploadDir(t *testing.T) string {
+	t.Helper()
+	saved := uploadDir
+	t.Cleanup(func() { uploadDir = saved })
+	uploadDir = t.TempDir()
+	return uploadDir
+}
+
+func TestUploadsRangeRequest(t *testing.T) {
+	dir := useUploadDir(t)
+	content := []byte("0123456789abcdefghij")
+	if err := os.WriteFile(filepath.Join(dir, "photo.jpg"), content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest("GET", "/uploads/photo.jpg", nil)
+	r.Header.Set("Range", "bytes=5-9")
+	rec := httptest.NewRecorder()
+	uploadsHandler(rec, r)
+
+	if rec.Code != http.StatusPartialContent {
+		t.Fatalf("status = %d, want 206", rec.Code)
+	}
+	if got := rec.Body.String(); got != "56789" {
+		t.Errorf("body = %q, want %q", got, "56789")
+	}
+	if got := rec.Header().Get("Content-Range"); got != "bytes 5-9/20" {
+		t.Errorf("Content-Range = %q, want %q", got, "bytes 5-9/20")
+	}
+	if got := rec.Header().Get("Accept-Ranges"); got != "bytes" {
+		t.Errorf("Accept-Ranges = %q, want bytes", got)
+	}
+
+	// Without a Range header the whole file comes back
+	rec = httptest.NewRecorder()
+	uploadsHandler(rec, httptest.NewRequest("GET", "/uploads/photo.jpg", nil))
+	if rec.Code != http.StatusOK || rec.Body.Len() != len(content) {
+		t.Errorf("full request: status %d, %d bytes; want 200, %d bytes", rec.Code, rec.Body.Len(), len(content))
+	}
+}
+
+func TestUploadsRejectsTraversalAndDirs(t *testing.T) {
+	dir := useUploadDir(t)
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"/uploads/", "/uploads/sub", "/uploads/../config.go", "/uploads/missing.jpg"} {
+		rec := httptest.NewRecorder()
+		uploadsHandler(rec, httptest.NewRequest("GET", p, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status = %d, want 404", p, rec.Code)
+		}
+	}
+}
+
+// gradientPNG encodes a w×h image shaded left-to-right (or right-to-left when
+// reversed), offset in brightness by tint.
+func gradientPNG(t *testing.T, w, h int, reversed bool, tint uint8) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			v := uint8(x * 200 / w)
+			if reversed {
+				v = 200 - v
+			}
+			v += uint8((y * 37) % 20) // some vertical texture
+			img.Set(x, y, color.RGBA{v + tint, v + tint, v, 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// uploadImage posts data as the "image" multipart field for carID as user.
+func uploadImage(t *testing.T, carID, user string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	part, err := mw.CreateFormFile("image", "photo.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	mw.Close()
+
+	r := httptest.NewRequest("POST", "/api/cars/"+carID+"/image", &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	uploadImageHandler(rec, asUser(r, user))
+	return rec
+}
+
+// uploadResult is the data of a successful upload response.
+type uploadResult struct {
+	Listing     CarListing `json:"listing"`
+	Warning     string     `json:"warning"`
+	DuplicateOf []int      `json:"duplicate_of"`
+}
+
+func TestUploadFlagsNearDuplicate(t *testing.T) {
+	useUploadDir(t)
+	useStore(t,
+		CarListing{ID: 1, Seller: "alice"},
+		CarListing{ID: 2, Seller: "bob"},
+		CarListing{ID: 3, Seller: "carol"},
+	)
+
+	rec := uploadImage(t, "1", "alice", gradientPNG(t, 320, 240, false, 0))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("first upload = %d: %s", rec.Code, rec.Body.String())
+	}
+	var first uploadResult
+	decodeData(t, rec, &first)
+	if first.Warning != "" || !isUploadPath(first.Listing.ImageURL) {
+		t.Errorf("first upload: warning %q, image %q", first.Warning, first.Listing.ImageURL)
+	}
+
+	// The same shot, resized and slightly brightened, on another listing
+	rec = uploadImage(t, "2", "bob", gradientPNG(t, 160, 120, false, 6))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("near-duplicate upload = %d: %s", rec.Code, rec.Body.String())
+	}
+	var dup uploadResult
+	decodeData(t, rec, &dup)
+	if dup.Warning == "" || !reflect.DeepEqual(dup.DuplicateOf, []int{1}) {
+		t.Errorf("near-duplicate: warning %q duplicate_of %v, want a warning naming listing 1", dup.Warning, dup.DuplicateOf)
+	}
+
+	// A genuinely different photo isn't flagged
+	rec = uploadImage(t, "3", "carol", gradientPNG(t, 320, 240, true, 0))
+	var other uploadResult
+	decodeData(t, rec, &other)
+	if other.Warning != "" || len(other.DuplicateOf) != 0 {
+		t.Errorf("different photo flagged as a duplicate of %v", other.DuplicateOf)
+	}
+}
+
+func TestUploadMatchesEveryGalleryPhoto(t *testing.T) {
+	useUploadDir(t)
+	useStore(t,
+		CarListing{ID: 1, Seller: "alice", Make: "BMW", Model: "M3", Year: 2021, Price: 65000},
+		CarListing{ID: 2, Seller: "bob"},
+		CarListing{ID: 3, Seller: "carol"},
+	)
+	for _, photo := range [][]byte{gradientPNG(t, 320, 240, false, 0), gradientPNG(t, 320, 240, true, 0)} {
+		if rec := uploadImage(t, "1", "alice", photo); rec.Code != http.StatusCreated {
+			t.Fatalf("upload to listing 1 = %d: %s", rec.Code, rec.Body.String())
+		}
+	}
+
+	// The first photo is no longer primary but still counts
+	var dup uploadResult
+	decodeData(t, uploadImage(t, "2", "bob", gradientPNG(t, 160, 120, false, 6)), &dup)
+	if !reflect.DeepEqual(dup.DuplicateOf, []int{1}) {
+		t.Errorf("copy of an older gallery photo: duplicate_of %v, want [1]", dup.DuplicateOf)
+	}
+
+	// Removing the primary photo drops its hash
+	rec := httptest.NewRecorder()
+	updateCarHandler(rec, asUser(jsonRequest(t, "PATCH", "/api/cars/1", map[string]string{"image_url": ""}), "alice"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("remove primary photo = %d: %s", rec.Code, rec.Body.String())
+	}
+	var other uploadResult
+	decodeData(t, uploadImage(t, "3", "carol", gradientPNG(t, 160, 120, true, 6)), &other)
+	if len(other.DuplicateOf) != 0 {
+		t.Errorf("copy of a removed photo flagged as a duplicate of %v", other.DuplicateOf)
+	}
+	if n := len(imageHashes[1]); n != 1 {
+		t.Errorf("listing 1 keeps %d photo hashes, want 1 for its remaining photo", n)
+	}
+}
+
+func TestUploadRejectsOversizedDimensions(t *testing.T) {
+	useUploadDir(t)
+	useStore(t, CarListing{ID: 1, Seller: "alice"})
+
+	rec := uploadImage(t, "1", "alice", gradientPNG(t, maxImageDimension+1, 1, false, 0))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("%d px wide: status = %d, want 413", maxImageDimension+1, rec.Code)
+	}
+	if rec := uploadImage(t, "1", "alice", []byte("not an image")); rec.Code != http.StatusBadRequest {
+		t.Errorf("garbage upload: status = %d, want 400", rec.Code)
+	}
+	if len(carStore[1].Images) != 0 {
+		t.Errorf("rejected uploads were added to the gallery: %v", carStore[1].Images)
+	}
+}
+
+func TestSimilarImagesSmallStore(t *testing.T) {
+	useStore(t)
+	const base = 0xF0F0_F0F0_F0F0_F0F0
+	imageHashes[1] = map[string]uint64{"/uploads/1.png": base}
+	imageHashes[2] = map[string]uint64{"/uploads/2.png": base ^ 0b111}                 // 3 bits off
+	imageHashes[3] = map[string]uint64{"/uploads/3.png": base ^ 0b11_1111}             // exactly imageHashThreshold bits off
+	imageHashes[4] = map[string]uint64{"/uploads/4.png": base ^ 0b111_1111}            // one bit too many
+	imageHashes[5] = map[string]uint64{"/uploads/5.png": ^uint64(base)}                // a different photo
+	imageHashes[6] = map[string]uint64{"/uploads/6.png": base ^ 0x8000_0000_0000_0001} // 2 bits off, far apart
+
+	// Below both caps the result is every match, sorted, and stable
+	want := []int{2, 3, 6}
+	for i := 0; i < 20; i++ {
+		if got := similarImages(base, 1); !reflect.DeepEqual(got, want) {
+			t.Fatalf("similarImages = %v, want %v", got, want)
+		}
+	}
+	if got := similarImages(base, 0); !reflect.DeepEqual(got, []int{1, 2, 3, 6}) {
+		t.Errorf("without an exclusion = %v, want the listing itself included", got)
+	}
+}
+
+func TestSimilarImagesStopsAtMatchCap(t *testing.T) {
+	useStore(t)
+	for id := 1; id <= maxSimilarImages*3; id++ {
+		imageHashes[id] = map[string]uint64{"/uploads/a.png": 42}
+	}
+	if got := similarImages(42, 0); len(got) != maxSimilarImages {
+		t.Errorf("%d matches returned, want the cap of %d", len(got), maxSimilarImages)
+	}
+}
+
+// BenchmarkSimilarImages scans a 50k-hash store: "distinct" photos never
+// match, so the whole store (up to maxImageHashScan) is checked; "duplicates"
+// match throughout and exit once maxSimilarImages are found.
+func BenchmarkSimilarImages(b *testing.B) {
+	saved := imageHashes
+	b.Cleanup(func() { imageHashes = saved })
+
+	rng := rand.New(rand.NewSource(1))
+	distinct := make(map[int]map[string]uint64, 50000)
+	duplicates := make(map[int]map[string]uint64, 50000)
+	for id := 1; id <= 50000; id++ {
+		distinct[id] = map[string]uint64{"/uploads/a.png": rng.Uint64()} // ~32 bits set, far from the probe
+		duplicates[id] = map[string]uint64{"/uploads/a.png": 1 << (id % 64)}
+	}
+
+	for _, bb := range []struct {
+		name   string
+		hashes map[int]map[string]uint64
+	}{{"distinct", distinct}, {"duplicates", duplicates}} {
+		b.Run(bb.name, func(b *testing.B) {
+			imageHashes = bb.hashes
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				similarImages(0, 0)
+			}
+		})
+	}
+}
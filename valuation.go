@@ -2,8 +2,16 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
 	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -17,97 +25,598 @@ import (
 // a portfolio/interview context without complex ML dependencies.
 func valuateHandler(w http.ResponseWriter, r *http.Request) {
 	var req ValuationRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		respond(w, http.StatusBadRequest, nil, "invalid request body")
+	if status, err := decodeJSON(w, r, &req, maxBodyBytes); err != nil {
+		respondError(w, status, errCodeInvalidBody, err.Error())
 		return
 	}
 
-	if req.Make == "" || req.Year == 0 {
-		respond(w, http.StatusBadRequest, nil, "make and year are required")
+	if errs := validateValuationRequest(req); len(errs) > 0 {
+		respondInvalid(w, errs)
 		return
 	}
 
-	value, factors := calculateValue(req)
-	variance := value * 0.07 // ±7% range for min/max estimate
+	lang := requestLanguage(req.Lang, r.Header.Get("Accept-Language"))
+	valuation := estimateValue(req, lang)
 
-	respond(w, http.StatusOK, ValuationResponse{
-		EstimatedMin: roundToHundred(value - variance),
-		EstimatedMax: roundToHundred(value + variance),
-		Confidence:   "medium",
-		Factors:      factors,
-	}, "")
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+	emitValuationRun(claims.Username, req, valuation, false)
+
+	w.Header().Set("Content-Language", lang)
+	respond(w, http.StatusOK, valuation)
+}
+
+// emitValuationRun records a valuation in the analytics stream.
+func emitValuationRun(actor string, req ValuationRequest, v ValuationResponse, batch bool) {
+	emitEvent(eventValuationRun, actor, 0, map[string]interface{}{
+		"make":       req.Make,
+		"year":       req.Year,
+		"confidence": v.Confidence,
+		"batch":      batch,
+	})
+}
+
+// validateValuationRequest returns every field of req the pricing engine
+// can't use (nil if there are none).
+func validateValuationRequest(req ValuationRequest) []ValidationError {
+	errs := validateVehicle(req.Make, req.Year, req.Mileage, req.FuelType, req.Condition)
+	if req.MaxFactors < 0 {
+		errs = append(errs, ValidationError{"max_factors", "max_factors must not be negative"})
+	}
+	return errs
+}
+
+// estimateValue runs the pricing engine for a validated request and builds
+// the response, with factors in lang.
+func estimateValue(req ValuationRequest, lang string) ValuationResponse {
+	value, factors := cachedValuationFor(req)
+
+	confidence, score, notes := valuationConfidence(req)
+	factors = append(factors, notes...)
+	variance := value * varianceFor(confidence)
+
+	return ValuationResponse{
+		EstimatedMin:    roundPrice(value - variance),
+		EstimatedMax:    roundPrice(value + variance),
+		Confidence:      confidence,
+		ConfidenceScore: score,
+		Factors:         factorTexts(factors, req.MaxFactors, lang),
+	}
+}
+
+// ─── POST /api/valuate/batch ──────────────────────────────────────────────────
+
+// batchValuationResult is one entry of a batch valuation, in request order.
+// Exactly one of the embedded valuation and Errors is set.
+type batchValuationResult struct {
+	Index int `json:"index"`
+	*ValuationResponse
+	Errors []ValidationError `json:"errors,omitempty"`
+}
+
+// batchValuateHandler values up to maxBatchItems cars in one request, e.g.
+// a dealer importing a spreadsheet. An invalid entry gets its own errors and
+// doesn't fail the rest of the batch. Each entry may set its own lang;
+// otherwise Accept-Language applies. Up to batchValuationWorkers entries are
+// valued at once; each worker writes only its own slot of results, so the
+// output keeps request order without further locking.
+func batchValuateHandler(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Cars []ValuationRequest `json:"cars"`
+	}
+	if status, err := decodeJSON(w, r, &req, maxBatchBytes); err != nil {
+		respondError(w, status, errCodeInvalidBody, err.Error())
+		return
+	}
+
+	if len(req.Cars) == 0 {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "cars must not be empty")
+		return
+	}
+	if len(req.Cars) > maxBatchItems {
+		respondError(w, http.StatusRequestEntityTooLarge, errCodePayloadTooLarge,
+			fmt.Sprintf("at most %d cars per batch", maxBatchItems))
+		return
+	}
+
+	claims := r.Context().Value(ctxKey("claims")).(*Claims)
+	acceptLanguage := r.Header.Get("Accept-Language")
+	results := make([]batchValuationResult, len(req.Cars))
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for n := min(batchValuationWorkers, len(req.Cars)); n > 0; n-- {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				car := req.Cars[i]
+				results[i].Index = i
+				if errs := validateValuationRequest(car); len(errs) > 0 {
+					results[i].Errors = errs
+					continue
+				}
+				v := estimateValue(car, requestLanguage(car.Lang, acceptLanguage))
+				results[i].ValuationResponse = &v
+				emitValuationRun(claims.Username, car, v, true)
+			}
+		}()
+	}
+	for i := range req.Cars {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	respond(w, http.StatusOK, map[string]interface{}{
+		"results": results,
+		"count":   len(results),
+	})
+}
+
+// valuationConfidence rates how trustworthy an estimate for req is from the
+// pricing signals it carries, as a label and a 0–1 score, with notes
+// explaining any downgrade. A recognised make is worth confidenceMakeWeight
+// of the score and each of mileage, condition, fuel type and transmission
+// confidenceFieldWeight (a mileage of 0 counts as not given).
+//
+// The label is "high" when every signal is present, "low" when the make fell
+// back to the default base price, and "medium" otherwise. A base price nobody
+// has reviewed in a long time also makes it "low" and halves the score; a
+// tier with no recorded review is never downgraded.
+func valuationConfidence(req ValuationRequest) (confidence string, score float64, notes []valuationFactor) {
+	_, known := lookupBasePrice(req.Make)
+	if known {
+		score += confidenceMakeWeight
+	} else {
+		notes = append(notes, valuationFactor{Key: "unknown_make"})
+	}
+
+	complete := known
+	for _, given := range []bool{req.Mileage > 0, req.Condition != "", req.FuelType != "", req.Transmission != ""} {
+		if given {
+			score += confidenceFieldWeight
+		} else {
+			complete = false
+		}
+	}
+
+	switch {
+	case !known:
+		confidence = "low"
+	case complete:
+		confidence = "high"
+	default:
+		confidence = "medium"
+	}
+
+	if updated, ok := basePriceUpdatedFor(req.Make); ok && time.Since(updated) > basePriceStaleAfter {
+		confidence = "low"
+		score /= 2
+		notes = append(notes, valuationFactor{Key: "stale_base_price", Args: []interface{}{updated.Format("01/2006")}})
+	}
+	return confidence, math.Round(score*100) / 100, notes
+}
+
+// varianceFor is the ± fraction of the estimate reported as its min/max
+// range at a confidence level: wider for low confidence, tighter for high.
+func varianceFor(confidence string) float64 {
+	switch confidence {
+	case "high":
+		return varianceHigh
+	case "low":
+		return varianceLow
+	default:
+		return varianceMedium
+	}
+}
+
+// ─── GET /api/valuate/confidence ──────────────────────────────────────────────
+
+// confidenceDistributionHandler valuates every listing in the store (through
+// the valuation cache) and returns how many land in each confidence bucket,
+// plus how many makes fell back to the default base price. Surfaces
+// data-quality gaps for the analytics dashboard. Soft-deleted listings
+// awaiting purge aren't counted; the buckets always sum to total_listings.
+func confidenceDistributionHandler(w http.ResponseWriter, r *http.Request) {
+	storeMu.RLock()
+	reqs := make([]ValuationRequest, 0, len(carStore))
+	for _, car := range carStore {
+		if car.Status != statusDeleted {
+			reqs = append(reqs, valuationRequestFor(car))
+		}
+	}
+	storeMu.RUnlock()
+
+	buckets := map[string]int{"high": 0, "medium": 0, "low": 0}
+	defaultBase := 0
+	for _, req := range reqs {
+		buckets[estimateValue(req, defaultLanguage).Confidence]++
+		if _, known := lookupBasePrice(req.Make); !known {
+			defaultBase++
+		}
+	}
+
+	respond(w, http.StatusOK, map[string]interface{}{
+		"total_listings":     len(reqs),
+		"confidence":         buckets,
+		"default_base_price": defaultBase,
+	})
+}
+
+// ─── POST /api/valuate/sensitivity ────────────────────────────────────────────
+
+// sensitivityRequest is the body for the sensitivity endpoint: a base car
+// plus one field to vary over a list of values.
+type sensitivityRequest struct {
+	Base   ValuationRequest  `json:"base"`
+	Field  string            `json:"field"` // mileage, year or condition
+	Values []json.RawMessage `json:"values"`
+}
+
+// sensitivityPoint is one evaluated point on the curve.
+type sensitivityPoint struct {
+	Value          interface{} `json:"value"`
+	EstimatedValue float64     `json:"estimated_value"`
+	EstimatedMin   float64     `json:"estimated_min"`
+	EstimatedMax   float64     `json:"estimated_max"`
+}
+
+// sensitivityHandler re-runs the pricing engine for the base car once per
+// value of the chosen field, so a client can plot e.g. value against mileage.
+// Points are returned in the order the values were given. Every point must
+// pass validateValuationRequest; if any fails the whole request gets 422.
+// Errors in the varied field are named after the value, e.g.
+// "values[2].year"; errors in the rest of the base car appear once, as
+// "base.condition".
+func sensitivityHandler(w http.ResponseWriter, r *http.Request) {
+	var req sensitivityRequest
+	if status, err := decodeJSON(w, r, &req, maxBodyBytes); err != nil {
+		respondError(w, status, errCodeInvalidBody, err.Error())
+		return
+	}
+
+	if req.Base.Make == "" || (req.Base.Year == 0 && req.Field != "year") {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "base make and year are required")
+		return
+	}
+	if len(req.Values) == 0 || len(req.Values) > maxSensitivityPoints {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed,
+			fmt.Sprintf("values must contain between 1 and %d entries", maxSensitivityPoints))
+		return
+	}
+
+	points := make([]sensitivityPoint, 0, len(req.Values))
+	var invalid []ValidationError
+	for i, raw := range req.Values {
+		vreq := req.Base
+		var value interface{}
+		switch req.Field {
+		case "mileage", "year":
+			var n int
+			if err := json.Unmarshal(raw, &n); err != nil || n < 0 {
+				respondError(w, http.StatusBadRequest, errCodeValidationFailed, req.Field+" values must be non-negative integers")
+				return
+			}
+			if req.Field == "mileage" {
+				vreq.Mileage = n
+			} else {
+				vreq.Year = n
+			}
+			value = n
+		case "condition":
+			var c string
+			if err := json.Unmarshal(raw, &c); err != nil || c == "" {
+				respondError(w, http.StatusBadRequest, errCodeValidationFailed, "condition values must be non-empty strings")
+				return
+			}
+			vreq.Condition = c
+			value = c
+		default:
+			respondError(w, http.StatusBadRequest, errCodeValidationFailed, "field must be one of mileage, year, condition")
+			return
+		}
+
+		if errs := validateValuationRequest(vreq); len(errs) > 0 {
+			for _, e := range errs {
+				switch {
+				case e.Field == req.Field:
+					invalid = append(invalid, ValidationError{fmt.Sprintf("values[%d].%s", i, e.Field), e.Message})
+				case i == 0: // the base is the same for every point
+					invalid = append(invalid, ValidationError{"base." + e.Field, e.Message})
+				}
+			}
+			continue
+		}
+
+		estimate, _ := calculateValue(vreq)
+		confidence, _, _ := valuationConfidence(vreq)
+		variance := estimate * varianceFor(confidence)
+		points = append(points, sensitivityPoint{
+			Value:          value,
+			EstimatedValue: roundPrice(estimate),
+			EstimatedMin:   roundPrice(estimate - variance),
+			EstimatedMax:   roundPrice(estimate + variance),
+		})
+	}
+
+	if len(invalid) > 0 {
+		respondInvalid(w, invalid)
+		return
+	}
+
+	respond(w, http.StatusOK, map[string]interface{}{
+		"field":  req.Field,
+		"points": points,
+	})
+}
+
+// isAppreciating reports whether req should be valued as an appreciating
+// classic: flagged explicitly, or matching an appreciatingModels entry.
+func isAppreciating(req ValuationRequest) bool {
+	if req.Appreciating {
+		return true
+	}
+	for _, m := range appreciatingModels {
+		if !strings.Contains(strings.ToLower(req.Make), strings.ToLower(m.Make)) ||
+			!strings.Contains(strings.ToLower(req.Model), strings.ToLower(m.Model)) {
+			continue
+		}
+		if m.Transmission == "" || strings.EqualFold(req.Transmission, m.Transmission) {
+			return true
+		}
+	}
+	return false
+}
+
+// valuationRequestFor builds the valuation input describing a listing.
+func valuationRequestFor(car CarListing) ValuationRequest {
+	return ValuationRequest{
+		Make:         car.Make,
+		Model:        car.Model,
+		Year:         car.Year,
+		Mileage:      car.Mileage,
+		Condition:    car.Condition,
+		FuelType:     car.FuelType,
+		Transmission: car.Transmission,
+	}
+}
+
+// valuationFactor is one adjustment applied by the pricing engine, with its
+// absolute effect on the estimate so the most significant ones can be kept
+// when a client caps the factors list. The text is a message key plus
+// arguments, rendered per language by localize.
+type valuationFactor struct {
+	Key    string
+	Args   []interface{}
+	Impact float64 // change in value caused by this step (0 for notes)
 }
 
 // calculateValue runs the pricing engine and returns the estimated value
-// along with a human-readable list of factors that affected the price.
-func calculateValue(req ValuationRequest) (float64, []string) {
+// along with the list of factors that affected the price.
+func calculateValue(req ValuationRequest) (float64, []valuationFactor) {
 	value := basePriceFor(req.Make)
-	var factors []string
+	var factors []valuationFactor
+
+	// adjust applies a multiplier and records the resulting price change
+	adjust := func(mult float64, key string, args ...interface{}) {
+		before := value
+		value *= mult
+		factors = append(factors, valuationFactor{Key: key, Args: args, Impact: value - before})
+	}
 
 	// ── Step 1: Depreciation ──────────────────────────────────────────────────
-	// Cars depreciate ~12% per year after the first 3 years.
+	// Cars depreciate ~12% per year after the first 3 years. Classics gain
+	// appreciationRate per year of age instead.
 	age := time.Now().Year() - req.Year
-	if age > 3 {
-		for i := 3; i < age; i++ {
-			value *= 0.88
+	switch {
+	case isAppreciating(req):
+		if age > 0 {
+			adjust(math.Pow(1+appreciationRate, float64(age)), "appreciation", appreciationRate*100)
 		}
-		factors = append(factors, "Annual depreciation applied (12%/yr after year 3)")
+	case age > 3:
+		adjust(math.Pow(0.88, float64(age-3)), "depreciation")
 	}
 
 	// ── Step 2: Mileage ───────────────────────────────────────────────────────
 	switch {
 	case req.Mileage > 150000:
-		value *= 0.72
-		factors = append(factors, "Very high mileage (>150k km): -28%")
+		adjust(0.72, "mileage_very_high")
 	case req.Mileage > 100000:
-		value *= 0.82
-		factors = append(factors, "High mileage (>100k km): -18%")
+		adjust(0.82, "mileage_high")
 	case req.Mileage < 10000:
-		value *= 1.12
-		factors = append(factors, "Very low mileage (<10k km): +12%")
+		adjust(1.12, "mileage_very_low")
 	case req.Mileage < 30000:
-		value *= 1.06
-		factors = append(factors, "Low mileage (<30k km): +6%")
+		adjust(1.06, "mileage_low")
 	}
 
 	// ── Step 3: Condition ─────────────────────────────────────────────────────
-	switch strings.ToLower(req.Condition) {
+	// A "new" car older than newConditionMaxAge is almost certainly a
+	// data-entry error, so it's priced as used instead of getting the premium.
+	condition := strings.ToLower(req.Condition)
+	if condition == "new" && age > newConditionMaxAge {
+		adjust(1, "condition_new_too_old", age)
+		condition = "used"
+	}
+	switch condition {
 	case "new":
-		value *= 1.15
-		factors = append(factors, "New condition: +15%")
+		adjust(1.15, "condition_new")
 	case "certified":
-		value *= 1.06
-		factors = append(factors, "Certified pre-owned: +6%")
+		adjust(1.06, "condition_certified")
 	default:
-		factors = append(factors, "Standard used vehicle pricing")
+		adjust(1, "condition_used")
 	}
 
 	// ── Step 4: Fuel Type ─────────────────────────────────────────────────────
 	switch strings.ToLower(req.FuelType) {
 	case "electric":
-		value *= 1.18
-		factors = append(factors, "Electric: strong demand premium (+18%)")
+		adjust(1.18, "fuel_electric")
 	case "hybrid":
-		value *= 1.08
-		factors = append(factors, "Hybrid: efficiency premium (+8%)")
+		adjust(1.08, "fuel_hybrid")
 	case "diesel":
-		value *= 0.94
-		factors = append(factors, "Diesel: regulatory risk discount (-6%)")
+		adjust(0.94, "fuel_diesel")
+	}
+
+	// ── Step 5: Make/fuel transition adjustments ──────────────────────────────
+	for _, adj := range makeFuelAdjustments {
+		if !strings.Contains(strings.ToLower(req.Make), strings.ToLower(adj.Make)) {
+			continue
+		}
+		if !strings.EqualFold(req.FuelType, adj.FuelType) || age < adj.MinAge {
+			continue
+		}
+		adjust(adj.Multiplier, "make_fuel", adj.Reason, (adj.Multiplier-1)*100)
 	}
 
-	// ── Step 5: Transmission ──────────────────────────────────────────────────
+	// ── Step 6: Transmission ──────────────────────────────────────────────────
 	if strings.ToLower(req.Transmission) == "automatic" {
-		value *= 1.03
-		factors = append(factors, "Automatic gearbox: +3%")
+		adjust(1.03, "transmission_automatic")
 	}
 
 	return value, factors
 }
 
-// basePriceFor returns a tier-based starting price for a given car make.
-// Unrecognised makes fall back to a sensible mid-market default.
-func basePriceFor(make string) float64 {
-	tiers := map[string]float64{
+// cachedValuation is one entry in valuationCache.
+type cachedValuation struct {
+	value      float64
+	factors    []valuationFactor
+	computedAt time.Time
+}
+
+// valuationKey identifies a valuationCache entry: the engine input plus the
+// calendar year it was computed in. calculateValue derives the car's age from
+// time.Now().Year(), so without the year a January request would be served
+// last year's depreciation.
+type valuationKey struct {
+	req         ValuationRequest
+	currentYear int
+}
+
+// valuationCacheKey normalizes req to the fields calculateValue reads, so
+// requests differing only in case or output options share an entry.
+func valuationCacheKey(req ValuationRequest, now time.Time) valuationKey {
+	return valuationKey{
+		req: ValuationRequest{
+			Make:         strings.ToLower(strings.TrimSpace(req.Make)),
+			Year:         req.Year,
+			Mileage:      req.Mileage,
+			Condition:    strings.ToLower(req.Condition),
+			FuelType:     strings.ToLower(req.FuelType),
+			Transmission: strings.ToLower(req.Transmission),
+			// The model only matters for the classic check, so resolve it
+			// here rather than splitting the cache per model
+			Appreciating: isAppreciating(req),
+		},
+		currentYear: now.Year(),
+	}
+}
+
+// cachedValuationFor returns calculateValue(req), served from valuationCache
+// while the entry is younger than valuationCacheTTL. The factors slice is a
+// copy, so callers may append to it.
+func cachedValuationFor(req ValuationRequest) (float64, []valuationFactor) {
+	now := time.Now()
+	valuationCacheMu.RLock()
+	c, ok := valuationCache[valuationCacheKey(req, now)]
+	valuationCacheMu.RUnlock()
+	if ok && now.Sub(c.computedAt) < valuationCacheTTL {
+		return c.value, append([]valuationFactor(nil), c.factors...)
+	}
+	return storeValuation(req)
+}
+
+// storeValuation runs the engine for req and (re)places its cache entry.
+// The first store after a year rollover also evicts last year's entries,
+// which can never be hit again. Adding to a full cache first drops expired
+// entries and, if none have expired, one arbitrary entry.
+func storeValuation(req ValuationRequest) (float64, []valuationFactor) {
+	now := time.Now()
+	key := valuationCacheKey(req, now)
+	value, factors := calculateValue(key.req)
+
+	valuationCacheMu.Lock()
+	if valuationCacheYear != key.currentYear {
+		for k := range valuationCache {
+			if k.currentYear != key.currentYear {
+				delete(valuationCache, k)
+			}
+		}
+		valuationCacheYear = key.currentYear
+	}
+	if _, exists := valuationCache[key]; !exists && len(valuationCache) >= valuationCacheMaxEntries {
+		evictValuations(now)
+	}
+	valuationCache[key] = cachedValuation{value: value, factors: factors, computedAt: now}
+	valuationCacheMu.Unlock()
+	return value, append([]valuationFactor(nil), factors...)
+}
+
+// evictValuations makes room in a full valuationCache: it deletes every
+// expired entry, or a random one (map order is random) if none has expired.
+// Callers hold valuationCacheMu.
+func evictValuations(now time.Time) {
+	for k, c := range valuationCache {
+		if now.Sub(c.computedAt) >= valuationCacheTTL {
+			delete(valuationCache, k)
+		}
+	}
+	if len(valuationCache) < valuationCacheMaxEntries {
+		return
+	}
+	for k := range valuationCache {
+		delete(valuationCache, k)
+		return
+	}
+}
+
+// clearValuationCache drops every cached valuation, e.g. after base prices
+// change.
+func clearValuationCache() {
+	valuationCacheMu.Lock()
+	valuationCache = make(map[valuationKey]cachedValuation)
+	valuationCacheMu.Unlock()
+}
+
+// factorTexts renders factors as strings in lang. When max > 0 and there are
+// more factors than that, only the max largest by absolute price impact are
+// kept (in their original order) followed by an "…and N more" note.
+func factorTexts(factors []valuationFactor, max int, lang string) []string {
+	keep := make([]bool, len(factors))
+	if max > 0 && len(factors) > max {
+		idx := make([]int, len(factors))
+		for i := range idx {
+			idx[i] = i
+		}
+		sort.SliceStable(idx, func(a, b int) bool {
+			return math.Abs(factors[idx[a]].Impact) > math.Abs(factors[idx[b]].Impact)
+		})
+		for _, i := range idx[:max] {
+			keep[i] = true
+		}
+	} else {
+		for i := range keep {
+			keep[i] = true
+		}
+	}
+
+	texts := []string{}
+	for i, f := range factors {
+		if keep[i] {
+			texts = append(texts, localize(lang, f.Key, f.Args...))
+		}
+	}
+	if dropped := len(factors) - len(texts); dropped > 0 {
+		texts = append(texts, localize(lang, "more_factors", dropped))
+	}
+	return texts
+}
+
+// basePriceTable maps a make (lowercase) to its tier-based starting price.
+// Package-level so admins can retune it at runtime, and loadBasePrices can
+// replace it at startup. basePricesMu is an RWMutex so concurrent valuations
+// only take the read lock and never race with (or block each other behind)
+// an admin update.
+var (
+	basePriceTable = map[string]float64{
 		"rolls royce":  350000,
 		"ferrari":      260000,
 		"lamborghini":  230000,
@@ -126,17 +635,217 @@ func basePriceFor(make string) float64 {
 		"honda":        23000,
 		"hyundai":      21000,
 	}
+	// basePriceUpdatedAt records when each tier was last reviewed. Tiers
+	// with no recorded review (built-in, or a file entry without updated_at)
+	// have no entry and never count as stale.
+	basePriceUpdatedAt = map[string]time.Time{}
+	basePricesMu       sync.RWMutex
+)
+
+// basePriceEntry is one tier in the base-prices file or an admin update:
+// either a bare price or {"price": …, "updated_at": RFC3339}. UpdatedAt is
+// nil when no review date is known.
+type basePriceEntry struct {
+	Price     float64    `json:"price"`
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+}
+
+// UnmarshalJSON accepts the bare-number form alongside the object form, so
+// files written before tiers carried a review date still load.
+func (e *basePriceEntry) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &e.Price); err == nil {
+		e.UpdatedAt = nil
+		return nil
+	}
+	type plain basePriceEntry
+	return json.Unmarshal(data, (*plain)(e))
+}
+
+// basePricesPath is where tiers are loaded from and admin updates saved:
+// basePricesFile, or base-prices.json beside dataFile when only persistence
+// is configured. explicit reports whether the operator named the file, in
+// which case it must exist. "" means tiers live in memory only.
+func basePricesPath() (path string, explicit bool) {
+	if basePricesFile != "" {
+		return basePricesFile, true
+	}
+	if dataFile != "" {
+		return filepath.Join(filepath.Dir(dataFile), "base-prices.json"), false
+	}
+	return "", false
+}
+
+// loadBasePrices replaces the built-in tiers with the tiers saved at
+// basePricesPath, so brands can be added or retuned without a recompile and
+// admin updates survive a restart. Without a file the built-in table is
+// kept. Tiers without a review date are left undated.
+func loadBasePrices() error {
+	now := time.Now()
+	path, explicit := basePricesPath()
+	var data []byte
+	if path != "" {
+		var err error
+		data, err = os.ReadFile(path)
+		if err != nil && (explicit || !errors.Is(err, fs.ErrNotExist)) {
+			return err
+		}
+	}
+	if data == nil {
+		return nil
+	}
+	var raw map[string]basePriceEntry
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if len(raw) == 0 {
+		return fmt.Errorf("%s: no base prices", path)
+	}
+	entries, err := normalizeBasePrices(raw, now)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	basePricesMu.Lock()
+	basePriceTable = make(map[string]float64, len(entries))
+	basePriceUpdatedAt = make(map[string]time.Time, len(entries))
+	for brand, e := range entries {
+		basePriceTable[brand] = e.Price
+		if e.UpdatedAt != nil {
+			basePriceUpdatedAt[brand] = *e.UpdatedAt
+		}
+	}
+	basePricesMu.Unlock()
+	return nil
+}
+
+// saveBasePrices writes the tier table to basePricesPath atomically, so a
+// crash mid-write leaves the previous file. No-op unless persistence is
+// enabled. Callers hold basePricesMu.
+func saveBasePrices() error {
+	path, _ := basePricesPath()
+	if dataFile == "" || path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(basePriceEntries(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data)
+}
+
+// basePriceEntries returns a copy of the tier table as make → entry, dated
+// only where a review was recorded. Callers hold basePricesMu.
+func basePriceEntries() map[string]basePriceEntry {
+	entries := make(map[string]basePriceEntry, len(basePriceTable))
+	for brand, price := range basePriceTable {
+		e := basePriceEntry{Price: price}
+		if updated, ok := basePriceUpdatedAt[brand]; ok {
+			e.UpdatedAt = &updated
+		}
+		entries[brand] = e
+	}
+	return entries
+}
+
+// normalizeBasePrices lowercases and trims make names, rejecting empty names,
+// non-positive prices and review dates later than now.
+func normalizeBasePrices(raw map[string]basePriceEntry, now time.Time) (map[string]basePriceEntry, error) {
+	entries := make(map[string]basePriceEntry, len(raw))
+	for make, e := range raw {
+		key := strings.ToLower(strings.TrimSpace(make))
+		if key == "" {
+			return nil, errors.New("make names must not be empty")
+		}
+		if e.Price <= 0 {
+			return nil, errors.New("base price for " + key + " must be positive")
+		}
+		if e.UpdatedAt != nil && e.UpdatedAt.After(now.Add(maxClockSkew)) {
+			return nil, errors.New("updated_at for " + key + " must not be in the future")
+		}
+		entries[key] = e
+	}
+	return entries, nil
+}
+
+// ─── GET /api/valuate/brands ──────────────────────────────────────────────────
+
+// brandTier is one entry of the brands list.
+type brandTier struct {
+	Brand     string  `json:"brand"`
+	BasePrice float64 `json:"base_price"`
+}
+
+// brandsHandler lists the makes the valuation engine knows, alphabetically,
+// with their base price tiers, so the frontend can offer make autocomplete.
+func brandsHandler(w http.ResponseWriter, r *http.Request) {
+	basePricesMu.RLock()
+	brands := make([]brandTier, 0, len(basePriceTable))
+	for brand, price := range basePriceTable {
+		brands = append(brands, brandTier{Brand: brand, BasePrice: price})
+	}
+	basePricesMu.RUnlock()
+
+	sort.Slice(brands, func(i, j int) bool { return brands[i].Brand < brands[j].Brand })
+
+	respond(w, http.StatusOK, map[string]interface{}{
+		"brands": brands,
+		"count":  len(brands),
+	})
+}
+
+// basePriceFor returns a tier-based starting price for a given car make.
+// Unrecognised makes fall back to a sensible mid-market default.
+func basePriceFor(make string) float64 {
+	price, _ := lookupBasePrice(make)
+	return price
+}
+
+// lookupBasePrice is basePriceFor that also reports whether the make matched
+// a known tier (false means the default fallback was used).
+func lookupBasePrice(make string) (price float64, known bool) {
+	basePricesMu.RLock()
+	defer basePricesMu.RUnlock()
 
 	lower := strings.ToLower(make)
-	for brand, price := range tiers {
+	for brand, price := range basePriceTable {
 		if strings.Contains(lower, brand) {
-			return price
+			return price, true
 		}
 	}
-	return 30000 // default mid-market fallback
+	return 30000, false // default mid-market fallback
+}
+
+// basePriceUpdatedFor returns when the tier matching make was last reviewed.
+// ok is false for unknown makes and for tiers with no recorded review.
+func basePriceUpdatedFor(make string) (updated time.Time, ok bool) {
+	basePricesMu.RLock()
+	defer basePricesMu.RUnlock()
+
+	lower := strings.ToLower(make)
+	for brand := range basePriceTable {
+		if strings.Contains(lower, brand) {
+			updated, ok = basePriceUpdatedAt[brand]
+			return updated, ok
+		}
+	}
+	return time.Time{}, false
+}
+
+// roundPrice rounds a price for display at a precision that scales with its
+// size: a $23,000 Honda to the nearest 100, a $2M hypercar to the nearest
+// 1000 (see roundFineBelow / roundMediumBelow).
+func roundPrice(v float64) float64 {
+	switch {
+	case v < roundFineBelow:
+		return roundTo(v, 100)
+	case v < roundMediumBelow:
+		return roundTo(v, 500)
+	default:
+		return roundTo(v, 1000)
+	}
 }
 
-// roundToHundred rounds a value to the nearest 100 for cleaner display.
-func roundToHundred(v float64) float64 {
-	return float64(int(v/100+0.5)) * 100
+// roundTo rounds v to the nearest multiple of step.
+func roundTo(v, step float64) float64 {
+	return math.Round(v/step) * step
 }
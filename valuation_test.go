@@ -0,0 +1,692 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// asUser returns r carrying claims for username, as AuthMiddleware would
+// leave it, so handlers can be called directly.
+func asUser(r *http.Request, username string) *http.Request {
+	claims := &Claims{Username: username, TokenType: "access"}
+	return r.WithContext(context.WithValue(r.Context(), ctxKey("claims"), claims))
+}
+
+// jsonRequest builds a request with body marshalled as JSON (nil sends none).
+func jsonRequest(t testing.TB, method, target string, body interface{}) *http.Request {
+	t.Helper()
+	var buf bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatalf("encoding request body: %v", err)
+		}
+	}
+	r := httptest.NewRequest(method, target, &buf)
+	r.Header.Set("Content-Type", "application/json")
+	return r
+}
+
+// decodeData unmarshals the data field of a response envelope into dst.
+func decodeData(t *testing.T, rec *httptest.ResponseRecorder, dst interface{}) {
+	t.Helper()
+	var env struct {
+		Success bool            `json:"success"`
+		Data    json.RawMessage `json:"data"`
+		Error   string          `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &env); err != nil {
+		t.Fatalf("decoding envelope %q: %v", rec.Body.String(), err)
+	}
+	if err := json.Unmarshal(env.Data, dst); err != nil {
+		t.Fatalf("decoding data %s: %v", env.Data, err)
+	}
+}
+
+// valuate runs req through valuateHandler as username and decodes the result.
+func valuate(t *testing.T, req ValuationRequest) ValuationResponse {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	valuateHandler(rec, asUser(jsonRequest(t, "POST", "/api/valuate", req), "seller"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /api/valuate = %d: %s", rec.Code, rec.Body.String())
+	}
+	var v ValuationResponse
+	decodeData(t, rec, &v)
+	return v
+}
+
+func TestMakeFuelAdjustment(t *testing.T) {
+	saved := makeFuelAdjustments
+	t.Cleanup(func() {
+		makeFuelAdjustments = saved
+		clearValuationCache()
+	})
+
+	diesel := ValuationRequest{
+		Make: "Audi", Year: time.Now().Year() - 8, Mileage: 90000,
+		Condition: "used", FuelType: "diesel", Transmission: "manual",
+	}
+	makeFuelAdjustments = nil
+	before, _ := calculateValue(diesel)
+
+	makeFuelAdjustments = []MakeFuelAdjustment{
+		{Make: "audi", FuelType: "diesel", MinAge: 5, Multiplier: 0.9, Reason: "ICE phase-out"},
+	}
+	after, factors := calculateValue(diesel)
+	if math.Abs(after-before*0.9) > 0.01 {
+		t.Errorf("adjusted value = %.2f, want %.2f (0.9 × %.2f)", after, before*0.9, before)
+	}
+	last := factors[len(factors)-1]
+	if last.Key != "make_fuel" || last.Impact >= 0 {
+		t.Errorf("last factor = %+v, want a negative make_fuel adjustment", last)
+	}
+
+	clearValuationCache()
+	v := valuate(t, diesel)
+	found := false
+	for _, f := range v.Factors {
+		if strings.HasPrefix(f, "ICE phase-out: -10%") {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("factors %q missing the ICE phase-out adjustment", v.Factors)
+	}
+
+	// Neither a younger car nor another fuel type is touched
+	young := diesel
+	young.Year = time.Now().Year() - 2
+	petrol := diesel
+	petrol.FuelType = "petrol"
+	for _, req := range []ValuationRequest{young, petrol} {
+		_, factors := calculateValue(req)
+		for _, f := range factors {
+			if f.Key == "make_fuel" {
+				t.Errorf("%d %s: unexpected make_fuel adjustment", req.Year, req.FuelType)
+			}
+		}
+	}
+}
+
+func TestBatchValuationItemCap(t *testing.T) {
+	car := ValuationRequest{Make: "BMW", Year: 2020, Mileage: 30000, Condition: "used", FuelType: "petrol"}
+	cars := make([]ValuationRequest, maxBatchItems+1)
+	for i := range cars {
+		cars[i] = car
+	}
+
+	rec := httptest.NewRecorder()
+	body := map[string]interface{}{"cars": cars}
+	batchValuateHandler(rec, asUser(jsonRequest(t, "POST", "/api/valuate/batch", body), "seller"))
+	if rec.Code != http.StatusRequestEntityTooLarge || !strings.Contains(rec.Body.String(), errCodePayloadTooLarge) {
+		t.Errorf("%d cars: status %d %s, want 413 %s", len(cars), rec.Code, rec.Body.String(), errCodePayloadTooLarge)
+	}
+
+	rec = httptest.NewRecorder()
+	body = map[string]interface{}{"cars": cars[:maxBatchItems]}
+	batchValuateHandler(rec, asUser(jsonRequest(t, "POST", "/api/valuate/batch", body), "seller"))
+	if rec.Code != http.StatusOK {
+		t.Errorf("%d cars: status %d, want 200", maxBatchItems, rec.Code)
+	}
+}
+
+// batchCars returns n distinct, valid valuation requests across makes, years
+// and mileages.
+func batchCars(n int) []ValuationRequest {
+	makes := []string{"BMW", "Audi", "Porsche", "Toyota", "Ferrari"}
+	fuels := []string{"petrol", "diesel", "electric", "hybrid"}
+	cars := make([]ValuationRequest, n)
+	for i := range cars {
+		cars[i] = ValuationRequest{
+			Make:      makes[i%len(makes)],
+			Year:      2005 + i%20,
+			Mileage:   5000 + i*1500,
+			Condition: "used",
+			FuelType:  fuels[i%len(fuels)],
+		}
+	}
+	return cars
+}
+
+func TestBatchValuationOrderAcrossWorkers(t *testing.T) {
+	saved := batchValuationWorkers
+	t.Cleanup(func() { batchValuationWorkers = saved })
+
+	cars := batchCars(50)
+	cars[7].Make = ""    // invalid entries mixed in
+	cars[31].Year = 1800 // keep their own slots
+	want := make([]*ValuationResponse, len(cars))
+	for i, car := range cars {
+		if len(validateValuationRequest(car)) == 0 {
+			v := estimateValue(car, defaultLanguage)
+			want[i] = &v
+		}
+	}
+
+	for _, workers := range []int{1, 4, 16, 64} {
+		batchValuationWorkers = workers
+		clearValuationCache()
+		rec := httptest.NewRecorder()
+		batchValuateHandler(rec, asUser(jsonRequest(t, "POST", "/api/valuate/batch", map[string]interface{}{"cars": cars}), "seller"))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%d workers: status %d: %s", workers, rec.Code, rec.Body.String())
+		}
+		var got struct {
+			Results []batchValuationResult `json:"results"`
+			Count   int                    `json:"count"`
+		}
+		decodeData(t, rec, &got)
+		if got.Count != len(cars) || len(got.Results) != len(cars) {
+			t.Fatalf("%d workers: %d results, want %d", workers, len(got.Results), len(cars))
+		}
+		for i, res := range got.Results {
+			if res.Index != i {
+				t.Errorf("%d workers: result %d has index %d", workers, i, res.Index)
+			}
+			switch {
+			case want[i] == nil && (res.ValuationResponse != nil || len(res.Errors) == 0):
+				t.Errorf("%d workers: entry %d = %+v, want validation errors only", workers, i, res)
+			case want[i] != nil && !reflect.DeepEqual(res.ValuationResponse, want[i]):
+				t.Errorf("%d workers: entry %d = %+v, want %+v", workers, i, res.ValuationResponse, want[i])
+			}
+		}
+	}
+}
+
+// BenchmarkBatchValuation values a 50-car batch on one worker versus the
+// configured pool. The cache is cleared each run so every entry hits the
+// engine.
+func BenchmarkBatchValuation(b *testing.B) {
+	saved := batchValuationWorkers
+	b.Cleanup(func() {
+		batchValuationWorkers = saved
+		clearValuationCache()
+	})
+	body := map[string]interface{}{"cars": batchCars(50)}
+
+	for _, bb := range []struct {
+		name    string
+		workers int
+	}{{"serial", 1}, {"pooled", saved}} {
+		b.Run(bb.name, func(b *testing.B) {
+			batchValuationWorkers = bb.workers
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				b.StopTimer()
+				clearValuationCache()
+				r := asUser(jsonRequest(b, "POST", "/api/valuate/batch", body), "seller")
+				b.StartTimer()
+				batchValuateHandler(httptest.NewRecorder(), r)
+			}
+		})
+	}
+}
+
+// Run with -race: valuations read the tier table while admin updates
+// replace tiers underneath them.
+func TestBasePricesConcurrentAccess(t *testing.T) {
+	useBasePrices(t)
+	dataFile = "" // keep the writers in memory
+
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				body := `{"bmw": ` + strconv.Itoa(50000+w*1000+i) + `, "make` + strconv.Itoa(w) + `": 40000}`
+				r := httptest.NewRequest("PUT", "/api/admin/valuation/base-prices", strings.NewReader(body))
+				rec := httptest.NewRecorder()
+				putBasePricesHandler(rec, asUser(r, "seller"))
+				if rec.Code != http.StatusOK {
+					t.Errorf("PUT = %d: %s", rec.Code, rec.Body.String())
+					return
+				}
+			}
+		}(w)
+	}
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				req := ValuationRequest{Make: "BMW", Year: 2020 - i%10, Mileage: 1000 * i, Condition: "used", FuelType: "petrol"}
+				if v, _ := calculateValue(req); v <= 0 {
+					t.Errorf("value = %v, want positive", v)
+					return
+				}
+				estimateValue(req, "en") // through the cache the writers clear
+				rec := httptest.NewRecorder()
+				brandsHandler(rec, httptest.NewRequest("GET", "/api/valuate/brands", nil))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if p := basePriceFor("bmw"); p < 50000 || p >= 54000 {
+		t.Errorf("bmw tier = %v, want one of the written values", p)
+	}
+}
+
+func TestStaleBasePriceLowersConfidence(t *testing.T) {
+	dir := useBasePrices(t)
+	full := ValuationRequest{
+		Make: "BMW", Year: 2021, Mileage: 40000, Condition: "used",
+		FuelType: "petrol", Transmission: "automatic",
+	}
+
+	setReviewed := func(at time.Time) {
+		basePricesMu.Lock()
+		basePriceUpdatedAt["bmw"] = at
+		basePricesMu.Unlock()
+		clearValuationCache()
+	}
+
+	setReviewed(time.Now().Add(-24 * time.Hour))
+	fresh := valuate(t, full)
+	if fresh.Confidence != "high" {
+		t.Fatalf("fresh tier: confidence = %q, want high", fresh.Confidence)
+	}
+
+	setReviewed(time.Now().Add(-basePriceStaleAfter - 24*time.Hour))
+	stale := valuate(t, full)
+	if stale.Confidence != "low" || stale.ConfidenceScore >= fresh.ConfidenceScore {
+		t.Errorf("stale tier: confidence %q (%.2f), want low below %.2f",
+			stale.Confidence, stale.ConfidenceScore, fresh.ConfidenceScore)
+	}
+	if stale.EstimatedMax-stale.EstimatedMin <= fresh.EstimatedMax-fresh.EstimatedMin {
+		t.Errorf("stale tier should widen the range: %v–%v vs %v–%v",
+			stale.EstimatedMin, stale.EstimatedMax, fresh.EstimatedMin, fresh.EstimatedMax)
+	}
+
+	// A file tier without updated_at has no review date, so never goes stale
+	basePricesFile = filepath.Join(dir, "tiers.json")
+	if err := os.WriteFile(basePricesFile, []byte(`{"bmw": 52000, "audi": {"price": 50000, "updated_at": "2020-01-01T00:00:00Z"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := loadBasePrices(); err != nil {
+		t.Fatal(err)
+	}
+	clearValuationCache()
+	if c := valuate(t, full).Confidence; c != "high" {
+		t.Errorf("undated file tier: confidence = %q, want high", c)
+	}
+	if _, dated := basePriceUpdatedFor("bmw"); dated {
+		t.Errorf("undated file tier was given a review date")
+	}
+	audi := full
+	audi.Make = "Audi"
+	if c := valuate(t, audi).Confidence; c != "low" {
+		t.Errorf("tier reviewed in 2020: confidence = %q, want low", c)
+	}
+}
+
+func TestUndatedBuiltInTierNeverStale(t *testing.T) {
+	dir := useBasePrices(t)
+	basePricesMu.Lock()
+	basePriceUpdatedAt = map[string]time.Time{}
+	basePricesMu.Unlock()
+
+	// Startup without a tiers file keeps the built-in table undated
+	if err := loadBasePrices(); err != nil {
+		t.Fatal(err)
+	}
+	if _, dated := basePriceUpdatedFor("bmw"); dated {
+		t.Fatalf("built-in tier was stamped at load")
+	}
+	full := ValuationRequest{Make: "BMW", Year: 2021, Mileage: 40000, Condition: "used", FuelType: "petrol", Transmission: "automatic"}
+	if c, _, notes := valuationConfidence(full); c != "high" || len(notes) != 0 {
+		t.Errorf("undated tier: confidence %q notes %v, want high with no stale note", c, notes)
+	}
+
+	// Reviewing another make persists and reports no date for the rest
+	if rec := putBasePrices(t, map[string]float64{"rivian": 65000}); rec.Code != http.StatusOK {
+		t.Fatalf("PUT = %d: %s", rec.Code, rec.Body.String())
+	} else if body := rec.Body.String(); strings.Contains(body, "0001-01-01") {
+		t.Errorf("admin response carries a zero date: %s", body)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "base-prices.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved map[string]map[string]interface{}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := saved["bmw"]["updated_at"]; ok {
+		t.Errorf("saved bmw tier = %v, want no updated_at", saved["bmw"])
+	}
+	if _, ok := saved["rivian"]["updated_at"]; !ok {
+		t.Errorf("saved rivian tier = %v, want the PUT recorded as its review", saved["rivian"])
+	}
+
+	// Reloading the saved file still leaves bmw undated
+	if err := loadBasePrices(); err != nil {
+		t.Fatal(err)
+	}
+	if _, dated := basePriceUpdatedFor("bmw"); dated {
+		t.Errorf("bmw picked up a review date from the saved file")
+	}
+}
+
+func TestFactorTextsKeepsLargestImpact(t *testing.T) {
+	factors := []valuationFactor{
+		{Key: "depreciation", Impact: -4000},
+		{Key: "mileage_low", Impact: 900},
+		{Key: "condition_used"}, // a note: no impact
+		{Key: "fuel_electric", Impact: 7000},
+		{Key: "transmission_automatic", Impact: -1200},
+	}
+
+	got := factorTexts(factors, 2, "en")
+	want := []string{
+		localize("en", "depreciation"),
+		localize("en", "fuel_electric"),
+		localize("en", "more_factors", 3),
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("max 2:\n got %q\nwant %q", got, want)
+	}
+
+	for _, max := range []int{0, len(factors), len(factors) + 1} {
+		if got := factorTexts(factors, max, "en"); len(got) != len(factors) {
+			t.Errorf("max %d: %d texts, want all %d with no note", max, len(got), len(factors))
+		}
+	}
+
+	v := valuate(t, ValuationRequest{
+		Make: "BMW", Year: 2015, Mileage: 120000, Condition: "used",
+		FuelType: "diesel", Transmission: "automatic", MaxFactors: 2,
+	})
+	if len(v.Factors) != 3 || !strings.HasPrefix(v.Factors[2], "…and ") {
+		t.Errorf("max_factors 2: factors = %q, want two plus a note", v.Factors)
+	}
+}
+
+func TestConfidenceDistributionSumsToListings(t *testing.T) {
+	useStore(t,
+		CarListing{Make: "BMW", Model: "330i", Year: 2021, Mileage: 40000, Condition: "used", FuelType: "petrol", Transmission: "automatic"},
+		CarListing{Make: "Toyota", Model: "Prius", Year: 2018, Mileage: 90000, FuelType: "hybrid"},
+		CarListing{Make: "Lada", Model: "Niva", Year: 1995},
+		CarListing{Make: "Zastava", Model: "Yugo", Year: 1988, Status: statusSold},
+		CarListing{Make: "Audi", Model: "A4", Year: 2012, Mileage: 210000, Condition: "used"},
+		CarListing{Make: "Skoda", Model: "Octavia", Year: 2015, Status: statusDeleted}, // awaiting purge
+	)
+
+	rec := httptest.NewRecorder()
+	confidenceDistributionHandler(rec, asUser(httptest.NewRequest("GET", "/api/valuate/confidence", nil), "seller"))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var got struct {
+		Total       int            `json:"total_listings"`
+		Confidence  map[string]int `json:"confidence"`
+		DefaultBase int            `json:"default_base_price"`
+	}
+	decodeData(t, rec, &got)
+
+	sum := 0
+	for _, bucket := range []string{"high", "medium", "low"} {
+		n, ok := got.Confidence[bucket]
+		if !ok {
+			t.Errorf("bucket %q missing from %v", bucket, got.Confidence)
+		}
+		sum += n
+	}
+	if got.Total != 5 || sum != got.Total || len(got.Confidence) != 3 {
+		t.Errorf("total %d, buckets %v (sum %d); want 5 listings all bucketed", got.Total, got.Confidence, sum)
+	}
+	if got.DefaultBase != 2 {
+		t.Errorf("default_base_price = %d, want 2 (Lada, Zastava)", got.DefaultBase)
+	}
+	valuationCacheMu.RLock()
+	cached := len(valuationCache)
+	valuationCacheMu.RUnlock()
+	if cached != 5 {
+		t.Errorf("valuation cache holds %d entries, want the 5 listings valued through it", cached)
+	}
+
+	useStore(t)
+	rec = httptest.NewRecorder()
+	confidenceDistributionHandler(rec, asUser(httptest.NewRequest("GET", "/api/valuate/confidence", nil), "seller"))
+	decodeData(t, rec, &got)
+	if got.Total != 0 || got.Confidence["high"]+got.Confidence["medium"]+got.Confidence["low"] != 0 {
+		t.Errorf("empty store: got %+v, want all zero", got)
+	}
+}
+
+// sensitivity posts body to sensitivityHandler.
+func sensitivity(t *testing.T, body interface{}) *httptest.ResponseRecorder {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	sensitivityHandler(rec, asUser(jsonRequest(t, "POST", "/api/valuate/sensitivity", body), "seller"))
+	return rec
+}
+
+func TestSensitivityMonotonicInMileage(t *testing.T) {
+	base := ValuationRequest{Make: "BMW", Year: 2020, Condition: "used", FuelType: "petrol"}
+	var mileages []int
+	for km := 0; km <= 200000; km += 10000 {
+		mileages = append(mileages, km)
+	}
+	rec := sensitivity(t, map[string]interface{}{"base": base, "field": "mileage", "values": mileages})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var got struct {
+		Points []sensitivityPoint `json:"points"`
+	}
+	decodeData(t, rec, &got)
+	if len(got.Points) != len(mileages) {
+		t.Fatalf("%d points, want %d", len(got.Points), len(mileages))
+	}
+	for i, p := range got.Points {
+		if p.Value != float64(mileages[i]) {
+			t.Errorf("point %d is for %v km, want %d (input order)", i, p.Value, mileages[i])
+		}
+		if p.EstimatedMin > p.EstimatedValue || p.EstimatedValue > p.EstimatedMax {
+			t.Errorf("point %d: %v outside its range %v–%v", i, p.EstimatedValue, p.EstimatedMin, p.EstimatedMax)
+		}
+		if i > 0 && p.EstimatedValue > got.Points[i-1].EstimatedValue {
+			t.Errorf("value rose from %v at %d km to %v at %d km",
+				got.Points[i-1].EstimatedValue, mileages[i-1], p.EstimatedValue, mileages[i])
+		}
+	}
+	if first, last := got.Points[0].EstimatedValue, got.Points[len(got.Points)-1].EstimatedValue; last >= first {
+		t.Errorf("200k km valued %v, want below the %v at 0 km", last, first)
+	}
+}
+
+func TestSensitivityRejectsInvalidPoints(t *testing.T) {
+	base := ValuationRequest{Make: "BMW", Year: 2020, Condition: "used"}
+	next := time.Now().Year() + 1
+
+	rec := sensitivity(t, map[string]interface{}{"base": base, "field": "year", "values": []int{2018, 1800, next + 5}})
+	if got := fieldErrors(t, rec); !reflect.DeepEqual(got, []string{"values[1].year", "values[2].year"}) {
+		t.Errorf("bad years: error fields = %v, want values[1].year and values[2].year", got)
+	}
+
+	badBase := base
+	badBase.FuelType = "steam"
+	rec = sensitivity(t, map[string]interface{}{"base": badBase, "field": "mileage", "values": []int{1000, 2000, 3000}})
+	if got := fieldErrors(t, rec); !reflect.DeepEqual(got, []string{"base.fuel_type"}) {
+		t.Errorf("bad base: error fields = %v, want base.fuel_type once", got)
+	}
+
+	rec = sensitivity(t, map[string]interface{}{"base": base, "field": "condition", "values": []string{"used", "salvage"}})
+	if got := fieldErrors(t, rec); !reflect.DeepEqual(got, []string{"values[1].condition"}) {
+		t.Errorf("bad condition: error fields = %v, want values[1].condition", got)
+	}
+
+	tooMany := make([]int, maxSensitivityPoints+1)
+	for name, body := range map[string]interface{}{
+		"unknown field":   map[string]interface{}{"base": base, "field": "colour", "values": []string{"red"}},
+		"no values":       map[string]interface{}{"base": base, "field": "mileage", "values": []int{}},
+		"too many values": map[string]interface{}{"base": base, "field": "mileage", "values": tooMany},
+		"negative value":  map[string]interface{}{"base": base, "field": "mileage", "values": []int{-1}},
+	} {
+		if rec := sensitivity(t, body); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, rec.Code)
+		}
+	}
+}
+
+func TestOldNewCarGetsNoPremium(t *testing.T) {
+	year := time.Now().Year()
+	price := func(condition string, age int) (float64, []string) {
+		req := ValuationRequest{Make: "Audi", Year: year - age, Mileage: 50000, Condition: condition, FuelType: "petrol"}
+		v, factors := calculateValue(req)
+		keys := make([]string, len(factors))
+		for i, f := range factors {
+			keys[i] = f.Key
+		}
+		return v, keys
+	}
+
+	// Within newConditionMaxAge the premium still applies
+	fresh, _ := price("new", newConditionMaxAge)
+	freshUsed, _ := price("used", newConditionMaxAge)
+	if math.Abs(fresh-freshUsed*1.15) > 0.01 {
+		t.Errorf("%d-year-old new car = %.2f, want 1.15 × %.2f", newConditionMaxAge, fresh, freshUsed)
+	}
+
+	old, keys := price("new", newConditionMaxAge+3)
+	oldUsed, _ := price("used", newConditionMaxAge+3)
+	if old != oldUsed {
+		t.Errorf("%d-year-old new car = %.2f, want the used price %.2f", newConditionMaxAge+3, old, oldUsed)
+	}
+	if !slices.Contains(keys, "condition_new_too_old") || slices.Contains(keys, "condition_new") {
+		t.Errorf("factors = %v, want condition_new_too_old instead of the premium", keys)
+	}
+
+	v := valuate(t, ValuationRequest{Make: "Audi", Year: year - 6, Mileage: 50000, Condition: "new", FuelType: "petrol"})
+	if !slices.Contains(v.Factors, localize("en", "condition_new_too_old", 6)) {
+		t.Errorf("factors %q don't explain the downgrade", v.Factors)
+	}
+}
+
+func TestValuationCacheYearRollover(t *testing.T) {
+	clearValuationCache()
+	t.Cleanup(clearValuationCache)
+
+	req := ValuationRequest{Make: "BMW", Year: 2020, Mileage: 30000, Condition: "good", FuelType: "petrol", Transmission: "automatic"}
+	now := time.Now()
+	lastYear := now.AddDate(-1, 0, 0)
+	want, _ := calculateValue(valuationCacheKey(req, now).req)
+
+	// A fresh entry written in December, still inside the TTL come January
+	valuationCacheMu.Lock()
+	valuationCache[valuationCacheKey(req, lastYear)] = cachedValuation{value: 1, computedAt: now}
+	valuationCacheYear = lastYear.Year()
+	valuationCacheMu.Unlock()
+
+	if got, _ := cachedValuationFor(req); got != want {
+		t.Errorf("after rollover: value = %v, want recomputed %v", got, want)
+	}
+	valuationCacheMu.RLock()
+	_, stale := valuationCache[valuationCacheKey(req, lastYear)]
+	_, fresh := valuationCache[valuationCacheKey(req, now)]
+	year := valuationCacheYear
+	valuationCacheMu.RUnlock()
+	if stale || !fresh || year != now.Year() {
+		t.Errorf("cache after rollover: stale=%v fresh=%v year=%d, want only a %d entry", stale, fresh, year, now.Year())
+	}
+
+	// Within the same year the entry is served from cache
+	valuationCacheMu.Lock()
+	valuationCache[valuationCacheKey(req, now)] = cachedValuation{value: 2, computedAt: now}
+	valuationCacheMu.Unlock()
+	if got, _ := cachedValuationFor(req); got != 2 {
+		t.Errorf("same year: value = %v, want the cached 2", got)
+	}
+}
+
+func TestRoundPriceTiers(t *testing.T) {
+	tests := []struct {
+		in, want float64
+	}{
+		{23049, 23000},
+		{23050, 23100},
+		{49949, 49900},
+		{49999, 50000}, // still the fine tier, and rounds onto the boundary
+		{50000, 50000},
+		{50249, 50000},
+		{50250, 50500},
+		{187740, 187500},
+		{199999, 200000},
+		{200000, 200000},
+		{200499, 200000},
+		{2000500, 2001000},
+		{0, 0},
+	}
+	for _, tt := range tests {
+		if got := roundPrice(tt.in); got != tt.want {
+			t.Errorf("roundPrice(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	for _, tt := range []struct{ v, step, want float64 }{{149, 100, 100}, {150, 100, 200}, {1249, 500, 1000}, {1500, 1000, 2000}} {
+		if got := roundTo(tt.v, tt.step); got != tt.want {
+			t.Errorf("roundTo(%v, %v) = %v, want %v", tt.v, tt.step, got, tt.want)
+		}
+	}
+}
+
+func TestAppreciatingClassicRisesWithAge(t *testing.T) {
+	t.Cleanup(clearValuationCache)
+	thisYear := time.Now().Year()
+	value := func(req ValuationRequest, age int) float64 {
+		req.Year = thisYear - age
+		v, _ := calculateValue(req)
+		return v
+	}
+	base := ValuationRequest{Make: "Porsche", Model: "911 GT3", Mileage: 20000, Condition: "used", Transmission: "manual"}
+	classic := base
+	classic.Appreciating = true
+
+	for _, age := range []int{1, 5, 10, 25} {
+		if older, newer := value(classic, age+1), value(classic, age); older <= newer {
+			t.Errorf("classic aged %d: %v, aged %d: %v; want the older car worth more", age+1, older, age, newer)
+		}
+	}
+	if old, young := value(base, 10), value(base, 4); old >= young {
+		t.Errorf("unflagged car aged 10 = %v, aged 4 = %v; want depreciation by default", old, young)
+	}
+
+	_, factors := calculateValue(classic)
+	if !slices.ContainsFunc(factors, func(f valuationFactor) bool { return f.Key == "appreciation" }) {
+		t.Errorf("factors %+v, want an appreciation note", factors)
+	}
+
+	// Classified by make and model through the config list
+	saved := appreciatingModels
+	t.Cleanup(func() { appreciatingModels = saved })
+	appreciatingModels = []AppreciatingModel{{Make: "porsche", Model: "gt3", Transmission: "manual"}}
+	if !isAppreciating(base) {
+		t.Errorf("manual GT3 not classified as appreciating")
+	}
+	auto := base
+	auto.Transmission = "automatic"
+	if isAppreciating(auto) {
+		t.Errorf("automatic GT3 classified as appreciating; the entry is manual-only")
+	}
+
+	older, newer := base, base
+	older.Year, newer.Year = thisYear-20, thisYear-10
+	if o, n := valuate(t, older), valuate(t, newer); o.EstimatedMin <= n.EstimatedMin {
+		t.Errorf("POST /api/valuate: 20-year-old GT3 %v, 10-year-old %v; want the older higher", o.EstimatedMin, n.EstimatedMin)
+	}
+}
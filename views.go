@@ -0,0 +1,98 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ─── GET /api/cars/{id}/view-trend ────────────────────────────────────────────
+
+// viewTrendHandler returns a listing's views per UTC day over the last
+// ?days= days (default 7, max 30), oldest first and including today, so a
+// client can draw a sparkline. Days without views are reported as 0.
+func viewTrendHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := parseCarID(r.URL.Path)
+	if err != nil {
+		respondError(w, http.StatusBadRequest, errCodeValidationFailed, "invalid car id")
+		return
+	}
+
+	days := 7
+	maxDays := int(viewHistoryRetention / (24 * time.Hour))
+	if raw := r.URL.Query().Get("days"); raw != "" {
+		if days, err = strconv.Atoi(raw); err != nil || days < 1 || days > maxDays {
+			respondError(w, http.StatusBadRequest, errCodeValidationFailed, "days must be between 1 and "+strconv.Itoa(maxDays))
+			return
+		}
+	}
+
+	if !carExists(id) {
+		respondError(w, http.StatusNotFound, errCodeNotFound, "car not found")
+		return
+	}
+
+	respond(w, http.StatusOK, map[string]interface{}{
+		"car_id": id,
+		"days":   viewTrend(id, days, time.Now()),
+	})
+}
+
+// viewDay is one bucket of the view trend.
+type viewDay struct {
+	Date  string `json:"date"` // YYYY-MM-DD, UTC
+	Views int    `json:"views"`
+}
+
+// viewTrend buckets the recorded views of a listing into the days UTC days
+// ending with now's.
+func viewTrend(id, days int, now time.Time) []viewDay {
+	today := now.UTC().Truncate(24 * time.Hour)
+	first := today.AddDate(0, 0, -(days - 1))
+
+	trend := make([]viewDay, days)
+	for i := range trend {
+		trend[i].Date = first.AddDate(0, 0, i).Format("2006-01-02")
+	}
+
+	viewHistoryMu.Lock()
+	defer viewHistoryMu.Unlock()
+	for _, t := range viewHistory[id] {
+		if t.Before(first) {
+			continue
+		}
+		if i := int(t.Sub(first) / (24 * time.Hour)); i < days {
+			trend[i].Views++
+		}
+	}
+	return trend
+}
+
+// recordView appends a view to the listing's history, dropping entries past
+// viewHistoryRetention or beyond maxViewHistory (oldest first). Views of a
+// listing deleted since it was read are ignored (see deleteViews).
+func recordView(id int, at time.Time) {
+	viewHistoryMu.Lock()
+	defer viewHistoryMu.Unlock()
+
+	viewsMu.RLock()
+	_, live := carViews[id]
+	viewsMu.RUnlock()
+	if !live {
+		return
+	}
+
+	history := append(viewHistory[id], at)
+	cutoff := at.Add(-viewHistoryRetention)
+	start := 0
+	for start < len(history) && history[start].Before(cutoff) {
+		start++
+	}
+	if len(history)-start > maxViewHistory {
+		start = len(history) - maxViewHistory
+	}
+	if start > 0 {
+		history = append([]time.Time(nil), history[start:]...)
+	}
+	viewHistory[id] = history
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestViewTrendDayBuckets(t *testing.T) {
+	useStore(t, CarListing{ID: 1}, CarListing{ID: 2})
+	now := time.Date(2026, 3, 10, 15, 30, 0, 0, time.UTC)
+	for _, at := range []time.Time{
+		now,
+		now.Add(-15 * time.Hour), // 00:30 today
+		now.Add(-16 * time.Hour), // 23:30 yesterday
+		now.AddDate(0, 0, -1),
+		now.AddDate(0, 0, -3),
+		now.AddDate(0, 0, -6).Add(-15 * time.Hour), // early on the first day
+		now.AddDate(0, 0, -7),                      // before the window
+	} {
+		recordView(1, at)
+	}
+	recordView(2, now) // another listing's view
+
+	var got []int
+	for _, d := range viewTrend(1, 7, now) {
+		got = append(got, d.Views)
+	}
+	if want := []int{1, 0, 0, 1, 0, 2, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("views per day = %v, want %v", got, want)
+	}
+	trend := viewTrend(1, 7, now)
+	if trend[0].Date != "2026-03-04" || trend[6].Date != "2026-03-10" {
+		t.Errorf("dates run %s to %s, want 2026-03-04 to 2026-03-10", trend[0].Date, trend[6].Date)
+	}
+}
+
+func TestViewTrendHandler(t *testing.T) {
+	useStore(t, CarListing{ID: 1})
+
+	for i := 0; i < 3; i++ {
+		rec := httptest.NewRecorder()
+		getCarHandler(rec, asUser(httptest.NewRequest("GET", "/api/cars/1", nil), "bob"))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("GET /api/cars/1 = %d", rec.Code)
+		}
+	}
+
+	trend := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		viewTrendHandler(rec, asUser(httptest.NewRequest("GET", "/api/cars/1/view-trend"+query, nil), "bob"))
+		return rec
+	}
+	rec := trend("?days=3")
+	var got struct {
+		Days []viewDay `json:"days"`
+	}
+	decodeData(t, rec, &got)
+	if len(got.Days) != 3 || got.Days[2].Views != 3 || got.Days[2].Date != time.Now().UTC().Format("2006-01-02") {
+		t.Errorf("trend = %+v, want 3 days ending with today's 3 views", got.Days)
+	}
+	decodeData(t, trend(""), &got)
+	if len(got.Days) != 7 {
+		t.Errorf("default trend has %d days, want 7", len(got.Days))
+	}
+	for _, bad := range []string{"?days=0", "?days=31", "?days=week"} {
+		if rec := trend(bad); rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", bad, rec.Code)
+		}
+	}
+}
+
+func TestViewHistoryBounded(t *testing.T) {
+	useStore(t, CarListing{ID: 1})
+	now := time.Now()
+
+	recordView(1, now.Add(-viewHistoryRetention-time.Hour))
+	recordView(1, now)
+	if n := len(viewHistory[1]); n != 1 {
+		t.Errorf("history holds %d views, want the expired one dropped", n)
+	}
+
+	for i := 0; i < maxViewHistory+10; i++ {
+		recordView(1, now.Add(time.Duration(i)*time.Millisecond))
+	}
+	history := viewHistory[1]
+	if len(history) != maxViewHistory {
+		t.Fatalf("history holds %d views, want the cap of %d", len(history), maxViewHistory)
+	}
+	if last := now.Add(time.Duration(maxViewHistory+9) * time.Millisecond); !history[len(history)-1].Equal(last) {
+		t.Errorf("newest view = %v, want %v kept", history[len(history)-1], last)
+	}
+
+	// Views of a deleted listing aren't recorded
+	deleteViews(1)
+	recordView(1, now)
+	if _, ok := viewHistory[1]; ok {
+		t.Errorf("view recorded for a deleted listing")
+	}
+}
+
+// TestConcurrentViewAndDelete races viewers against a delete of the same
+// listing; run with -race. A view must never bring a deleted listing back or
+// leave a counter or history behind for a purged ID.
+func TestConcurrentViewAndDelete(t *testing.T) {
+	useStore(t)
+	car := CarListing{ID: 1, Seller: "alice", Status: statusActive, ListedAt: time.Now().Format(time.RFC3339)}
+
+	for round := 0; round < 50; round++ {
+		hard := round%2 == 0
+		storeMu.Lock()
+		carStore[1] = car
+		storeMu.Unlock()
+		setViews(1, 0)
+
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for v := 0; v < 8; v++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				<-start
+				for i := 0; i < 20; i++ {
+					rec := httptest.NewRecorder()
+					getCarHandler(rec, asUser(httptest.NewRequest("GET", "/api/cars/1", nil), "bob"))
+					if rec.Code != http.StatusOK && rec.Code != http.StatusNotFound {
+						t.Errorf("view = %d, want 200 or 404", rec.Code)
+					}
+				}
+			}()
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			target := "/api/cars/1"
+			if hard {
+				target += "?hard=true"
+			}
+			rec := httptest.NewRecorder()
+			deleteCarHandler(rec, asUser(httptest.NewRequest("DELETE", target, nil), "alice"))
+			if rec.Code != http.StatusOK {
+				t.Errorf("delete = %d: %s", rec.Code, rec.Body.String())
+			}
+		}()
+		close(start)
+		wg.Wait()
+
+		storeMu.RLock()
+		stored, ok := carStore[1]
+		storeMu.RUnlock()
+		viewsMu.RLock()
+		_, counted := carViews[1]
+		viewsMu.RUnlock()
+		viewHistoryMu.Lock()
+		history := len(viewHistory[1])
+		viewHistoryMu.Unlock()
+
+		switch {
+		case hard && (ok || counted || history != 0):
+			t.Fatalf("round %d, hard delete: listing %v, counter %v, history %d; want all gone", round, ok, counted, history)
+		case !hard && (!ok || stored.Status != statusDeleted):
+			t.Fatalf("round %d, soft delete: listing %+v, want it kept as deleted", round, stored)
+		}
+		if !hard {
+			rec := httptest.NewRecorder()
+			getCarHandler(rec, asUser(httptest.NewRequest("GET", "/api/cars/1", nil), "bob"))
+			if rec.Code != http.StatusNotFound {
+				t.Fatalf("round %d: view after soft delete = %d, want 404", round, rec.Code)
+			}
+			deleteViews(1)
+		}
+	}
+}